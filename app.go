@@ -35,6 +35,7 @@ import (
 	"github.com/rubyniu105/framework/core/task"
 	"github.com/rubyniu105/framework/core/wrapper/taskset"
 	"github.com/rubyniu105/framework/modules/configs/client"
+	"github.com/rubyniu105/framework/modules/configs/server"
 	"github.com/shirou/gopsutil/v3/process"
 	"os"
 	"os/signal"
@@ -487,6 +488,11 @@ func (p *App) run() error {
 		}
 	}()
 
+	//the manager apis must be registered before the api server starts
+	if global.Env().SystemConfig.Configs.ManagerConfig.Enabled {
+		server.InitAPI()
+	}
+
 	if p.start != nil {
 		p.start()
 	}
@@ -519,6 +525,14 @@ func (p *App) run() error {
 		return nil
 	}, context.Background())
 
+	// serve configs to managed instances
+	if global.Env().SystemConfig.Configs.ManagerConfig.Enabled {
+		err := server.StartManager()
+		if err != nil {
+			log.Error("failed to start config manager,", err)
+		}
+	}
+
 	// register to config manager
 	if global.Env().SystemConfig.Configs.Managed {
 		err := client.ConnectToManager()
@@ -120,8 +120,11 @@ func initializeAPI() {
 	}
 }
 
-// HandleAPIMethod register api handler
-func HandleAPIMethod(method Method, pattern string, handler func(w http.ResponseWriter, req *http.Request, ps httprouter.Params)) {
+// HandleAPIMethod register api handler, the api without RequirePermission or AllowAuthenticated is only allowed to the admin
+func HandleAPIMethod(method Method, pattern string, handler func(w http.ResponseWriter, req *http.Request, ps httprouter.Params), options ...Option) {
+	o := newHandlerOptions(options...)
+	handler = RequirePermissionHandler(handler, o.GetRequiredPermissions()...)
+
 	l.Lock()
 	if registeredAPIMethodHandler == nil {
 		registeredAPIMethodHandler = map[string]map[string]func(w http.ResponseWriter, req *http.Request, ps httprouter.Params){}
@@ -190,13 +193,22 @@ func StartAPI() {
 
 	//init api handlers
 	if apiConfig.Security.Enabled {
-		apiBasicAuthFilter := BasicAuthFilter{
-			Username: apiConfig.Security.Username,
-			Password: apiConfig.Security.Password,
-		}
+		if len(apiConfig.Security.Strategies) > 0 {
+			authFilter, err := NewStrategyAuthFilter(apiConfig.Security)
+			if err != nil {
+				panic(err)
+			}
+			RegisterAPIFilter(authFilter)
+			registerConfiguredRoles(apiConfig.Security.Roles)
+		} else {
+			apiBasicAuthFilter := BasicAuthFilter{
+				Username: apiConfig.Security.Username,
+				Password: apiConfig.Security.Password,
+			}
 
-		//register api filters
-		RegisterAPIFilter(&apiBasicAuthFilter)
+			//register api filters
+			RegisterAPIFilter(&apiBasicAuthFilter)
+		}
 	}
 
 	//TODO support filter out specify api
@@ -0,0 +1,292 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	log "github.com/cihub/seelog"
+	httprouter "github.com/rubyniu105/framework/core/api/router"
+	"github.com/rubyniu105/framework/core/config"
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/util"
+	ccache "github.com/rubyniu105/framework/lib/cache"
+	"github.com/rubyniu105/framework/lib/guardian/auth"
+	"github.com/rubyniu105/framework/lib/guardian/auth/strategies/basic"
+	"github.com/rubyniu105/framework/lib/guardian/auth/strategies/jwt"
+	"github.com/rubyniu105/framework/lib/guardian/auth/strategies/ldap"
+	"github.com/rubyniu105/framework/lib/guardian/auth/strategies/oauth2/introspection"
+	"github.com/rubyniu105/framework/lib/guardian/auth/strategies/token"
+	"github.com/rubyniu105/framework/lib/guardian/auth/strategies/union"
+	x509strategy "github.com/rubyniu105/framework/lib/guardian/auth/strategies/x509"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const defaultAuthCacheTTL = 5 * time.Minute
+
+// StrategyAuthFilter authenticate api requests with a chain of guardian strategies,
+// the authenticated identity is attached to the request context
+type StrategyAuthFilter struct {
+	Strategy  union.Union
+	Challenge string
+}
+
+// NewStrategyAuthFilter build the auth filter from the `security.strategies` settings
+func NewStrategyAuthFilter(cfg config.APISecurityConfig) (*StrategyAuthFilter, error) {
+	strategy, err := NewAuthStrategy(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := StrategyAuthFilter{Strategy: strategy, Challenge: "Bearer"}
+	for _, v := range cfg.Strategies {
+		if v.Type == "basic" || v.Type == "ldap" {
+			filter.Challenge = "Basic realm=Restricted"
+			break
+		}
+	}
+	return &filter, nil
+}
+
+// NewAuthStrategy build a union strategy from the configured strategies, in order
+func NewAuthStrategy(cfg config.APISecurityConfig) (union.Union, error) {
+	if len(cfg.Strategies) == 0 {
+		return nil, errors.New("no auth strategy was configured")
+	}
+
+	strategies := []auth.Strategy{}
+	for _, v := range cfg.Strategies {
+		s, err := newAuthStrategy(cfg, v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid auth strategy [%v]", v.Type)
+		}
+		log.Debugf("api auth strategy [%v] enabled", v.Type)
+		strategies = append(strategies, s)
+	}
+	return union.New(strategies...), nil
+}
+
+func newAuthStrategy(security config.APISecurityConfig, cfg config.AuthStrategyConfig) (auth.Strategy, error) {
+	authCache := newAuthCache(util.GetDurationOrDefault(cfg.CacheTTL, defaultAuthCacheTTL))
+
+	switch cfg.Type {
+	case "basic":
+		users := map[string]config.AuthUserConfig{}
+		for _, u := range cfg.Basic.Users {
+			users[u.Username] = u
+		}
+		if len(users) == 0 && security.Username != "" {
+			users[security.Username] = config.AuthUserConfig{Username: security.Username, Password: security.Password}
+		}
+		if len(users) == 0 {
+			return nil, errors.New("no user was configured")
+		}
+		return basic.New(func(ctx context.Context, r *http.Request, userName, password []byte) (auth.Info, error) {
+			u, ok := users[string(userName)]
+			if !ok || subtle.ConstantTimeCompare([]byte(u.Password), password) != 1 {
+				return nil, basic.ErrInvalidCredentials
+			}
+			return auth.NewUserInfo(u.Username, getUserID(u), u.Groups, nil), nil
+		}), nil
+	case "token":
+		opts := []auth.Option{}
+		if cfg.Token.Header != "" {
+			opts = append(opts, token.SetParser(token.XHeaderParser(cfg.Token.Header)))
+		} else if cfg.Token.Query != "" {
+			opts = append(opts, token.SetParser(token.QueryParser(cfg.Token.Query)))
+		}
+		if cfg.Token.File != "" {
+			return token.NewStaticFromFile(cfg.Token.File, opts...)
+		}
+		tokens := map[string]auth.Info{}
+		for _, u := range cfg.Token.Tokens {
+			if u.Token == "" {
+				return nil, errors.Errorf("empty token for user [%v]", u.Username)
+			}
+			tokens[u.Token] = auth.NewUserInfo(u.Username, getUserID(u), u.Groups, nil)
+		}
+		if len(tokens) == 0 {
+			return nil, errors.New("no token was configured")
+		}
+		return token.NewStatic(tokens, opts...), nil
+	case "jwt":
+		if cfg.JWT.Secret == "" {
+			return nil, errors.New("jwt secret is required")
+		}
+		alg := cfg.JWT.Algorithm
+		if alg == "" {
+			alg = jwt.HS256
+		}
+		opts := []auth.Option{}
+		if cfg.JWT.Issuer != "" {
+			opts = append(opts, jwt.SetIssuer(cfg.JWT.Issuer))
+		}
+		if cfg.JWT.Audience != "" {
+			opts = append(opts, jwt.SetAudience(cfg.JWT.Audience))
+		}
+		keeper := jwt.StaticSecret{
+			ID:        cfg.JWT.KeyID,
+			Secret:    []byte(cfg.JWT.Secret),
+			Algorithm: alg,
+		}
+		return jwt.New(authCache, keeper, opts...), nil
+	case "ldap":
+		ldapCfg := ldap.Config{
+			Host:           cfg.LDAP.Host,
+			Port:           cfg.LDAP.Port,
+			BindDN:         cfg.LDAP.BindDN,
+			BindPassword:   cfg.LDAP.BindPassword,
+			BaseDN:         cfg.LDAP.BaseDN,
+			UserFilter:     cfg.LDAP.UserFilter,
+			UIDAttribute:   cfg.LDAP.UIDAttribute,
+			GroupAttribute: cfg.LDAP.GroupAttribute,
+			Attributes:     cfg.LDAP.Attributes,
+		}
+		if ldapCfg.UserFilter == "" {
+			ldapCfg.UserFilter = "(uid=%s)"
+		}
+		if cfg.LDAP.TLS {
+			ldapCfg.TLS = &tls.Config{ServerName: cfg.LDAP.Host}
+		}
+		return ldap.NewCached(&ldapCfg, authCache), nil
+	case "x509":
+		pool := certPool
+		if cfg.X509.CACertFile != "" {
+			pem, err := ioutil.ReadFile(cfg.X509.CACertFile)
+			if err != nil {
+				return nil, err
+			}
+			pool = x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, errors.Errorf("invalid ca file: %v", cfg.X509.CACertFile)
+			}
+		}
+		if pool == nil {
+			return nil, errors.New("ca file is required")
+		}
+		opts := []auth.Option{}
+		if len(cfg.X509.AllowedCN) > 0 {
+			opts = append(opts, x509strategy.SetAllowedCN(cfg.X509.AllowedCN...))
+		}
+		return x509strategy.New(x509.VerifyOptions{
+			Roots:     pool,
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}, opts...), nil
+	case "oauth2_introspection":
+		if cfg.OAuth2Introspection.Endpoint == "" {
+			return nil, errors.New("introspection endpoint is required")
+		}
+		opts := []auth.Option{
+			introspection.SetTLSConfig(&tls.Config{InsecureSkipVerify: cfg.OAuth2Introspection.InsecureSkipVerify}),
+		}
+		if cfg.OAuth2Introspection.ClientID != "" {
+			opts = append(opts, introspection.SetBasicAuth(cfg.OAuth2Introspection.ClientID, cfg.OAuth2Introspection.ClientSecret))
+		}
+		return introspection.New(cfg.OAuth2Introspection.Endpoint, authCache, opts...), nil
+	}
+	return nil, errors.Errorf("unknown auth strategy type: %v", cfg.Type)
+}
+
+func getUserID(u config.AuthUserConfig) string {
+	if u.ID != "" {
+		return u.ID
+	}
+	return u.Username
+}
+
+// authenticate the request, the returned request carries the identity in its context
+func (filter *StrategyAuthFilter) authenticate(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	info, err := filter.Strategy.Authenticate(r.Context(), r)
+	if err != nil || info == nil {
+		log.Debugf("failed to authenticate request [%v %v]: %v", r.Method, r.URL.Path, err)
+		if filter.Challenge != "" {
+			w.Header().Set("WWW-Authenticate", filter.Challenge)
+		}
+		DefaultAPI.WriteError(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return r, false
+	}
+	return withPermissionCheck(auth.RequestWithUser(info, r)), true
+}
+
+func (filter *StrategyAuthFilter) FilterHttpRouter(pattern string, h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		r, ok := filter.authenticate(w, r)
+		if ok {
+			h(w, r, ps)
+		}
+	}
+}
+
+func (filter *StrategyAuthFilter) FilterHttpHandlerFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r, ok := filter.authenticate(w, r)
+		if ok {
+			handler(w, r)
+		}
+	}
+}
+
+// GetUser return the identity authenticated by the api auth filter, nil if not authenticated
+func GetUser(r *http.Request) auth.Info {
+	return auth.User(r)
+}
+
+// authCache adapt the lru cache to the guardian cache interface
+type authCache struct {
+	cache *ccache.Cache
+	ttl   time.Duration
+}
+
+func newAuthCache(ttl time.Duration) *authCache {
+	return &authCache{cache: ccache.New(ccache.Configure().MaxSize(10000)), ttl: ttl}
+}
+
+func (c *authCache) Load(key interface{}) (interface{}, bool) {
+	item := c.cache.Get(fmt.Sprint(key))
+	if item == nil || item.Expired() {
+		return nil, false
+	}
+	return item.Value(), true
+}
+
+func (c *authCache) Store(key interface{}, value interface{}) {
+	c.cache.Set(fmt.Sprint(key), value, c.ttl)
+}
+
+func (c *authCache) StoreWithTTL(key interface{}, value interface{}, ttl time.Duration) {
+	c.cache.Set(fmt.Sprint(key), value, ttl)
+}
+
+func (c *authCache) Delete(key interface{}) {
+	c.cache.Delete(fmt.Sprint(key))
+}
@@ -0,0 +1,87 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package api
+
+import (
+	"github.com/rubyniu105/framework/core/api/router"
+	"github.com/rubyniu105/framework/core/config"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStrategyAuthFilter(t *testing.T) {
+	cfg := config.APISecurityConfig{Enabled: true, Username: "admin", Password: "secret"}
+	basicCfg := config.AuthStrategyConfig{Type: "basic"}
+	tokenCfg := config.AuthStrategyConfig{Type: "token"}
+	tokenCfg.Token.Tokens = []config.AuthUserConfig{{Token: "abc", Username: "bot", Groups: []string{"readonly"}}}
+	cfg.Strategies = []config.AuthStrategyConfig{basicCfg, tokenCfg}
+
+	filter, err := NewStrategyAuthFilter(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "Basic realm=Restricted", filter.Challenge)
+
+	var user string
+	h := filter.FilterHttpRouter("/", func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		user = GetUser(r).GetUserName()
+	})
+
+	r := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	h(w, r, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "Basic realm=Restricted", w.Header().Get("WWW-Authenticate"))
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.SetBasicAuth("admin", "secret")
+	w = httptest.NewRecorder()
+	h(w, r, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "admin", user)
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer abc")
+	w = httptest.NewRecorder()
+	h(w, r, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "bot", user)
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer invalid")
+	w = httptest.NewRecorder()
+	h(w, r, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestUnknownAuthStrategy(t *testing.T) {
+	cfg := config.APISecurityConfig{Enabled: true}
+	cfg.Strategies = []config.AuthStrategyConfig{{Type: "unknown"}}
+	_, err := NewAuthStrategy(cfg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,202 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package api
+
+import (
+	"context"
+	log "github.com/cihub/seelog"
+	httprouter "github.com/rubyniu105/framework/core/api/router"
+	"github.com/rubyniu105/framework/core/config"
+	"github.com/rubyniu105/framework/core/model"
+	"github.com/rubyniu105/framework/core/util"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Option is used to attach metadata to the registered api handler
+type Option func(o *HandlerOptions)
+
+// HandlerOptions stores the metadata of the registered api handler
+type HandlerOptions struct {
+	Permissions []string
+	//allow every authenticated user, no permission is required
+	Authenticated bool
+}
+
+// AdminPermission is required by the apis registered without any permission,
+// the routes are denied by default unless the permissions are declared
+const AdminPermission = "*"
+
+// RequirePermission only allow the users who have all the permissions to access the api,
+// permission is in the form of `resource:action`, eg: `queue:delete`
+func RequirePermission(permissions ...string) Option {
+	return func(o *HandlerOptions) {
+		o.Permissions = append(o.Permissions, permissions...)
+	}
+}
+
+// AllowAuthenticated allow all the authenticated users to access the api, eg: `/_whoami`
+func AllowAuthenticated() Option {
+	return func(o *HandlerOptions) {
+		o.Authenticated = true
+	}
+}
+
+// GetRequiredPermissions return the permissions to check, the admin permission is required when nothing declared
+func (o *HandlerOptions) GetRequiredPermissions() []string {
+	if o.Authenticated {
+		return nil
+	}
+	if len(o.Permissions) == 0 {
+		return []string{AdminPermission}
+	}
+	return o.Permissions
+}
+
+func newHandlerOptions(options ...Option) *HandlerOptions {
+	o := HandlerOptions{}
+	for _, f := range options {
+		f(&o)
+	}
+	return &o
+}
+
+var roles = map[string]model.Role{model.BuiltinAdminRole.Name: model.BuiltinAdminRole}
+var rolesLock sync.RWMutex
+
+type permissionCheckKey struct{}
+
+// permission checking only make sense when the requests are authenticated by strategies,
+// the api and web servers may have different security settings, so it is decided per request
+func withPermissionCheck(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), permissionCheckKey{}, true))
+}
+
+func permissionCheckRequired(r *http.Request) bool {
+	v, _ := r.Context().Value(permissionCheckKey{}).(bool)
+	return v
+}
+
+// RegisterRole register or replace the role with the same name
+func RegisterRole(role model.Role) {
+	rolesLock.Lock()
+	defer rolesLock.Unlock()
+	roles[role.Name] = role
+}
+
+// GetRole return the registered role by name
+func GetRole(name string) (model.Role, bool) {
+	rolesLock.RLock()
+	defer rolesLock.RUnlock()
+	role, ok := roles[name]
+	return role, ok
+}
+
+func registerConfiguredRoles(cfgs []config.RoleConfig) {
+	for _, v := range cfgs {
+		log.Debugf("register role [%v], permissions: %v", v.Name, v.Permissions)
+		RegisterRole(model.Role{Name: v.Name, Permissions: v.Permissions})
+	}
+}
+
+// GetPermissions return all the permissions granted to the roles
+func GetPermissions(roleNames []string) []string {
+	rolesLock.RLock()
+	defer rolesLock.RUnlock()
+	permissions := []string{}
+	for _, name := range roleNames {
+		if role, ok := roles[name]; ok {
+			permissions = append(permissions, role.Permissions...)
+		}
+	}
+	return permissions
+}
+
+// HasPermission check whether the granted permissions covers the required one,
+// `*` matches all the permissions, `queue:*` matches all the actions of queue
+func HasPermission(granted []string, required string) bool {
+	for _, v := range granted {
+		if v == "*" || v == required {
+			return true
+		}
+		if strings.HasSuffix(v, ":*") && strings.HasPrefix(required, strings.TrimSuffix(v, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+func getMissingPermissions(r *http.Request, required []string) []string {
+	user := GetUser(r)
+	if user == nil {
+		return required
+	}
+	granted := GetPermissions(user.GetGroups())
+	missing := []string{}
+	for _, v := range required {
+		if !HasPermission(granted, v) {
+			missing = append(missing, v)
+		}
+	}
+	return missing
+}
+
+func writeForbidden(w http.ResponseWriter, r *http.Request, required, missing []string) {
+	userName := ""
+	if user := GetUser(r); user != nil {
+		userName = user.GetUserName()
+	}
+	log.Debugf("user [%v] is not allowed to access [%v %v], missing permissions: %v", userName, r.Method, r.URL.Path, missing)
+	DefaultAPI.WriteJSON(w, util.MapStr{
+		"status": http.StatusForbidden,
+		"error": util.MapStr{
+			"type":                 "security_exception",
+			"reason":               "permission denied",
+			"user":                 userName,
+			"missing_permissions":  missing,
+			"required_permissions": required,
+		},
+	}, http.StatusForbidden)
+}
+
+// RequirePermissionHandler wrap the handler with the permission checking
+func RequirePermissionHandler(h httprouter.Handle, permissions ...string) httprouter.Handle {
+	if len(permissions) == 0 {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if permissionCheckRequired(r) {
+			if missing := getMissingPermissions(r, permissions); len(missing) > 0 {
+				writeForbidden(w, r, permissions, missing)
+				return
+			}
+		}
+		h(w, r, ps)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package api
+
+import (
+	"github.com/rubyniu105/framework/core/api/router"
+	"github.com/rubyniu105/framework/core/config"
+	"github.com/rubyniu105/framework/core/model"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHasPermission(t *testing.T) {
+	assert.True(t, HasPermission([]string{"*"}, "queue:delete"))
+	assert.True(t, HasPermission([]string{"queue:*"}, "queue:delete"))
+	assert.True(t, HasPermission([]string{"stats:read", "queue:read"}, "queue:read"))
+	assert.False(t, HasPermission([]string{"queue:read"}, "queue:delete"))
+	assert.False(t, HasPermission([]string{"queue:*"}, "queues:read"))
+	assert.False(t, HasPermission(nil, "queue:read"))
+}
+
+func TestRequirePermissionHandler(t *testing.T) {
+	RegisterRole(model.Role{Name: "oncall", Permissions: []string{"stats:read", "queue:read"}})
+
+	cfg := config.APISecurityConfig{Enabled: true}
+	tokenCfg := config.AuthStrategyConfig{Type: "token"}
+	tokenCfg.Token.Tokens = []config.AuthUserConfig{
+		{Token: "oncall", Username: "oncall", Groups: []string{"oncall"}},
+		{Token: "root", Username: "root", Groups: []string{"admin"}},
+	}
+	cfg.Strategies = []config.AuthStrategyConfig{tokenCfg}
+	filter, err := NewStrategyAuthFilter(cfg)
+	assert.NoError(t, err)
+
+	o := newHandlerOptions(RequirePermission("queue:delete"))
+	handler := RequirePermissionHandler(func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		w.WriteHeader(http.StatusOK)
+	}, o.Permissions...)
+	h := filter.FilterHttpRouter("/queue/:id", handler)
+
+	r := httptest.NewRequest("DELETE", "/queue/1", nil)
+	r.Header.Set("Authorization", "Bearer oncall")
+	w := httptest.NewRecorder()
+	h(w, r, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "queue:delete")
+
+	r = httptest.NewRequest("DELETE", "/queue/1", nil)
+	r.Header.Set("Authorization", "Bearer root")
+	w = httptest.NewRecorder()
+	h(w, r, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	//not checked on the server without auth strategies
+	r = httptest.NewRequest("DELETE", "/queue/1", nil)
+	w = httptest.NewRecorder()
+	handler(w, r, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestUnannotatedRoute(t *testing.T) {
+	RegisterRole(model.Role{Name: "oncall", Permissions: []string{"stats:read", "queue:*"}})
+
+	cfg := config.APISecurityConfig{Enabled: true}
+	tokenCfg := config.AuthStrategyConfig{Type: "token"}
+	tokenCfg.Token.Tokens = []config.AuthUserConfig{
+		{Token: "oncall", Username: "oncall", Groups: []string{"oncall"}},
+		{Token: "root", Username: "root", Groups: []string{"admin"}},
+	}
+	cfg.Strategies = []config.AuthStrategyConfig{tokenCfg}
+	filter, err := NewStrategyAuthFilter(cfg)
+	assert.NoError(t, err)
+
+	ok := func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		w.WriteHeader(http.StatusOK)
+	}
+	do := func(options *HandlerOptions, token string) int {
+		h := filter.FilterHttpRouter("/elasticsearch/metadata", RequirePermissionHandler(ok, options.GetRequiredPermissions()...))
+		r := httptest.NewRequest("GET", "/elasticsearch/metadata", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		h(w, r, nil)
+		return w.Code
+	}
+
+	//the routes without permissions are only allowed to the admin
+	assert.Equal(t, []string{AdminPermission}, newHandlerOptions().GetRequiredPermissions())
+	assert.Equal(t, http.StatusForbidden, do(newHandlerOptions(), "oncall"))
+	assert.Equal(t, http.StatusOK, do(newHandlerOptions(), "root"))
+
+	assert.Equal(t, http.StatusOK, do(newHandlerOptions(AllowAuthenticated()), "oncall"))
+	assert.Equal(t, http.StatusForbidden, do(newHandlerOptions(RequirePermission("elasticsearch:read")), "oncall"))
+	assert.Equal(t, http.StatusOK, do(newHandlerOptions(RequirePermission("queue:read")), "oncall"))
+}
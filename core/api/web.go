@@ -80,6 +80,16 @@ func StartWeb(cfg config.WebAppConfig) {
 		rw.WriteHeader(404)
 	})
 
+	var authFilter *StrategyAuthFilter
+	if cfg.Security.Enabled && len(cfg.Security.Strategies) > 0 {
+		var err error
+		authFilter, err = NewStrategyAuthFilter(cfg.Security)
+		if err != nil {
+			panic(err)
+		}
+		registerConfiguredRoles(cfg.Security.Roles)
+	}
+
 	//registered handlers
 	if registeredUIHandler != nil {
 		for k, v := range registeredUIHandler {
@@ -97,6 +107,9 @@ func StartWeb(cfg config.WebAppConfig) {
 		for k, v := range registeredUIMethodHandler {
 			for m, n := range v {
 				log.Debug("register http handler: ", k, " ", m)
+				if authFilter != nil {
+					n = authFilter.FilterHttpRouter(m, n)
+				}
 				uiRouter.Handle(k, m, n)
 			}
 		}
@@ -107,6 +120,9 @@ func StartWeb(cfg config.WebAppConfig) {
 			for k, v := range registeredAPIMethodHandler {
 				for m, n := range v {
 					log.Debug("register http handler: ", k, " ", m)
+					if authFilter != nil {
+						n = authFilter.FilterHttpRouter(m, n)
+					}
 					uiRouter.Handle(k, m, n)
 				}
 			}
@@ -114,6 +130,9 @@ func StartWeb(cfg config.WebAppConfig) {
 		if registeredAPIFuncHandler != nil {
 			for k, v := range registeredAPIFuncHandler {
 				log.Debug("register http handler: ", k)
+				if authFilter != nil {
+					v = authFilter.FilterHttpHandlerFunc(k, v)
+				}
 				uiServeMux.HandleFunc(k, v)
 			}
 		}
@@ -320,8 +339,11 @@ func HandleUI(pattern string, handler http.Handler) {
 	uiMutex.Unlock()
 }
 
-// HandleUIMethod register ui request handler
-func HandleUIMethod(method Method, pattern string, handler func(w http.ResponseWriter, req *http.Request, ps httprouter.Params)) {
+// HandleUIMethod register ui request handler, the api without RequirePermission or AllowAuthenticated is only allowed to the admin
+func HandleUIMethod(method Method, pattern string, handler func(w http.ResponseWriter, req *http.Request, ps httprouter.Params), options ...Option) {
+	o := newHandlerOptions(options...)
+	handler = RequirePermissionHandler(handler, o.GetRequiredPermissions()...)
+
 	uiMutex.Lock()
 	if registeredUIMethodHandler == nil {
 		registeredUIMethodHandler = map[string]map[string]func(w http.ResponseWriter, req *http.Request, ps httprouter.Params){}
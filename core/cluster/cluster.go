@@ -0,0 +1,123 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package cluster
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	NodeStatusAlive = "alive"
+	NodeStatusDead  = "dead"
+	//learned from other nodes, not contacted yet
+	NodeStatusDiscovered = "discovered"
+)
+
+type Node struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name,omitempty"`
+	Address   string            `json:"address"`             //rpc publish address
+	StartTime int64             `json:"start_time"`          //unix millis, the oldest alive node is the leader
+	Status    string            `json:"status,omitempty"`    //alive/dead/discovered
+	LastSeen  time.Time         `json:"last_seen,omitempty"` //last successful heartbeat in either direction
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+type EventType string
+
+const (
+	NodeJoined    EventType = "node_joined"
+	NodeLeft      EventType = "node_left"
+	LeaderChanged EventType = "leader_changed"
+)
+
+type MembershipEvent struct {
+	Type EventType `json:"type"`
+	Node Node      `json:"node"`
+	//for leader_changed, empty means no leader, eg: minimum_nodes is not met
+	LeaderID string `json:"leader_id,omitempty"`
+	Term     uint64 `json:"term"`
+}
+
+var listeners = []func(event *MembershipEvent){}
+var listenerLock = sync.RWMutex{}
+
+// RegisterMembershipChangeListener is notified when nodes join or leave and when the leader changes
+func RegisterMembershipChangeListener(l func(event *MembershipEvent)) {
+	listenerLock.Lock()
+	defer listenerLock.Unlock()
+	listeners = append(listeners, l)
+}
+
+func notify(event *MembershipEvent) {
+	listenerLock.RLock()
+	defer listenerLock.RUnlock()
+	for _, l := range listeners {
+		l(event)
+	}
+}
+
+var membership atomic.Pointer[Membership]
+
+// SetMembership registers the running membership, used by the package level helpers
+func SetMembership(m *Membership) {
+	membership.Store(m)
+}
+
+func GetMembership() *Membership {
+	return membership.Load()
+}
+
+// IsLeader returns true if the local node is the leader, false if cluster is not enabled or not formed
+func IsLeader() bool {
+	m := membership.Load()
+	if m == nil {
+		return false
+	}
+	return m.IsLeader()
+}
+
+// GetLeader returns the current leader, nil if no leader elected yet
+func GetLeader() *Node {
+	m := membership.Load()
+	if m == nil {
+		return nil
+	}
+	return m.GetLeader()
+}
+
+// GetNodes returns all known nodes including the local node
+func GetNodes() []Node {
+	m := membership.Load()
+	if m == nil {
+		return nil
+	}
+	return m.GetNodes()
+}
@@ -0,0 +1,399 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package cluster
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/rubyniu105/framework/core/config"
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/global"
+	"github.com/rubyniu105/framework/core/stats"
+)
+
+type HeartbeatRequest struct {
+	ClusterName string `json:"cluster_name"`
+	From        Node   `json:"from"`
+	//alive nodes known by the sender, used to discover the rest of the cluster
+	Nodes []Node `json:"nodes,omitempty"`
+}
+
+type HeartbeatResponse struct {
+	Node  Node   `json:"node"`
+	Nodes []Node `json:"nodes,omitempty"`
+}
+
+// Transport delivers heartbeats to other nodes
+type Transport interface {
+	Heartbeat(ctx context.Context, addr string, req *HeartbeatRequest) (*HeartbeatResponse, error)
+}
+
+type Membership struct {
+	cfg       config.ClusterConfig
+	local     Node
+	transport Transport
+
+	interval       time.Duration
+	requestTimeout time.Duration
+	failureTimeout time.Duration
+	removeTimeout  time.Duration
+
+	lock     sync.RWMutex
+	nodes    map[string]*Node  //node id: node, local node excluded
+	aliases  map[string]string //dialed address: node id
+	leaderID string
+	term     uint64
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+func NewMembership(cfg config.ClusterConfig, local Node, transport Transport) *Membership {
+	m := &Membership{
+		cfg:       cfg,
+		local:     local,
+		transport: transport,
+		nodes:     map[string]*Node{},
+		aliases:   map[string]string{},
+	}
+	m.local.Status = NodeStatusAlive
+
+	m.interval = time.Duration(cfg.HealthCheckInMilliseconds) * time.Millisecond
+	if m.interval <= 0 {
+		m.interval = 10 * time.Second
+	}
+	m.requestTimeout = time.Duration(cfg.DiscoveryTimeoutInMilliseconds) * time.Millisecond
+	if m.requestTimeout <= 0 {
+		m.requestTimeout = 10 * time.Second
+	}
+	//a node is dead after missing three rounds
+	m.failureTimeout = 3 * m.interval
+	if m.failureTimeout < m.interval+m.requestTimeout {
+		m.failureTimeout = m.interval + m.requestTimeout
+	}
+	m.removeTimeout = 10 * m.failureTimeout
+
+	//the election has no quorum of its own, only minimum_nodes keeps both sides of a partition from electing a leader
+	expected := len(cfg.GetSeeds()) + 1
+	if cfg.MinimumNodes <= expected/2 {
+		log.Warnf("minimum_nodes [%v] of cluster [%v] is not a majority of the %v configured nodes, a network partition may elect more than one leader", cfg.MinimumNodes, cfg.Name, expected)
+	}
+	return m
+}
+
+func (m *Membership) Start() {
+	m.quit = make(chan struct{})
+	m.done = make(chan struct{})
+	go func() {
+		defer close(m.done)
+		defer func() {
+			if !global.Env().IsDebug {
+				if r := recover(); r != nil {
+					log.Error("error in cluster membership,", r)
+				}
+			}
+		}()
+
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		m.round()
+		for {
+			select {
+			case <-m.quit:
+				return
+			case <-ticker.C:
+				m.round()
+			}
+		}
+	}()
+}
+
+func (m *Membership) Stop() {
+	if m.quit == nil {
+		return
+	}
+	close(m.quit)
+	<-m.done
+	m.quit = nil
+}
+
+// HandleHeartbeat serves heartbeat from other nodes
+func (m *Membership) HandleHeartbeat(req *HeartbeatRequest) (*HeartbeatResponse, error) {
+	if req.ClusterName != m.cfg.Name {
+		return nil, errors.Errorf("cluster name mismatch, expect [%v], got [%v] from node [%v]", m.cfg.Name, req.ClusterName, req.From.ID)
+	}
+	if req.From.ID == "" {
+		return nil, errors.New("node id can't be empty")
+	}
+
+	stats.Increment("cluster", "heartbeat_received")
+
+	m.lock.Lock()
+	events := m.touch(req.From)
+	m.discover(req.Nodes)
+	events = append(events, m.refresh()...)
+	resp := &HeartbeatResponse{Node: m.local, Nodes: m.aliveNodes()}
+	m.lock.Unlock()
+
+	m.notify(events)
+	return resp, nil
+}
+
+// round sends one heartbeat to every known node and the seeds
+func (m *Membership) round() {
+	m.lock.RLock()
+	req := &HeartbeatRequest{ClusterName: m.cfg.Name, From: m.local, Nodes: m.aliveNodes()}
+	targets := m.targets()
+	m.lock.RUnlock()
+
+	wg := sync.WaitGroup{}
+	for _, addr := range targets {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), m.requestTimeout)
+			defer cancel()
+			resp, err := m.transport.Heartbeat(ctx, addr, req)
+			if err != nil {
+				stats.Increment("cluster", "heartbeat_failed")
+				log.Debugf("failed to send heartbeat to [%v]: %v", addr, err)
+				return
+			}
+			stats.Increment("cluster", "heartbeat_sent")
+
+			m.lock.Lock()
+			m.aliases[addr] = resp.Node.ID
+			events := m.touch(resp.Node)
+			m.discover(resp.Nodes)
+			m.lock.Unlock()
+			m.notify(events)
+		}(addr)
+	}
+	wg.Wait()
+
+	m.lock.Lock()
+	events := m.refresh()
+	m.lock.Unlock()
+	m.notify(events)
+}
+
+func (m *Membership) targets() []string {
+	addrs := map[string]struct{}{}
+	for _, n := range m.nodes {
+		if n.Address != "" {
+			addrs[n.Address] = struct{}{}
+		}
+	}
+	for _, seed := range m.cfg.GetSeeds() {
+		if id, ok := m.aliases[seed]; ok {
+			//the seed is ourself, or reachable by its own address
+			if id == m.local.ID {
+				continue
+			}
+			if n, ok := m.nodes[id]; ok && n.Address != "" {
+				continue
+			}
+		}
+		addrs[seed] = struct{}{}
+	}
+	delete(addrs, m.local.Address)
+
+	targets := make([]string, 0, len(addrs))
+	for addr := range addrs {
+		targets = append(targets, addr)
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// touch marks the node alive, must be called with lock held
+func (m *Membership) touch(node Node) []*MembershipEvent {
+	if node.ID == "" || node.ID == m.local.ID {
+		return nil
+	}
+	node.LastSeen = time.Now()
+	node.Status = NodeStatusAlive
+
+	n, ok := m.nodes[node.ID]
+	if ok && n.Status == NodeStatusAlive {
+		*n = node
+		return nil
+	}
+	m.nodes[node.ID] = &node
+	log.Infof("node [%v] joined cluster [%v], address: %v", node.ID, m.cfg.Name, node.Address)
+	return []*MembershipEvent{{Type: NodeJoined, Node: node, Term: m.term}}
+}
+
+// discover adds nodes learned from others, they will be contacted in the next round
+func (m *Membership) discover(nodes []Node) {
+	for _, node := range nodes {
+		if node.ID == "" || node.ID == m.local.ID || node.Address == "" {
+			continue
+		}
+		if _, ok := m.nodes[node.ID]; ok {
+			continue
+		}
+		node.Status = NodeStatusDiscovered
+		node.LastSeen = time.Now()
+		m.nodes[node.ID] = &node
+	}
+}
+
+// refresh expires silent nodes and re-elects the leader, must be called with lock held
+func (m *Membership) refresh() []*MembershipEvent {
+	var events []*MembershipEvent
+	now := time.Now()
+	for id, n := range m.nodes {
+		elapsed := now.Sub(n.LastSeen)
+		switch n.Status {
+		case NodeStatusAlive:
+			if elapsed > m.failureTimeout {
+				n.Status = NodeStatusDead
+				log.Warnf("node [%v] left cluster [%v], last seen: %v", n.ID, m.cfg.Name, n.LastSeen)
+				events = append(events, &MembershipEvent{Type: NodeLeft, Node: *n, Term: m.term})
+			}
+		case NodeStatusDiscovered:
+			if elapsed > m.failureTimeout {
+				delete(m.nodes, id)
+			}
+		default:
+			if elapsed > m.removeTimeout {
+				delete(m.nodes, id)
+			}
+		}
+	}
+
+	leaderID := m.elect()
+	if leaderID != m.leaderID {
+		m.leaderID = leaderID
+		m.term++
+		event := &MembershipEvent{Type: LeaderChanged, LeaderID: leaderID, Term: m.term}
+		if leader := m.getNode(leaderID); leader != nil {
+			event.Node = *leader
+		}
+		if leaderID == "" {
+			log.Warnf("no leader in cluster [%v], minimum_nodes: %v", m.cfg.Name, m.cfg.MinimumNodes)
+		} else {
+			log.Infof("node [%v] was elected as the leader of cluster [%v], term: %v", leaderID, m.cfg.Name, m.term)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// elect picks the oldest alive node, so a restarted or newly joined node never takes over a running leader.
+// every node decides on its own view and there is no quorum besides minimum_nodes, so minimum_nodes must be
+// greater than half of the cluster size, otherwise each side of a network partition elects its own leader.
+func (m *Membership) elect() string {
+	alive := m.aliveNodes()
+	alive = append(alive, m.local)
+	if len(alive) < m.cfg.MinimumNodes {
+		return ""
+	}
+	leader := alive[0]
+	for _, n := range alive[1:] {
+		if n.StartTime < leader.StartTime || (n.StartTime == leader.StartTime && n.ID < leader.ID) {
+			leader = n
+		}
+	}
+	return leader.ID
+}
+
+func (m *Membership) aliveNodes() []Node {
+	nodes := []Node{}
+	for _, n := range m.nodes {
+		if n.Status == NodeStatusAlive {
+			nodes = append(nodes, *n)
+		}
+	}
+	return nodes
+}
+
+func (m *Membership) getNode(id string) *Node {
+	if id == m.local.ID {
+		return &m.local
+	}
+	return m.nodes[id]
+}
+
+func (m *Membership) notify(events []*MembershipEvent) {
+	for _, e := range events {
+		notify(e)
+	}
+}
+
+// SetLocalAddress updates the published rpc address, must be called before Start
+func (m *Membership) SetLocalAddress(addr string) {
+	m.local.Address = addr
+}
+
+func (m *Membership) GetLocalNode() Node {
+	return m.local
+}
+
+func (m *Membership) IsLeader() bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.leaderID != "" && m.leaderID == m.local.ID
+}
+
+func (m *Membership) GetLeader() *Node {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	n := m.getNode(m.leaderID)
+	if n == nil {
+		return nil
+	}
+	v := *n
+	return &v
+}
+
+func (m *Membership) GetTerm() uint64 {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.term
+}
+
+// GetNodes returns all known nodes including the local node, sorted by id
+func (m *Membership) GetNodes() []Node {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	nodes := []Node{m.local}
+	for _, n := range m.nodes {
+		nodes = append(nodes, *n)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID < nodes[j].ID
+	})
+	return nodes
+}
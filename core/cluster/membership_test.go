@@ -0,0 +1,188 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package cluster
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rubyniu105/framework/core/config"
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryTransport struct {
+	lock    sync.RWMutex
+	members map[string]*Membership
+	down    map[string]bool
+}
+
+func (t *memoryTransport) Heartbeat(ctx context.Context, addr string, req *HeartbeatRequest) (*HeartbeatResponse, error) {
+	t.lock.RLock()
+	m, ok := t.members[addr]
+	down := t.down[addr]
+	t.lock.RUnlock()
+	if !ok || down {
+		return nil, errors.Errorf("node [%v] unreachable", addr)
+	}
+	return m.HandleHeartbeat(req)
+}
+
+func newTestCluster(minimumNodes int, ids ...string) (*memoryTransport, []*Membership) {
+	t := &memoryTransport{members: map[string]*Membership{}, down: map[string]bool{}}
+	var members []*Membership
+	for i, id := range ids {
+		cfg := config.ClusterConfig{
+			Name:                           "test",
+			MinimumNodes:                   minimumNodes,
+			HealthCheckInMilliseconds:      10,
+			DiscoveryTimeoutInMilliseconds: 10,
+			RPCConfig:                      config.RPCConfig{NetworkConfig: config.NetworkConfig{Binding: id + ":10000"}},
+		}
+		//everyone only knows the first node
+		if i > 0 {
+			cfg.Seeds = []string{ids[0]}
+		}
+		m := NewMembership(cfg, Node{ID: id, Address: id, StartTime: int64(i + 1)}, t)
+		t.members[id] = m
+		members = append(members, m)
+	}
+	return t, members
+}
+
+func rounds(members []*Membership, n int) {
+	for i := 0; i < n; i++ {
+		for _, m := range members {
+			m.round()
+		}
+	}
+}
+
+func TestMembershipJoinAndElect(t *testing.T) {
+	var events []*MembershipEvent
+	lock := sync.Mutex{}
+	RegisterMembershipChangeListener(func(event *MembershipEvent) {
+		lock.Lock()
+		defer lock.Unlock()
+		events = append(events, event)
+	})
+
+	_, members := newTestCluster(3, "a", "b", "c")
+
+	//b and c only know the seed, they find each other through a
+	rounds(members, 3)
+	for _, m := range members {
+		nodes := m.GetNodes()
+		assert.Equal(t, 3, len(nodes))
+		for _, n := range nodes {
+			assert.Equal(t, NodeStatusAlive, n.Status, n.ID)
+		}
+		assert.Equal(t, "a", m.GetLeader().ID)
+	}
+	assert.True(t, members[0].IsLeader())
+	assert.False(t, members[1].IsLeader())
+
+	lock.Lock()
+	var joined, elected int
+	for _, e := range events {
+		switch e.Type {
+		case NodeJoined:
+			joined++
+		case LeaderChanged:
+			elected++
+		}
+	}
+	lock.Unlock()
+	assert.Equal(t, 6, joined)
+	assert.Equal(t, 3, elected)
+}
+
+func TestMembershipFailover(t *testing.T) {
+	transport, members := newTestCluster(2, "a", "b", "c")
+	rounds(members, 3)
+	assert.Equal(t, "a", members[1].GetLeader().ID)
+
+	transport.lock.Lock()
+	transport.down["a"] = true
+	transport.lock.Unlock()
+
+	time.Sleep(2 * members[1].failureTimeout)
+	rounds(members[1:], 1)
+
+	for _, m := range members[1:] {
+		assert.Equal(t, "b", m.GetLeader().ID)
+		for _, n := range m.GetNodes() {
+			if n.ID == "a" {
+				assert.Equal(t, NodeStatusDead, n.Status)
+			}
+		}
+	}
+	assert.True(t, members[1].IsLeader())
+
+	//a node can't elect itself without enough nodes
+	transport.lock.Lock()
+	transport.down["c"] = true
+	transport.lock.Unlock()
+	time.Sleep(2 * members[1].failureTimeout)
+	rounds(members[1:2], 1)
+	assert.Nil(t, members[1].GetLeader())
+	assert.False(t, members[1].IsLeader())
+}
+
+func TestMembershipClusterNameMismatch(t *testing.T) {
+	_, members := newTestCluster(1, "a")
+	_, err := members[0].HandleHeartbeat(&HeartbeatRequest{ClusterName: "other", From: Node{ID: "x", Address: "x"}})
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, len(members[0].GetNodes()))
+}
+
+func TestRPCTransport(t *testing.T) {
+	rpc.Setup(&config.RPCConfig{})
+	_, members := newTestCluster(1, "a")
+	RegisterRPCService(rpc.GetRPCServer(), members[0])
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go rpc.GetRPCServer().Serve(listener)
+	defer rpc.GetRPCServer().Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	transport := &RPCTransport{}
+	resp, err := transport.Heartbeat(ctx, listener.Addr().String(), &HeartbeatRequest{
+		ClusterName: "test",
+		From:        Node{ID: "remote", Address: "remote:10000", StartTime: 100},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "a", resp.Node.ID)
+	assert.Equal(t, 2, len(members[0].GetNodes()))
+}
@@ -0,0 +1,125 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package cluster
+
+import (
+	"context"
+
+	"github.com/rubyniu105/framework/core/rpc"
+	"github.com/rubyniu105/framework/core/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// the membership service has no generated protobuf code, messages are exchanged as json
+const codecName = "json"
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return util.ToJSONBytes(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return util.FromJSONBytes(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return codecName
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+const heartbeatMethod = "/cluster.Membership/Heartbeat"
+
+type membershipServer interface {
+	Heartbeat(ctx context.Context, req *HeartbeatRequest) (*HeartbeatResponse, error)
+}
+
+var membershipServiceDesc = grpc.ServiceDesc{
+	ServiceName: "cluster.Membership",
+	HandlerType: (*membershipServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Heartbeat",
+			Handler:    heartbeatHandler,
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+func heartbeatHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HeartbeatRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(membershipServer).Heartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: heartbeatMethod,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(membershipServer).Heartbeat(ctx, req.(*HeartbeatRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+type rpcServer struct {
+	membership *Membership
+}
+
+func (s *rpcServer) Heartbeat(ctx context.Context, req *HeartbeatRequest) (*HeartbeatResponse, error) {
+	return s.membership.HandleHeartbeat(req)
+}
+
+// RegisterRPCService exposes the membership on the grpc server, must be called before the server starts
+func RegisterRPCService(s *grpc.Server, m *Membership) {
+	s.RegisterService(&membershipServiceDesc, &rpcServer{membership: m})
+}
+
+// RPCTransport sends heartbeats over core/rpc connections
+type RPCTransport struct{}
+
+func (t *RPCTransport) Heartbeat(ctx context.Context, addr string, req *HeartbeatRequest) (*HeartbeatResponse, error) {
+	conn, err := rpc.ObtainConnection(addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	resp := &HeartbeatResponse{}
+	err = conn.Invoke(ctx, heartbeatMethod, req, resp, grpc.CallContentSubtype(codecName))
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -43,10 +43,20 @@ package config
 type LoggingConfig struct {
 	DisableFileOutput bool     `json:"disable_file_output" config:"disable_file_output"`
 	LogLevel             string `json:"level" config:"level"`
+	//seelog format string, or `json` for structured output
 	LogFormat			 string `json:"format" config:"format"`
+	//levels of each sink, default to level
+	ConsoleLogLevel string `json:"console_level,omitempty" config:"console_level"`
+	FileLogLevel    string `json:"file_level,omitempty" config:"file_level"`
+
+	Rotate LogRotateConfig `json:"rotate,omitempty" config:"rotate"`
+	//static key/values attached to every json log
+	Fields map[string]string `json:"fields,omitempty" config:"fields"`
+	//levels for matched packages or files, the first matched one wins
+	Overrides []LogLevelOverride `json:"overrides,omitempty" config:"overrides"`
 
 	RealtimePushEnabled  bool   `json:"realtime"`
-	PushLogLevel         string `json:"push_log_level"`
+	PushLogLevel         string `json:"push_log_level" config:"push_log_level"`
 	FuncFilterPattern    string `json:"func_pattern"`
 	FileFilterPattern    string `json:"file_pattern"`
 	MessageFilterPattern string `json:"message_pattern"`
@@ -54,3 +64,20 @@ type LoggingConfig struct {
 
 	IsDebug              bool   `json:"debug"  config:"debug"`
 }
+
+// LogLevelOverride sets the log level of a package or of the files matched by a pattern
+type LogLevelOverride struct {
+	//package path, eg: modules/pipeline, sub packages are included
+	Package string `json:"package,omitempty" config:"package"`
+	//pattern of the source file path, eg: */elastic/bulk_processor.go
+	File  string `json:"file,omitempty" config:"file"`
+	Level string `json:"level" config:"level"`
+}
+
+// LogRotateConfig controls the rotation of log files
+type LogRotateConfig struct {
+	MaxFileSizeInMB  int   `json:"max_file_size_in_mb,omitempty" config:"max_file_size_in_mb"`
+	MaxFileCount     int   `json:"max_file_count,omitempty" config:"max_file_count"`
+	MaxFileAgeInDays int   `json:"max_file_age_in_days,omitempty" config:"max_file_age_in_days"`
+	Compress         *bool `json:"compress,omitempty" config:"compress"`
+}
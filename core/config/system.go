@@ -34,7 +34,7 @@ import (
 type ClusterConfig struct {
 	Enabled                        bool          `config:"enabled"`
 	Name                           string        `config:"name"`
-	MinimumNodes                   int           `config:"minimum_nodes"`
+	MinimumNodes                   int           `config:"minimum_nodes"` //should be greater than half of the cluster size to avoid split-brain
 	Seeds                          []string      `config:"seeds"`
 	RPCConfig                      RPCConfig     `config:"rpc"`
 	BoradcastConfig                NetworkConfig `config:"broadcast"`
@@ -211,7 +211,9 @@ type ConfigsConfig struct {
 	ValidConfigsExtensions     []string  `config:"valid_config_extensions"`
 	TLSConfig                  TLSConfig `config:"tls"` //server or client's certs
 	ManagerConfig              struct {
+		Enabled              bool   `config:"enabled"` //serve configs to managed instances
 		LocalConfigsRepoPath string `config:"local_configs_repo_path"`
+		ReloadInterval       string `config:"reload_interval"` //interval to check the changes of the repo
 	} `config:"manager"`
 	AlwaysRegisterAfterRestart bool     `config:"always_register_after_restart"`
 	AllowGeneratedMetricsTasks bool     `config:"allow_generated_metrics_tasks"`
@@ -234,6 +236,76 @@ type APISecurityConfig struct {
 	Enabled  bool   `config:"enabled"`
 	Username string `json:"username,omitempty" config:"username" elastic_mapping:"username:{type:keyword}"`
 	Password string `json:"password,omitempty" config:"password" elastic_mapping:"password:{type:keyword}"`
+
+	//chained authentication strategies, the first strategy authenticated the request wins
+	Strategies []AuthStrategyConfig `config:"strategies"`
+
+	//role to permissions mapping, user's groups are used as roles
+	Roles []RoleConfig `config:"roles"`
+}
+
+type RoleConfig struct {
+	Name        string   `config:"name"`
+	Permissions []string `config:"permissions"`
+}
+
+// AuthStrategyConfig stores the settings of one api authentication strategy
+type AuthStrategyConfig struct {
+	Type     string `config:"type"` //basic, token, jwt, ldap, x509, oauth2_introspection
+	CacheTTL string `config:"cache_ttl"`
+
+	Basic struct {
+		Users []AuthUserConfig `config:"users"`
+	} `config:"basic"`
+
+	Token struct {
+		File   string           `config:"file"` //csv records: `token,username,userid,"group1,group2"`
+		Header string           `config:"header"`
+		Query  string           `config:"query"`
+		Tokens []AuthUserConfig `config:"tokens"`
+	} `config:"token"`
+
+	JWT struct {
+		Secret    string `config:"secret"`
+		KeyID     string `config:"kid"`
+		Algorithm string `config:"algorithm"`
+		Issuer    string `config:"issuer"`
+		Audience  string `config:"audience"`
+	} `config:"jwt"`
+
+	LDAP struct {
+		Host           string   `config:"host"`
+		Port           int      `config:"port"`
+		TLS            bool     `config:"tls"`
+		BindDN         string   `config:"bind_dn"`
+		BindPassword   string   `config:"bind_password"`
+		BaseDN         string   `config:"base_dn"`
+		UserFilter     string   `config:"user_filter"`
+		UIDAttribute   string   `config:"uid_attribute"`
+		GroupAttribute string   `config:"group_attribute"`
+		Attributes     []string `config:"attributes"`
+	} `config:"ldap"`
+
+	X509 struct {
+		CACertFile string   `config:"ca_file"`
+		AllowedCN  []string `config:"allowed_cn"`
+	} `config:"x509"`
+
+	OAuth2Introspection struct {
+		Endpoint           string `config:"endpoint"`
+		ClientID           string `config:"client_id"`
+		ClientSecret       string `config:"client_secret"`
+		InsecureSkipVerify bool   `config:"skip_insecure_verify"`
+	} `config:"oauth2_introspection"`
+}
+
+// AuthUserConfig stores a static identity used by the basic and token strategies
+type AuthUserConfig struct {
+	Username string   `config:"username"`
+	Password string   `config:"password"`
+	Token    string   `config:"token"`
+	ID       string   `config:"id"`
+	Groups   []string `config:"groups"`
 }
 
 type WebAppConfig struct {
@@ -29,6 +29,8 @@ package credential
 
 import (
 	"fmt"
+	"time"
+
 	"github.com/rubyniu105/framework/core/model"
 	"github.com/rubyniu105/framework/core/orm"
 )
@@ -45,7 +47,15 @@ type Credential struct {
 	} `json:"encrypt" elastic_mapping:"encrypt:{type:object,enabled:false}"`
 	SearchText string `json:"search_text,omitempty" elastic_mapping:"search_text:{type:text,index_prefixes:{},index_phrases:true, analyzer:suggest_text_search }"`
 	secret     []byte
-	Invalid    bool `json:"invalid" elastic_mapping:"invalid:{type:boolean}"`
+	Invalid    bool            `json:"invalid" elastic_mapping:"invalid:{type:boolean}"`
+	Rotation   *RotationConfig `json:"rotation,omitempty" elastic_mapping:"rotation:{type:object}"`
+}
+
+type RotationConfig struct {
+	Enabled     bool       `json:"enabled"`
+	Interval    string     `json:"interval,omitempty"` //eg: 90d
+	LastRotated *time.Time `json:"last_rotated,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
 }
 
 func (cred *Credential) SetSecret(secret []byte) {
@@ -66,12 +76,7 @@ func (cred *Credential) Validate() error {
 }
 
 func (cred *Credential) Encode() error {
-	switch cred.Type {
-	case BasicAuth:
-		return encodeBasicAuth(cred)
-	default:
-		return fmt.Errorf("unkonow credential type [%s]", cred.Type)
-	}
+	return encodeSecret(cred)
 }
 func (cred *Credential) DecodeBasicAuth() (*model.BasicAuth, error) {
 	var dv interface{}
@@ -90,11 +95,20 @@ func (cred *Credential) Decode() (interface{}, error) {
 	switch cred.Type {
 	case BasicAuth:
 		return decodeBasicAuth(cred)
+	case APIKey:
+		return decodeAPIKey(cred)
+	case BearerToken:
+		return decodeBearerToken(cred)
+	case ClientCert:
+		return decodeClientCert(cred)
 	default:
 		return nil, fmt.Errorf("unkonow credential type [%s]", cred.Type)
 	}
 }
 
 const (
-	BasicAuth string = "basic_auth"
+	BasicAuth   string = "basic_auth"
+	APIKey      string = "api_key"      //payload: id, key
+	BearerToken string = "bearer_token" //payload: token
+	ClientCert  string = "client_cert"  //payload: cert, key, ca, pem encoded
 )
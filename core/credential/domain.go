@@ -38,6 +38,9 @@ import (
 
 const SecretKey = "credential_secret"
 
+// PreviousSecretKey keeps the master secret before rotation, used to decode the credentials not re-encrypted yet
+const PreviousSecretKey = "credential_secret_previous"
+
 func GetOrInitSecret() ([]byte, error) {
 	ks, err := keystore.GetOrInitKeystore()
 	if err != nil {
@@ -85,78 +88,157 @@ func InitSecret(ks keystore2.Keystore, secret []byte) error {
 	return nil
 }
 
-func encodeBasicAuth(cred *Credential) error {
-	var (
-		params map[string]interface{}
-		ok     bool
-		pwd    string
-	)
-	if params, ok = cred.Payload[cred.Type].(map[string]interface{}); !ok {
-		return fmt.Errorf("wrong credential parameters for type [%s], expect a map", cred.Type)
+// the field of payload to be encrypted, for each credential type
+var secretFields = map[string]string{
+	BasicAuth:   "password",
+	APIKey:      "key",
+	BearerToken: "token",
+	ClientCert:  "key",
+}
+
+func getParams(cred *Credential) (map[string]interface{}, error) {
+	params, ok := cred.Payload[cred.Type].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("wrong credential parameters for type [%s], expect a map", cred.Type)
+	}
+	return params, nil
+}
+
+func getStringParam(cred *Credential, params map[string]interface{}, field string) (string, error) {
+	v, ok := params[field].(string)
+	if !ok {
+		return "", fmt.Errorf("wrong credential parameters %s for type [%s], expect a string", field, cred.Type)
 	}
-	if pwd, ok = params["password"].(string); !ok {
-		return fmt.Errorf("wrong credential parameters password for type [%s], expect a string", cred.Type)
+	if v == "" {
+		return "", fmt.Errorf("credential parameters %s can not be empty", field)
+	}
+	return v, nil
+}
+
+func encodeSecret(cred *Credential) error {
+	field, ok := secretFields[cred.Type]
+	if !ok {
+		return fmt.Errorf("unkonow credential type [%s]", cred.Type)
+	}
+	params, err := getParams(cred)
+	if err != nil {
+		return err
 	}
-	if pwd == "" {
-		return fmt.Errorf("credential parameters password can not be empty")
+	value, err := getStringParam(cred, params, field)
+	if err != nil {
+		return err
 	}
 	secret, err := GetOrInitSecret()
 	if err != nil {
 		return err
 	}
-	encodeBytes, salt, err := util.AesGcmEncrypt([]byte(pwd), secret)
+	encodeBytes, salt, err := util.AesGcmEncrypt([]byte(value), secret)
 	if err != nil {
-		return fmt.Errorf("encrypt password error: %w", err)
+		return fmt.Errorf("encrypt %s error: %w", field, err)
 	}
 	cred.Encrypt.Type = "AES"
 	cred.Encrypt.Params = map[string]interface{}{
 		"salt": string(salt),
 	}
-	params["password"] = string(encodeBytes)
+	params[field] = string(encodeBytes)
 	cred.Payload[cred.Type] = params
 	return nil
 }
 
-func decodeBasicAuth(cred *Credential) (basicAuth model.BasicAuth, err error) {
-	var (
-		params map[string]interface{}
-		ok     bool
-		pwd    string
-		salt   string
-	)
-	if params, ok = cred.Payload[cred.Type].(map[string]interface{}); !ok {
-		err = fmt.Errorf("wrong credential parameters for type [%s], expect a map", cred.Type)
-		return
+func decodeSecret(cred *Credential) (params map[string]interface{}, plaintext []byte, err error) {
+	field, ok := secretFields[cred.Type]
+	if !ok {
+		return nil, nil, fmt.Errorf("unkonow credential type [%s]", cred.Type)
 	}
-	if pwd, ok = params["password"].(string); !ok {
-		err = fmt.Errorf("wrong credential parameters password for type [%s], expect a string", cred.Type)
+	if params, err = getParams(cred); err != nil {
 		return
 	}
-	if pwd == "" {
-		err = fmt.Errorf("credential parameters password can not be empty")
+	value, err := getStringParam(cred, params, field)
+	if err != nil {
 		return
 	}
-	if salt, ok = cred.Encrypt.Params["salt"].(string); !ok {
+	salt, ok := cred.Encrypt.Params["salt"].(string)
+	if !ok {
 		err = fmt.Errorf("credential encrypt parameters salt can not be empty")
 		return
 	}
 	var secret = cred.secret
-	if secret == nil {
-		secret, err = GetOrInitSecret()
-		if err != nil {
-			return basicAuth, err
+	if secret != nil {
+		plaintext, err = util.AesGcmDecrypt([]byte(value), secret, []byte(salt))
+		return
+	}
+	secret, err = GetOrInitSecret()
+	if err != nil {
+		return
+	}
+	plaintext, err = util.AesGcmDecrypt([]byte(value), secret, []byte(salt))
+	if err != nil {
+		//encrypted before the master secret was rotated
+		if previous, err1 := keystore.GetValue(PreviousSecretKey); err1 == nil && len(previous) > 0 {
+			if v, err1 := util.AesGcmDecrypt([]byte(value), previous, []byte(salt)); err1 == nil {
+				return params, v, nil
+			}
 		}
 	}
+	return
+}
+
+// decryptedParams returns a copy of the parameters with the secret decrypted
+func (cred *Credential) decryptedParams() (map[string]interface{}, error) {
+	params, plaintext, err := decodeSecret(cred)
+	if err != nil {
+		return nil, err
+	}
+	output := map[string]interface{}{}
+	for k, v := range params {
+		output[k] = v
+	}
+	output[secretFields[cred.Type]] = string(plaintext)
+	return output, nil
+}
 
-	plaintext, err := util.AesGcmDecrypt([]byte(pwd), secret, []byte(salt))
+func decodeBasicAuth(cred *Credential) (basicAuth model.BasicAuth, err error) {
+	params, plaintext, err := decodeSecret(cred)
 	if err != nil {
 		return basicAuth, err
 	}
-	basicAuth.Username = params["username"].(string)
+	basicAuth.Username, _ = params["username"].(string)
 	basicAuth.Password = ucfg.SecretString(plaintext)
 	return
 }
 
+func decodeAPIKey(cred *Credential) (apiKey model.APIKey, err error) {
+	params, plaintext, err := decodeSecret(cred)
+	if err != nil {
+		return apiKey, err
+	}
+	apiKey.ID, _ = params["id"].(string)
+	apiKey.Key = ucfg.SecretString(plaintext)
+	return
+}
+
+func decodeBearerToken(cred *Credential) (ucfg.SecretString, error) {
+	_, plaintext, err := decodeSecret(cred)
+	if err != nil {
+		return "", err
+	}
+	return ucfg.SecretString(plaintext), nil
+}
+
+func decodeClientCert(cred *Credential) (cert model.ClientCert, err error) {
+	params, plaintext, err := decodeSecret(cred)
+	if err != nil {
+		return cert, err
+	}
+	if cert.Cert, err = getStringParam(cred, params, "cert"); err != nil {
+		return cert, err
+	}
+	cert.CA, _ = params["ca"].(string)
+	cert.SkipDomainVerify, _ = params["skip_domain_verify"].(bool)
+	cert.Key = ucfg.SecretString(plaintext)
+	return
+}
+
 type ChangeEvent func(credentials *Credential)
 
 var changeEvents []ChangeEvent
@@ -0,0 +1,292 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI Ltd. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package credential
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/rubyniu105/framework/core/cluster"
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/keystore"
+	"github.com/rubyniu105/framework/core/orm"
+	"github.com/rubyniu105/framework/core/util"
+)
+
+const defaultRotationInterval = 90 * 24 * time.Hour
+
+// Rotator issues a new secret for the credential in the remote system,
+// params are decrypted and should be updated with the new secret in place
+type Rotator func(cred *Credential, params map[string]interface{}) error
+
+// Rollback restores the previous secret in the remote system when the rotated credential failed to be saved,
+// oldParams are the decrypted params before rotation and newParams are the ones issued by the rotator
+type Rollback func(cred *Credential, oldParams, newParams map[string]interface{}) error
+
+type rotatorEntry struct {
+	rotator  Rotator
+	rollback Rollback
+}
+
+var rotators = map[string]rotatorEntry{}
+var rotatorLock = sync.RWMutex{}
+
+// RegisterRotator registers the rotator of the credential type, the rollback is required,
+// as the secret is changed in the remote system before the credential is saved
+func RegisterRotator(credType string, rotator Rotator, rollback Rollback) {
+	rotatorLock.Lock()
+	defer rotatorLock.Unlock()
+	rotators[credType] = rotatorEntry{rotator: rotator, rollback: rollback}
+}
+
+func getRotator(credType string) (Rotator, Rollback) {
+	rotatorLock.RLock()
+	defer rotatorLock.RUnlock()
+	entry := rotators[credType]
+	return entry.rotator, entry.rollback
+}
+
+// IsRotationDue tells whether the credential should be rotated now
+func (cred *Credential) IsRotationDue(now time.Time) bool {
+	if cred.Rotation == nil || !cred.Rotation.Enabled {
+		return false
+	}
+	interval, err := util.ParseDuration(cred.Rotation.Interval)
+	if err != nil || interval <= 0 {
+		interval = defaultRotationInterval
+	}
+	last := cred.Created
+	if cred.Rotation.LastRotated != nil {
+		last = cred.Rotation.LastRotated
+	}
+	return last == nil || now.Sub(*last) >= interval
+}
+
+// Rotate issues a new secret with the registered rotator, saves the credential and notifies the listeners
+func Rotate(cred *Credential) error {
+	rotator, rollback := getRotator(cred.Type)
+	if rotator == nil || rollback == nil {
+		return fmt.Errorf("no rotator for credential type [%s]", cred.Type)
+	}
+	params, err := cred.decryptedParams()
+	if err != nil {
+		return err
+	}
+	oldParams := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		oldParams[k] = v
+	}
+
+	if cred.Rotation == nil {
+		cred.Rotation = &RotationConfig{}
+	}
+	if err := rotator(cred, params); err != nil {
+		cred.Rotation.LastError = err.Error()
+		if err1 := orm.Update(nil, cred); err1 != nil {
+			log.Error(err1)
+		}
+		return err
+	}
+
+	if err := saveRotated(cred, params); err != nil {
+		//the new secret would be lost, restore the previous one which is still stored
+		if err1 := rollback(cred, oldParams, params); err1 != nil {
+			return errors.Errorf("failed to save the rotated credential [%v]: %v, and failed to roll back: %v", cred.Name, err, err1)
+		}
+		return errors.Errorf("failed to save the rotated credential [%v], rolled back: %v", cred.Name, err)
+	}
+	log.Infof("credential [%v] was rotated", cred.Name)
+	markSeen(cred)
+	TriggerChangeEvent(cred)
+	return nil
+}
+
+// saveRotated encrypts and saves the rotated params, the credential is left unchanged on error
+func saveRotated(cred *Credential, params map[string]interface{}) error {
+	oldPayload := cred.Payload[cred.Type]
+	oldEncrypt := cred.Encrypt
+	oldRotation := *cred.Rotation
+	oldInvalid := cred.Invalid
+	oldUpdated := cred.Updated
+
+	//params are encrypted in place, keep the plaintext for the rollback
+	payload := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		payload[k] = v
+	}
+	cred.Payload[cred.Type] = payload
+	err := cred.Encode()
+	if err == nil {
+		now := time.Now()
+		cred.Rotation.LastRotated = &now
+		cred.Rotation.LastError = ""
+		cred.Invalid = false
+		cred.Updated = &now
+		err = orm.Update(nil, cred)
+	}
+	if err != nil {
+		cred.Payload[cred.Type] = oldPayload
+		cred.Encrypt = oldEncrypt
+		*cred.Rotation = oldRotation
+		cred.Invalid = oldInvalid
+		cred.Updated = oldUpdated
+	}
+	return err
+}
+
+func GetCredentials() ([]*Credential, error) {
+	err, result := orm.Search(Credential{}, &orm.Query{Size: 10000})
+	if err != nil {
+		return nil, err
+	}
+	output := make([]*Credential, 0, len(result.Result))
+	for _, v := range result.Result {
+		cred := &Credential{}
+		if err := util.FromJSONBytes(util.MustToJSONBytes(v), cred); err != nil {
+			return nil, err
+		}
+		output = append(output, cred)
+	}
+	return output, nil
+}
+
+// RotateExpired rotates all the credentials which are due, returns the number of rotated credentials
+func RotateExpired(creds []*Credential) (int, error) {
+	var count int
+	var errs []string
+	now := time.Now()
+	for _, cred := range creds {
+		if !cred.IsRotationDue(now) {
+			continue
+		}
+		if err := Rotate(cred); err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", cred.Name, err))
+			continue
+		}
+		count++
+	}
+	if len(errs) > 0 {
+		return count, errors.Errorf("failed to rotate credentials: %v", errs)
+	}
+	return count, nil
+}
+
+// the version of credentials seen by this node, used to pick up the changes made by other nodes
+var seen = sync.Map{}
+
+func credentialVersion(cred *Credential) string {
+	return util.MD5digestString(util.MustToJSONBytes(cred.Payload))
+}
+
+func markSeen(cred *Credential) {
+	seen.Store(cred.ID, credentialVersion(cred))
+}
+
+// CheckChanges notifies the listeners for the credentials changed since last check,
+// the first check of a credential only records its version
+func CheckChanges(creds []*Credential) {
+	for _, cred := range creds {
+		v := credentialVersion(cred)
+		prev, ok := seen.Load(cred.ID)
+		seen.Store(cred.ID, v)
+		if ok && prev != v {
+			log.Infof("credential [%v] was changed", cred.Name)
+			TriggerChangeEvent(cred)
+		}
+	}
+}
+
+// RotateSecret generates a new master secret and re-encrypts all the credentials,
+// the previous secret is kept to decode the credentials failed to be re-encrypted.
+// the master secret only lives in the local keystore, so the rotation is refused when other nodes share the credentials,
+// unless force is set and the new secret will be copied to them.
+// other nodes are not visible without the cluster membership, force is required in that case
+func RotateSecret(force bool) error {
+	if cluster.GetMembership() == nil && !force {
+		return errors.New("cluster membership is not enabled, not able to tell whether other nodes share the credentials, use force to rotate anyway")
+	}
+	if nodes := cluster.GetNodes(); len(nodes) > 1 && !force {
+		ids := make([]string, 0, len(nodes))
+		for _, n := range nodes {
+			ids = append(ids, n.ID)
+		}
+		return errors.Errorf("credentials are shared by nodes %v, they are not able to decrypt after the master secret is rotated locally", ids)
+	}
+
+	creds, err := GetCredentials()
+	if err != nil {
+		return err
+	}
+	previous, err := GetOrInitSecret()
+	if err != nil {
+		return err
+	}
+
+	params := make([]map[string]interface{}, len(creds))
+	for i, cred := range creds {
+		//only one previous secret is kept, all the credentials must be encrypted with the current one before rotating again
+		cred.secret = previous
+		params[i], err = cred.decryptedParams()
+		cred.secret = nil
+		if err != nil {
+			return errors.Errorf("failed to decode credential [%v] with the current secret, save it again before rotating: %v", cred.Name, err)
+		}
+	}
+
+	secret, err := util.RandomBytes(32)
+	if err != nil {
+		return err
+	}
+	if err := keystore.SetValue(PreviousSecretKey, previous); err != nil {
+		return err
+	}
+	if err := InitSecret(nil, secret); err != nil {
+		return err
+	}
+
+	var errs []string
+	for i, cred := range creds {
+		cred.Payload[cred.Type] = params[i]
+		if err := cred.Encode(); err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", cred.Name, err))
+			continue
+		}
+		if err := orm.Update(nil, cred); err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", cred.Name, err))
+			continue
+		}
+		markSeen(cred)
+	}
+	if len(errs) > 0 {
+		return errors.Errorf("failed to re-encrypt credentials: %v", errs)
+	}
+	log.Infof("master secret was rotated, [%v] credentials re-encrypted", len(creds))
+	return nil
+}
@@ -0,0 +1,63 @@
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package credential
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsRotationDue(t *testing.T) {
+	now := time.Now()
+	created := now.Add(-24 * time.Hour)
+	cred := Credential{}
+	cred.Created = &created
+	assert.False(t, cred.IsRotationDue(now))
+
+	cred.Rotation = &RotationConfig{Enabled: true, Interval: "2d"}
+	assert.False(t, cred.IsRotationDue(now))
+	assert.True(t, cred.IsRotationDue(now.Add(24*time.Hour)))
+
+	last := now.Add(-time.Hour)
+	cred.Rotation.LastRotated = &last
+	assert.False(t, cred.IsRotationDue(now.Add(24*time.Hour)))
+
+	//default to 90 days
+	cred.Rotation.Interval = ""
+	assert.False(t, cred.IsRotationDue(now.Add(80*24*time.Hour)))
+	assert.True(t, cred.IsRotationDue(now.Add(90*24*time.Hour)))
+}
+
+func TestCheckChanges(t *testing.T) {
+	var changed []string
+	RegisterChangeEvent(func(cred *Credential) {
+		changed = append(changed, cred.ID)
+	})
+
+	cred := &Credential{Type: BasicAuth, Payload: map[string]interface{}{
+		BasicAuth: map[string]interface{}{"username": "elastic", "password": "v1"},
+	}}
+	cred.ID = "check_changes"
+	CheckChanges([]*Credential{cred})
+	assert.Empty(t, changed)
+
+	CheckChanges([]*Credential{cred})
+	assert.Empty(t, changed)
+
+	cred.Payload[BasicAuth].(map[string]interface{})["password"] = "v2"
+	CheckChanges([]*Credential{cred})
+	assert.Equal(t, []string{"check_changes"}, changed)
+}
+
+func TestRotateWithoutRotator(t *testing.T) {
+	cred := &Credential{Type: BearerToken}
+	assert.NotNil(t, Rotate(cred))
+}
+
+func TestRotateSecretWithoutMembership(t *testing.T) {
+	assert.NotNil(t, RotateSecret(false))
+}
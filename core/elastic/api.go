@@ -76,6 +76,9 @@ type API interface {
 
 	Index(indexName, docType string, id interface{}, data interface{}, refresh string) (*InsertResponse, error)
 
+	//IndexWithOptions index a document conditionally, returns ErrVersionConflict if the condition is not met
+	IndexWithOptions(indexName, docType string, id interface{}, data interface{}, options *IndexOptions) (*InsertResponse, error)
+
 	Update(indexName, docType string, id interface{}, data interface{}, refresh string) (*InsertResponse, error)
 
 	Bulk(data []byte) (*util.Result, error)
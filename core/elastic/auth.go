@@ -0,0 +1,219 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package elastic
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/util"
+	"github.com/rubyniu105/framework/lib/go-ucfg"
+)
+
+// GetAuthorizationHeader returns the value of Authorization header for api key or bearer token,
+// returns empty when the cluster is accessed with basic auth or anonymously
+func (config *ElasticsearchConfig) GetAuthorizationHeader() string {
+	if config.APIKey != nil && config.APIKey.Key.Get() != "" {
+		if config.APIKey.ID == "" {
+			//already encoded
+			return "ApiKey " + config.APIKey.Key.Get()
+		}
+		return "ApiKey " + base64.StdEncoding.EncodeToString([]byte(config.APIKey.ID+":"+config.APIKey.Key.Get()))
+	}
+	if config.BearerToken.Get() != "" {
+		return "Bearer " + config.BearerToken.Get()
+	}
+	return ""
+}
+
+// HasTokenAuth tells whether the basic auth should be skipped
+func (config *ElasticsearchConfig) HasTokenAuth() bool {
+	return config.GetAuthorizationHeader() != ""
+}
+
+// GetSanitizedConfig returns a copy of the config with the passwords, keys and tokens redacted,
+// the config must be sanitized before exposed by the apis
+func (config *ElasticsearchConfig) GetSanitizedConfig() *ElasticsearchConfig {
+	c := *config
+	if c.BasicAuth != nil {
+		auth := *c.BasicAuth
+		auth.Password = redactSecret(auth.Password)
+		c.BasicAuth = &auth
+	}
+	if c.AgentBasicAuth != nil {
+		auth := *c.AgentBasicAuth
+		auth.Password = redactSecret(auth.Password)
+		c.AgentBasicAuth = &auth
+	}
+	if c.APIKey != nil {
+		apiKey := *c.APIKey
+		apiKey.Key = redactSecret(apiKey.Key)
+		c.APIKey = &apiKey
+	}
+	if c.ClientCert != nil {
+		cert := *c.ClientCert
+		cert.Key = redactSecret(cert.Key)
+		c.ClientCert = &cert
+	}
+	c.BearerToken = redactSecret(c.BearerToken)
+	return &c
+}
+
+func redactSecret(secret ucfg.SecretString) ucfg.SecretString {
+	if secret == "" {
+		return ""
+	}
+	return ucfg.SecretShadowText
+}
+
+func readPEM(content, file string) ([]byte, error) {
+	if content != "" {
+		return []byte(content), nil
+	}
+	if file != "" {
+		return util.FileGetContent(file)
+	}
+	return nil, nil
+}
+
+// GetTLSConfig returns the tls config to access the cluster, client certs are loaded for mutual tls,
+// server certs are not verified unless the ca was provided
+func (config *ElasticsearchConfig) GetTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: true}
+	cfg := config.ClientCert
+	if cfg == nil {
+		return tlsConfig, nil
+	}
+
+	certPEM, err := readPEM(cfg.Cert, cfg.CertFile)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := readPEM(cfg.Key.Get(), cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(certPEM) > 0 || len(keyPEM) > 0 {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, errors.Errorf("invalid client cert of cluster [%v]: %v", config.Name, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	caPEM, err := readPEM(cfg.CA, cfg.CAFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(caPEM) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, errors.Errorf("invalid ca of cluster [%v]", config.Name)
+		}
+		if !cfg.SkipDomainVerify {
+			tlsConfig.InsecureSkipVerify = false
+			tlsConfig.RootCAs = pool
+		} else {
+			//verify the chain only
+			tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				certs := make([]*x509.Certificate, 0, len(rawCerts))
+				for _, raw := range rawCerts {
+					cert, err := x509.ParseCertificate(raw)
+					if err != nil {
+						return err
+					}
+					certs = append(certs, cert)
+				}
+				if len(certs) == 0 {
+					return errors.New("no server certificate")
+				}
+				opts := x509.VerifyOptions{Roots: pool, Intermediates: x509.NewCertPool()}
+				for _, cert := range certs[1:] {
+					opts.Intermediates.AddCert(cert)
+				}
+				_, err := certs[0].Verify(opts)
+				return err
+			}
+		}
+	}
+	return tlsConfig, nil
+}
+
+type netClient struct {
+	//hash of the client cert, cert changes lead to a new client
+	certHash string
+	client   *http.Client
+}
+
+var netClients = map[string]*netClient{} //cluster id: client
+var netClientLock = sync.RWMutex{}
+
+// GetNetHttpClient returns the net/http client with the client certs of cluster,
+// returns nil if no client cert configured, then the default client should be used
+func (metadata *ElasticsearchMetadata) GetNetHttpClient() *http.Client {
+	if metadata.Config == nil || metadata.Config.ClientCert == nil {
+		return nil
+	}
+
+	certHash := util.MD5digest(string(util.MustToJSONBytes(metadata.Config.ClientCert)) + metadata.Config.ClientCert.Key.Get())
+	netClientLock.RLock()
+	v, ok := netClients[metadata.Config.ID]
+	netClientLock.RUnlock()
+	if ok && v.certHash == certHash {
+		return v.client
+	}
+
+	tlsConfig, err := metadata.Config.GetTLSConfig()
+	if err != nil {
+		log.Errorf("failed to init tls config of cluster [%v]: %v", metadata.Config.Name, err)
+		return nil
+	}
+
+	netClientLock.Lock()
+	defer netClientLock.Unlock()
+	v, ok = netClients[metadata.Config.ID]
+	if ok {
+		if v.certHash == certHash {
+			return v.client
+		}
+		//the replaced client may still serve requests in flight, only the idle connections are closed
+		v.client.CloseIdleConnections()
+	}
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			TLSClientConfig:     tlsConfig,
+			MaxIdleConnsPerHost: 100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+	netClients[metadata.Config.ID] = &netClient{certHash: certHash, client: client}
+	return client
+}
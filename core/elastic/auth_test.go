@@ -0,0 +1,147 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package elastic
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rubyniu105/framework/core/model"
+	"github.com/rubyniu105/framework/lib/go-ucfg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAuthorizationHeader(t *testing.T) {
+	cfg := ElasticsearchConfig{}
+	assert.Equal(t, "", cfg.GetAuthorizationHeader())
+
+	cfg.BearerToken = "token"
+	assert.Equal(t, "Bearer token", cfg.GetAuthorizationHeader())
+
+	//api key takes precedence
+	cfg.APIKey = &model.APIKey{ID: "id", Key: "key"}
+	assert.Equal(t, "ApiKey aWQ6a2V5", cfg.GetAuthorizationHeader())
+
+	cfg.APIKey = &model.APIKey{Key: "aWQ6a2V5"}
+	assert.Equal(t, "ApiKey aWQ6a2V5", cfg.GetAuthorizationHeader())
+}
+
+func TestGetSanitizedConfig(t *testing.T) {
+	cfg := &ElasticsearchConfig{
+		BasicAuth:   &model.BasicAuth{Username: "elastic", Password: "password"},
+		APIKey:      &model.APIKey{ID: "id", Key: "key"},
+		BearerToken: "token",
+		ClientCert:  &model.ClientCert{CertFile: "client.crt", Key: "private key"},
+	}
+	sanitized := cfg.GetSanitizedConfig()
+	assert.Equal(t, ucfg.SecretShadowText, sanitized.BasicAuth.Password.Get())
+	assert.Equal(t, ucfg.SecretShadowText, sanitized.APIKey.Key.Get())
+	assert.Equal(t, ucfg.SecretShadowText, sanitized.BearerToken.Get())
+	assert.Equal(t, ucfg.SecretShadowText, sanitized.ClientCert.Key.Get())
+	assert.Equal(t, "elastic", sanitized.BasicAuth.Username)
+	assert.Equal(t, "id", sanitized.APIKey.ID)
+	assert.Equal(t, "client.crt", sanitized.ClientCert.CertFile)
+	assert.Nil(t, sanitized.AgentBasicAuth)
+
+	//the original config is still able to access the cluster
+	assert.Equal(t, "password", cfg.BasicAuth.Password.Get())
+	assert.Equal(t, "key", cfg.APIKey.Key.Get())
+	assert.Equal(t, "token", cfg.BearerToken.Get())
+	assert.Equal(t, "private key", cfg.ClientCert.Key.Get())
+}
+
+func newTestCert(t *testing.T) (certPEM, keyPEM []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return
+}
+
+func TestClientCert(t *testing.T) {
+	certPEM, keyPEM := newTestCert(t)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "client", r.TLS.PeerCertificates[0].Subject.CommonName)
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	metadata := &ElasticsearchMetadata{Config: &ElasticsearchConfig{}}
+	metadata.Config.ID = "mtls"
+	assert.Nil(t, metadata.GetNetHttpClient())
+
+	metadata.Config.ClientCert = &model.ClientCert{Cert: string(certPEM), Key: "invalid"}
+	_, err := metadata.Config.GetTLSConfig()
+	assert.NotNil(t, err)
+
+	metadata.Config.ClientCert.Key = ucfg.SecretString(keyPEM)
+	client := metadata.GetNetHttpClient()
+	assert.NotNil(t, client)
+	assert.Equal(t, client, metadata.GetNetHttpClient())
+
+	res, err := client.Get(server.URL)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	res.Body.Close()
+
+	//cert changes replace the client of the cluster
+	metadata.Config.ClientCert.SkipDomainVerify = true
+	newClient := metadata.GetNetHttpClient()
+	assert.True(t, client != newClient)
+	assert.True(t, newClient == metadata.GetNetHttpClient())
+	netClientLock.RLock()
+	assert.True(t, newClient == netClients["mtls"].client)
+	netClientLock.RUnlock()
+
+	//without the client cert
+	res, err = (&http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}).Get(server.URL)
+	if err == nil {
+		res.Body.Close()
+	}
+	assert.NotNil(t, err)
+}
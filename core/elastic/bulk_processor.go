@@ -405,6 +405,24 @@ func NewBulkProcessor(tag, esClusterID string, cfg BulkProcessorConfig) BulkProc
 	return bulkProcessor
 }
 
+func init() {
+	stats.DefineHistogram("elasticsearch", "bulk_latency_ms", stats.MetricOptions{
+		Help: "Latency of the bulk requests in milliseconds",
+	})
+}
+
+func observeBulkLatency(clusterID, host string, status int, err error, took time.Duration) {
+	code := util.IntToString(status)
+	if err != nil {
+		code = "error"
+	}
+	stats.Observe("elasticsearch", "bulk_latency_ms", stats.Labels{
+		"cluster_id": clusterID,
+		"host":       host,
+		"status":     code,
+	}, float64(took.Microseconds())/1000)
+}
+
 // bulkResult is valid only if max_reject_retry_times == 0
 func (joint *BulkProcessor) Bulk(ctx context.Context, tag string, metadata *ElasticsearchMetadata, host string, buffer *BulkBuffer) (continueNext bool, statsRet map[int]int, bulkResult *BulkResult, err error) {
 
@@ -420,6 +438,11 @@ func (joint *BulkProcessor) Bulk(ctx context.Context, tag string, metadata *Elas
 		panic("invalid host")
 	}
 
+	host, breaker, err := metadata.AcquireHost(host)
+	if err != nil {
+		return false, statsRet, nil, err
+	}
+
 	httpClient := metadata.GetHttpClient(host)
 
 	var url string
@@ -443,7 +466,9 @@ func (joint *BulkProcessor) Bulk(ctx context.Context, tag string, metadata *Elas
 	clonedURI := req.CloneURI()
 	defer fasthttp.ReleaseURI(clonedURI)
 
-	if metadata.Config.BasicAuth != nil {
+	if auth := metadata.Config.GetAuthorizationHeader(); auth != "" {
+		req.Header.Set(fasthttp.HeaderAuthorization, auth)
+	} else if metadata.Config.BasicAuth != nil {
 		clonedURI.SetUsername(metadata.Config.BasicAuth.Username)
 		clonedURI.SetPassword(metadata.Config.BasicAuth.Password.Get())
 	}
@@ -509,7 +534,13 @@ DO:
 
 	req.SetURI(clonedURI)
 	//execute
+	start := time.Now()
 	err = httpClient.DoTimeout(req, resp, time.Duration(joint.Config.RequestTimeoutInSecond)*time.Second)
+	took := time.Since(start)
+	observeBulkLatency(metadata.Config.ID, host, resp.StatusCode(), err, took)
+	if breaker != nil {
+		breaker.Observe(resp.StatusCode(), err, took)
+	}
 	//restore schema
 	clonedURI.SetScheme(orignalSchema)
 	req.SetURI(clonedURI)
@@ -565,6 +596,7 @@ DO:
 		if util.ContainStr(string(req.Header.RequestURI()), "_bulk") {
 
 			containError, statsCodeStats, bulkResult := HandleBulkResponse(req, resp, labels, data, resbody, successItems, nonRetryableItems, retryableItems, joint.Config.BulkResponseParseConfig, joint.Config.RetryRules)
+			metadata.ObserveNodeTraffic(host, statsCodeStats[429] > 0, took)
 
 			for k, v := range statsCodeStats {
 				if global.Env().IsDebug {
@@ -612,6 +644,10 @@ DO:
 					retryTimes++
 					stats.Increment("elasticsearch."+tag+"."+metadata.Config.Name+".bulk", "retry")
 
+					//the node may be tripped by other requests while waiting
+					if breaker != nil && !breaker.Allow() {
+						return false, statsRet, bulkResult, errors.Errorf("circuit breaker of node [%v] is open, quit retry", host)
+					}
 					goto DO
 				}
 
@@ -634,6 +670,7 @@ DO:
 		return true, statsRet, bulkResult, nil
 	} else {
 		statsRet[resp.StatusCode()] = statsRet[resp.StatusCode()] + buffer.GetMessageCount()
+		metadata.ObserveNodeTraffic(host, resp.StatusCode() == 429 || resp.StatusCode() == 503, took)
 
 		var bulkResult *BulkResult
 
@@ -0,0 +1,273 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package elastic
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/rubyniu105/framework/core/env"
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/global"
+	"github.com/rubyniu105/framework/core/stats"
+)
+
+type CircuitBreakerConfig struct {
+	Enabled bool `json:"enabled,omitempty" config:"enabled"`
+	//trip after consecutive failures, default 5
+	FailureThreshold int `json:"failure_threshold,omitempty" config:"failure_threshold"`
+	//wait before probing an open node, default 30s
+	OpenTimeoutInSeconds int `json:"open_timeout_in_seconds,omitempty" config:"open_timeout_in_seconds"`
+	//responses slower than this are counted as failures, disabled by default
+	SlowRequestThresholdInMs int `json:"slow_request_threshold_in_ms,omitempty" config:"slow_request_threshold_in_ms"`
+}
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half_open"
+)
+
+// CircuitBreaker guards one node, requests are routed to other nodes while it's open,
+// after the open timeout a single probe request is allowed to decide whether to close it again
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	host      string
+	cfg       CircuitBreakerConfig
+	state     CircuitState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	probedAt  time.Time
+	clusterID string
+}
+
+var breakers = sync.Map{} //cluster_id:host: breaker
+
+func NewCircuitBreaker(clusterID, host string, cfg CircuitBreakerConfig) *CircuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeoutInSeconds <= 0 {
+		cfg.OpenTimeoutInSeconds = 30
+	}
+	return &CircuitBreaker{clusterID: clusterID, host: host, cfg: cfg, state: CircuitClosed}
+}
+
+// GetCircuitBreaker returns the breaker of the host, nil if circuit breaker is not enabled,
+// breakers are per cluster, as clusters may share hosts with different settings
+func (metadata *ElasticsearchMetadata) GetCircuitBreaker(host string) *CircuitBreaker {
+	cfg := metadata.Config.CircuitBreaker
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	key := metadata.Config.ID + ":" + host
+	v, ok := breakers.Load(key)
+	if !ok {
+		var loaded bool
+		v, loaded = breakers.LoadOrStore(key, NewCircuitBreaker(metadata.Config.ID, host, *cfg))
+		if !loaded {
+			registerCircuitHost(metadata.Config.ID, host)
+		}
+	}
+	return v.(*CircuitBreaker)
+}
+
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+func (cb *CircuitBreaker) openTimeout() time.Duration {
+	return time.Duration(cb.cfg.OpenTimeoutInSeconds) * time.Second
+}
+
+// Allow checks if a request can be sent to the host, the caller must report the result by OnSuccess or OnFailure
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		if time.Since(cb.openedAt) < cb.openTimeout() {
+			return false
+		}
+		cb.setState(CircuitHalfOpen)
+		fallthrough
+	case CircuitHalfOpen:
+		//only one probe at a time, unless the previous probe never reported back
+		if cb.probing && time.Since(cb.probedAt) < cb.openTimeout() {
+			return false
+		}
+		cb.probing = true
+		cb.probedAt = time.Now()
+	}
+	return true
+}
+
+func (cb *CircuitBreaker) OnSuccess() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures = 0
+	cb.probing = false
+	if cb.state != CircuitClosed {
+		cb.setState(CircuitClosed)
+	}
+}
+
+func (cb *CircuitBreaker) OnFailure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.failures++
+	cb.probing = false
+	if cb.state == CircuitHalfOpen || (cb.state == CircuitClosed && cb.failures >= cb.cfg.FailureThreshold) {
+		cb.openedAt = time.Now()
+		cb.setState(CircuitOpen)
+	}
+}
+
+// Observe reports the result of a request, 429 is left to traffic control as the node is still healthy
+func (cb *CircuitBreaker) Observe(status int, err error, took time.Duration) {
+	if err != nil || status == 502 || status == 503 || status == 504 ||
+		(cb.cfg.SlowRequestThresholdInMs > 0 && took > time.Duration(cb.cfg.SlowRequestThresholdInMs)*time.Millisecond) {
+		cb.OnFailure()
+		return
+	}
+	cb.OnSuccess()
+}
+
+// setState must be called after holding mu
+func (cb *CircuitBreaker) setState(state CircuitState) {
+	if cb.state == state {
+		return
+	}
+	log.Infof("circuit breaker of node [%v] changed from [%v] to [%v], failures: %v", cb.host, cb.state, state, cb.failures)
+	cb.state = state
+	stats.Increment(cb.clusterID, "circuit_breaker_"+string(state))
+	reportCircuitHealth(cb.clusterID, cb.host, state)
+}
+
+var circuitStates = map[string]map[string]CircuitState{} //cluster_id: host: state
+var circuitStatesLock sync.Mutex
+
+// getCircuitStates must be called after holding circuitStatesLock
+func getCircuitStates(clusterID string) map[string]CircuitState {
+	states, ok := circuitStates[clusterID]
+	if !ok {
+		states = map[string]CircuitState{}
+		circuitStates[clusterID] = states
+	}
+	return states
+}
+
+// registerCircuitHost tracks the new breaker as closed, the cluster is not red while it is closed
+func registerCircuitHost(clusterID, host string) {
+	circuitStatesLock.Lock()
+	defer circuitStatesLock.Unlock()
+	states := getCircuitStates(clusterID)
+	if _, ok := states[host]; !ok {
+		states[host] = CircuitClosed
+	}
+}
+
+// reportCircuitHealth reports the health of the cluster, the requests are routed to other nodes while
+// some of the circuits are open, so it's yellow, and only red when the circuits of all the hosts are open
+func reportCircuitHealth(clusterID, host string, state CircuitState) {
+	circuitStatesLock.Lock()
+	defer circuitStatesLock.Unlock()
+
+	states := getCircuitStates(clusterID)
+	states[host] = state
+
+	hosts := map[string]bool{}
+	for k := range states {
+		hosts[k] = true
+	}
+	if metadata := GetMetadata(clusterID); metadata != nil {
+		for _, k := range metadata.getCircuitHosts() {
+			hosts[k] = true
+		}
+	}
+
+	open, closed := 0, 0
+	for k := range hosts {
+		switch states[k] {
+		case CircuitOpen:
+			open++
+		case CircuitHalfOpen:
+		default:
+			closed++
+		}
+	}
+
+	health := env.HEALTH_YELLOW
+	if open == len(hosts) {
+		health = env.HEALTH_RED
+	} else if closed == len(hosts) {
+		health = env.HEALTH_GREEN
+	}
+	global.Env().ReportHealth("elasticsearch_circuit:"+clusterID, health)
+}
+
+// getCircuitHosts returns the seed hosts and the http hosts of the nodes
+func (metadata *ElasticsearchMetadata) getCircuitHosts() []string {
+	hosts := append([]string{}, metadata.GetSeedHosts()...)
+	if metadata.Nodes != nil {
+		for _, v := range *metadata.Nodes {
+			if v.Http.PublishAddress != "" {
+				hosts = append(hosts, v.GetHttpPublishHost())
+			}
+		}
+	}
+	return hosts
+}
+
+// AcquireHost returns a host whose circuit is not open, the preferred host goes first,
+// the breaker of the returned host is nil if circuit breaker is not enabled
+func (metadata *ElasticsearchMetadata) AcquireHost(preferred string) (string, *CircuitBreaker, error) {
+	cb := metadata.GetCircuitBreaker(preferred)
+	if cb == nil || cb.Allow() {
+		return preferred, cb, nil
+	}
+
+	for _, host := range metadata.getCircuitHosts() {
+		if host == "" || host == preferred || !IsHostAvailable(host) {
+			continue
+		}
+		cb = metadata.GetCircuitBreaker(host)
+		if cb.Allow() {
+			if global.Env().IsDebug {
+				log.Debugf("circuit of node [%v] is open, route to [%v]", preferred, host)
+			}
+			return host, cb, nil
+		}
+	}
+	return "", nil, errors.Errorf("circuit of all nodes in [%v] are open", metadata.Config.Name)
+}
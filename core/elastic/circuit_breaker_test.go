@@ -0,0 +1,116 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package elastic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/global"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	cb := NewCircuitBreaker("test", "localhost:9200", CircuitBreakerConfig{FailureThreshold: 2, OpenTimeoutInSeconds: 1})
+	assert.True(t, cb.Allow())
+	cb.Observe(0, errors.New("timeout"), time.Second)
+	assert.Equal(t, CircuitClosed, cb.State())
+	cb.Observe(503, nil, time.Millisecond)
+	assert.Equal(t, CircuitOpen, cb.State())
+	assert.False(t, cb.Allow())
+
+	//single probe after the open timeout
+	time.Sleep(1100 * time.Millisecond)
+	assert.True(t, cb.Allow())
+	assert.Equal(t, CircuitHalfOpen, cb.State())
+	assert.False(t, cb.Allow())
+
+	//failed probe opens it again
+	cb.OnFailure()
+	assert.Equal(t, CircuitOpen, cb.State())
+	assert.False(t, cb.Allow())
+
+	time.Sleep(1100 * time.Millisecond)
+	assert.True(t, cb.Allow())
+	cb.Observe(200, nil, time.Millisecond)
+	assert.Equal(t, CircuitClosed, cb.State())
+	assert.True(t, cb.Allow())
+
+	//rejections are not failures of the node
+	cb.Observe(429, nil, time.Millisecond)
+	cb.Observe(429, nil, time.Millisecond)
+	assert.Equal(t, CircuitClosed, cb.State())
+}
+
+func TestAcquireHost(t *testing.T) {
+	metadata := &ElasticsearchMetadata{Config: &ElasticsearchConfig{
+		ID:             "breaker",
+		Hosts:          []string{"127.0.0.1:19201", "127.0.0.1:19202"},
+		CircuitBreaker: &CircuitBreakerConfig{Enabled: true, FailureThreshold: 1},
+	}}
+	nodeAvailCache.Put("127.0.0.1:19202", true)
+
+	host, cb, err := metadata.AcquireHost("127.0.0.1:19201")
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:19201", host)
+	cb.OnFailure()
+
+	host, cb, err = metadata.AcquireHost("127.0.0.1:19201")
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:19202", host)
+	cb.OnFailure()
+
+	_, _, err = metadata.AcquireHost("127.0.0.1:19201")
+	assert.NotNil(t, err)
+}
+
+func TestCircuitBreakerPerCluster(t *testing.T) {
+	cfg := &CircuitBreakerConfig{Enabled: true, FailureThreshold: 1}
+	m1 := &ElasticsearchMetadata{Config: &ElasticsearchConfig{ID: "breaker_1", CircuitBreaker: cfg}}
+	m2 := &ElasticsearchMetadata{Config: &ElasticsearchConfig{ID: "breaker_2", CircuitBreaker: cfg}}
+
+	m1.GetCircuitBreaker("127.0.0.1:19203").OnFailure()
+	assert.Equal(t, CircuitOpen, m1.GetCircuitBreaker("127.0.0.1:19203").State())
+	assert.Equal(t, CircuitClosed, m2.GetCircuitBreaker("127.0.0.1:19203").State())
+}
+
+func TestCircuitHealth(t *testing.T) {
+	cfg := &CircuitBreakerConfig{Enabled: true, FailureThreshold: 1}
+	metadata := &ElasticsearchMetadata{Config: &ElasticsearchConfig{ID: "breaker_health", CircuitBreaker: cfg}}
+	health := func() string {
+		return global.Env().GetServicesHealth()["elasticsearch_circuit:breaker_health"]
+	}
+
+	metadata.GetCircuitBreaker("127.0.0.1:19204").OnSuccess()
+	metadata.GetCircuitBreaker("127.0.0.1:19205").OnFailure()
+	assert.Equal(t, "yellow", health())
+
+	metadata.GetCircuitBreaker("127.0.0.1:19204").OnFailure()
+	assert.Equal(t, "red", health())
+
+	metadata.GetCircuitBreaker("127.0.0.1:19204").OnSuccess()
+	metadata.GetCircuitBreaker("127.0.0.1:19205").OnSuccess()
+	assert.Equal(t, "green", health())
+}
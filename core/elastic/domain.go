@@ -31,6 +31,7 @@ import (
 	"github.com/dgraph-io/ristretto"
 	"github.com/rubyniu105/framework/core/orm"
 	"github.com/rubyniu105/framework/core/util"
+	"github.com/rubyniu105/framework/lib/go-ucfg"
 )
 
 type Stats struct {
@@ -486,7 +487,10 @@ type ElasticsearchConfig struct {
 
 	AllowAccessWhenMasterNotFound bool `json:"allow_access_when_master_not_found,omitempty" config:"allow_access_when_master_not_found"`
 
-	BasicAuth *model.BasicAuth `config:"basic_auth" json:"basic_auth,omitempty" elastic_mapping:"basic_auth:{type:object}"`
+	BasicAuth   *model.BasicAuth  `config:"basic_auth" json:"basic_auth,omitempty" elastic_mapping:"basic_auth:{type:object}"`
+	APIKey      *model.APIKey     `config:"api_key" json:"api_key,omitempty" elastic_mapping:"api_key:{type:object}"`
+	BearerToken ucfg.SecretString `config:"bearer_token" json:"bearer_token,omitempty" elastic_mapping:"bearer_token:{type:keyword}"`
+	ClientCert  *model.ClientCert `config:"client_cert" json:"client_cert,omitempty" elastic_mapping:"client_cert:{type:object}"`
 
 	TrafficControl *struct {
 		Enabled              bool `json:"enabled,omitempty" config:"enabled"`
@@ -494,8 +498,12 @@ type ElasticsearchConfig struct {
 		MaxWaitTimeInMs      int  `json:"max_wait_time_in_ms,omitempty" config:"max_wait_time_in_ms" elastic_mapping:"max_wait_time_in_ms:{type:keyword}"`
 		MaxBytesPerNode      int  `json:"max_bytes_per_node,omitempty" config:"max_bytes_per_node" elastic_mapping:"max_bytes_per_node:{type:keyword}"`
 		MaxQpsPerNode        int  `json:"max_qps_per_node,omitempty" config:"max_qps_per_node" elastic_mapping:"max_qps_per_node:{type:keyword}"`
+
+		Adaptive *AdaptiveTrafficControl `json:"adaptive,omitempty" config:"adaptive" elastic_mapping:"adaptive:{type:object}"`
 	} `config:"traffic_control" json:"traffic_control,omitempty" elastic_mapping:"traffic_control:{type:object}"`
 
+	CircuitBreaker *CircuitBreakerConfig `config:"circuit_breaker" json:"circuit_breaker,omitempty" elastic_mapping:"circuit_breaker:{type:object}"`
+
 	Discovery struct {
 		Enabled bool     `json:"enabled,omitempty" config:"enabled"`
 		Modules []string `json:"module,omitempty" config:"module"`
@@ -104,6 +104,26 @@ func RegisterInstance(cfg ElasticsearchConfig, handler API) {
 	}
 }
 
+// ReloadInstance replaces the client and config of a registered cluster without touching its metadata,
+// used when the auth of cluster was changed, eg: credential rotated
+func ReloadInstance(cfg ElasticsearchConfig, handler API) {
+	UpdateClient(cfg, handler)
+	UpdateConfig(cfg)
+	if meta := GetMetadata(cfg.ID); meta != nil {
+		meta.Config = &cfg
+	}
+
+	//http clients are cached by host, drop them to apply the new client certs
+	clientLock.Lock()
+	defer clientLock.Unlock()
+	hosts.Range(func(key, value any) bool {
+		if v, ok := value.(*NodeAvailable); ok && v.ClusterID == cfg.ID {
+			delete(clients, v.Host)
+		}
+		return true
+	})
+}
+
 func UpdateConfig(cfg ElasticsearchConfig) {
 	cfgs.Store(cfg.ID, &cfg)
 }
@@ -558,6 +578,15 @@ func (metadata *ElasticsearchMetadata) NewHttpClient(host string) *fasthttp.Clie
 		DialDualStack:                 true,
 	}
 
+	if metadata.Config.ClientCert != nil {
+		tlsConfig, err := metadata.Config.GetTLSConfig()
+		if err != nil {
+			log.Errorf("failed to init tls config of cluster [%v]: %v", metadata.Config.Name, err)
+		} else {
+			client.TLSConfig = tlsConfig
+		}
+	}
+
 	if metadata.Config.TrafficControl != nil && metadata.Config.TrafficControl.MaxConnectionPerNode > 0 {
 		client.MaxConnsPerHost = metadata.Config.TrafficControl.MaxConnectionPerNode
 	}
@@ -581,12 +610,28 @@ func (metadata *ElasticsearchMetadata) CheckNodeTrafficThrottle(node string, req
 
 		maxTime := time.Duration(metadata.Config.TrafficControl.MaxWaitTimeInMs) * time.Millisecond
 		startTime := time.Now()
+
+		//adaptive limits are tracked per node
+		adaptive := metadata.getAdaptiveTrafficControl()
+		allowQps := func() bool {
+			if adaptive != nil {
+				return metadata.getNodeQpsLimiter(node, adaptive).Allow()
+			}
+			return rate.GetRateLimiterPerSecond(metadata.Config.ID, "req-max_qps", int(metadata.Config.TrafficControl.MaxQpsPerNode)).Allow()
+		}
+		allowBytes := func() bool {
+			if adaptive != nil {
+				return metadata.getNodeBpsLimiter(node, adaptive).AllowN(time.Now(), dataSize)
+			}
+			return rate.GetRateLimiterPerSecond(metadata.Config.ID, "req-max_bps",
+				int(metadata.Config.TrafficControl.MaxBytesPerNode)).AllowN(time.Now(), dataSize)
+		}
 	RetryRateLimit:
 
 		if time.Now().Sub(startTime) < maxTime {
 
 			if metadata.Config.TrafficControl.MaxQpsPerNode > 0 && req > 0 {
-				if !rate.GetRateLimiterPerSecond(metadata.Config.ID, "req-max_qps", int(metadata.Config.TrafficControl.MaxQpsPerNode)).Allow() {
+				if !allowQps() {
 					stats.Increment(metadata.Config.ID, "req-max_qps_throttled")
 					if global.Env().IsDebug {
 						log.Debugf("request qps throttle on node [%v]", node)
@@ -597,8 +642,7 @@ func (metadata *ElasticsearchMetadata) CheckNodeTrafficThrottle(node string, req
 			}
 
 			if metadata.Config.TrafficControl.MaxBytesPerNode > 0 && dataSize > 0 {
-				if !rate.GetRateLimiterPerSecond(metadata.Config.ID, "req-max_bps",
-					int(metadata.Config.TrafficControl.MaxBytesPerNode)).AllowN(time.Now(), dataSize) {
+				if !allowBytes() {
 					stats.Increment(metadata.Config.ID, "req-max_bps_throttled")
 					if global.Env().IsDebug {
 						log.Debugf("request traffic throttle on node [%v]", node)
@@ -177,6 +177,8 @@ type IndexDocument struct {
 	Routing   string                   `json:"_routing,omitempty"`
 	Source    map[string]interface{}   `json:"_source,omitempty"`
 	Highlight map[string][]interface{} `json:"highlight,omitempty"`
+	//sort values of the hit, used by search_after
+	Sort []interface{} `json:"sort,omitempty"`
 }
 
 type BucketBase map[string]interface{}
@@ -244,6 +246,9 @@ type InsertResponse struct {
 	ID      string `json:"_id"`
 	Version int    `json:"_version"`
 
+	SeqNo       int64 `json:"_seq_no"`
+	PrimaryTerm int64 `json:"_primary_term"`
+
 	Shards struct {
 		Total      int `json:"total" `
 		Failed     int `json:"failed"`
@@ -260,6 +265,24 @@ type GetResponse struct {
 	ID      string                 `json:"_id"`
 	Version int                    `json:"_version"`
 	Source  map[string]interface{} `json:"_source"`
+
+	SeqNo       int64 `json:"_seq_no"`
+	PrimaryTerm int64 `json:"_primary_term"`
+}
+
+// ErrVersionConflict is returned when a conditional write lost to a concurrent writer
+var ErrVersionConflict = errors.New("version conflict")
+
+// IndexOptions controls the optimistic concurrency of a single document write
+type IndexOptions struct {
+	//create: fail if the document already exists
+	OpType string
+	//only applied when IfPrimaryTerm > 0, requires elasticsearch 6.7+
+	IfSeqNo       int64
+	IfPrimaryTerm int64
+	//external version for clusters without seq_no, applied when IfPrimaryTerm is 0
+	Version int64
+	Refresh string
 }
 
 // DeleteResponse is a delete response object
@@ -0,0 +1,147 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package elastic
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/rubyniu105/framework/core/rate"
+	"github.com/rubyniu105/framework/core/stats"
+)
+
+// AdaptiveTrafficControl backs off the per node limits when the node rejects requests or slows down,
+// and recovers them gradually, max_qps_per_node and max_bytes_per_node are used as the ceilings
+type AdaptiveTrafficControl struct {
+	Enabled              bool    `json:"enabled,omitempty" config:"enabled"`
+	MinQpsPerNode        int     `json:"min_qps_per_node,omitempty" config:"min_qps_per_node"`
+	MinBytesPerNode      int     `json:"min_bytes_per_node,omitempty" config:"min_bytes_per_node"`
+	IncreasePercent      float64 `json:"increase_percent,omitempty" config:"increase_percent"`
+	DecreaseFactor       float64 `json:"decrease_factor,omitempty" config:"decrease_factor"`
+	AdjustIntervalInMs   int     `json:"adjust_interval_in_ms,omitempty" config:"adjust_interval_in_ms"`
+	LatencyThresholdInMs int     `json:"latency_threshold_in_ms,omitempty" config:"latency_threshold_in_ms"`
+	//back off if the recent latency is higher than the baseline by this ratio, default 2
+	LatencyRatio float64 `json:"latency_ratio,omitempty" config:"latency_ratio"`
+}
+
+func (cfg *AdaptiveTrafficControl) aimdConfig(max, min int) rate.AIMDConfig {
+	c := rate.AIMDConfig{
+		Max:            float64(max),
+		Min:            float64(min),
+		DecreaseFactor: cfg.DecreaseFactor,
+		Interval:       time.Duration(cfg.AdjustIntervalInMs) * time.Millisecond,
+	}
+	if cfg.IncreasePercent > 0 {
+		c.IncreaseStep = c.Max * cfg.IncreasePercent / 100
+	}
+	return c
+}
+
+func (metadata *ElasticsearchMetadata) getAdaptiveTrafficControl() *AdaptiveTrafficControl {
+	tc := metadata.Config.TrafficControl
+	if tc == nil || !tc.Enabled || tc.Adaptive == nil || !tc.Adaptive.Enabled {
+		return nil
+	}
+	return tc.Adaptive
+}
+
+func (metadata *ElasticsearchMetadata) getNodeQpsLimiter(node string, cfg *AdaptiveTrafficControl) *rate.AIMDLimiter {
+	return rate.GetAIMDLimiter(metadata.Config.ID, node+"-req-max_qps",
+		cfg.aimdConfig(metadata.Config.TrafficControl.MaxQpsPerNode, cfg.MinQpsPerNode))
+}
+
+func (metadata *ElasticsearchMetadata) getNodeBpsLimiter(node string, cfg *AdaptiveTrafficControl) *rate.AIMDLimiter {
+	c := cfg.aimdConfig(metadata.Config.TrafficControl.MaxBytesPerNode, cfg.MinBytesPerNode)
+	//a request larger than the burst will never pass
+	c.Burst = metadata.Config.TrafficControl.MaxBytesPerNode
+	return rate.GetAIMDLimiter(metadata.Config.ID, node+"-req-max_bps", c)
+}
+
+type nodeLatency struct {
+	mu       sync.Mutex
+	recent   float64
+	baseline float64
+	samples  int
+}
+
+var nodeLatencies = sync.Map{}
+
+// observe returns true if the latency is considered as overloaded
+func (l *nodeLatency) observe(took time.Duration, cfg *AdaptiveTrafficControl) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ms := float64(took.Microseconds()) / 1000
+	if l.samples == 0 {
+		l.recent = ms
+		l.baseline = ms
+	}
+	l.recent = 0.3*ms + 0.7*l.recent
+	l.baseline = 0.02*ms + 0.98*l.baseline
+	l.samples++
+
+	if cfg.LatencyThresholdInMs > 0 && l.recent > float64(cfg.LatencyThresholdInMs) {
+		return true
+	}
+	ratio := cfg.LatencyRatio
+	if ratio <= 0 {
+		ratio = 2
+	}
+	return l.samples >= 10 && l.recent > l.baseline*ratio
+}
+
+// ObserveNodeTraffic adjusts the adaptive limits of the node by the result of a request
+func (metadata *ElasticsearchMetadata) ObserveNodeTraffic(node string, rejected bool, took time.Duration) {
+	cfg := metadata.getAdaptiveTrafficControl()
+	if cfg == nil {
+		return
+	}
+
+	v, _ := nodeLatencies.LoadOrStore(metadata.Config.ID+":"+node, &nodeLatency{})
+	overloaded := v.(*nodeLatency).observe(took, cfg) || rejected
+
+	labels := stats.Labels{
+		"cluster_id": metadata.Config.ID,
+		"host":       node,
+	}
+	adjust := func(limiter *rate.AIMDLimiter, key string) {
+		if overloaded {
+			if limiter.Decrease() {
+				stats.Increment(metadata.Config.ID, key+"_backoff")
+				log.Debugf("node [%v] of [%v] is overloaded, %v backoff to %.0f", node, metadata.Config.Name, key, limiter.Limit())
+			}
+		} else {
+			limiter.Increase()
+		}
+		stats.GaugeWithLabels("elasticsearch", "traffic_control_"+key, labels, limiter.Limit())
+	}
+
+	if metadata.Config.TrafficControl.MaxQpsPerNode > 0 {
+		adjust(metadata.getNodeQpsLimiter(node, cfg), "max_qps")
+	}
+	if metadata.Config.TrafficControl.MaxBytesPerNode > 0 {
+		adjust(metadata.getNodeBpsLimiter(node, cfg), "max_bps")
+	}
+}
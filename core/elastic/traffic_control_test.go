@@ -0,0 +1,61 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package elastic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rubyniu105/framework/core/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveTrafficControl(t *testing.T) {
+	cfg := &ElasticsearchConfig{}
+	util.MustFromJSONBytes([]byte(`{"id":"adaptive","traffic_control":{"enabled":true,"max_qps_per_node":100,
+		"adaptive":{"enabled":true,"min_qps_per_node":20,"increase_percent":10,"adjust_interval_in_ms":1,"latency_threshold_in_ms":500}}}`), cfg)
+	metadata := &ElasticsearchMetadata{Config: cfg}
+	node := "localhost:9200"
+	limiter := metadata.getNodeQpsLimiter(node, cfg.TrafficControl.Adaptive)
+	assert.Equal(t, float64(100), limiter.Limit())
+
+	metadata.ObserveNodeTraffic(node, true, time.Millisecond)
+	assert.Equal(t, float64(50), limiter.Limit())
+
+	time.Sleep(2 * time.Millisecond)
+	metadata.ObserveNodeTraffic(node, false, time.Millisecond)
+	assert.Equal(t, float64(60), limiter.Limit())
+
+	//slow responses back off too
+	time.Sleep(2 * time.Millisecond)
+	metadata.ObserveNodeTraffic(node, false, 5*time.Second)
+	assert.Equal(t, float64(30), limiter.Limit())
+
+	time.Sleep(2 * time.Millisecond)
+	metadata.ObserveNodeTraffic(node, true, time.Millisecond)
+	assert.Equal(t, float64(20), limiter.Limit())
+
+	//other nodes are not affected
+	assert.Equal(t, float64(100), metadata.getNodeQpsLimiter("localhost:9201", cfg.TrafficControl.Adaptive).Limit())
+}
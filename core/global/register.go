@@ -133,6 +133,11 @@ func Env() *env.Env {
 	return e
 }
 
+// EnvRegistered returns true if an env was registered, without initializing an empty one
+func EnvRegistered() bool {
+	return e != nil
+}
+
 var initCallback = []func(){}
 var shutdownCallback = []func(){}
 
@@ -116,16 +116,9 @@ func GetKeystoreBasePath() string {
 	return global.Env().GetDataDir()
 }
 
+// GetValue resolves the key from all the providers, see RegisterProvider
 func GetValue(key string) ([]byte, error) {
-	ks, err := GetOrInitKeystore()
-	if err != nil {
-		return nil, err
-	}
-	secStr, err := ks.Retrieve(key)
-	if err != nil {
-		return nil, err
-	}
-	return secStr.Get()
+	return resolve(key)
 }
 
 func SetValue(key string, value []byte) error {
@@ -141,20 +134,22 @@ func SetValue(key string, value []byte) error {
 }
 
 func GetVariableResolver() (ucfg.Option, error) {
-	return ucfg.Resolve(func(keyName string) (string, parse.Config, error) {
-		if strings.HasPrefix(keyName, "keystore.") {
-			ks, err := GetOrInitKeystore()
-			if err != nil {
-				return "", parse.NoopConfig, err
-			}
-			v, pc, err := keystore.ResolverWrap(ks)(keyName[9:])
-			if err == ucfg.ErrMissing {
-				return "", parse.NoopConfig, nil
-			}
-			return v, pc, err
+	return ucfg.Resolve(resolveVariable), nil
+}
+
+// resolveVariable resolves `${keystore.x}`, a missing key resolves to empty, failures of providers are returned
+func resolveVariable(keyName string) (string, parse.Config, error) {
+	if strings.HasPrefix(keyName, "keystore.") {
+		v, err := resolve(keyName[9:])
+		if err == keystore.ErrKeyDoesntExists {
+			return "", parse.NoopConfig, nil
+		}
+		if err != nil {
+			return "", parse.NoopConfig, err
 		}
-		return "", parse.NoopConfig, ucfg.ErrMissing
-	}), nil
+		return string(v), parse.NoopConfig, nil
+	}
+	return "", parse.NoopConfig, ucfg.ErrMissing
 }
 
 var watcher *fsnotify.Watcher
@@ -173,18 +168,31 @@ func Watch() {
 		log.Error(err)
 		return
 	}
+	for _, p := range getProviders() {
+		if v, ok := p.(watchedProvider); ok {
+			for _, path := range v.WatchPaths() {
+				if err := watcher.Add(path); err != nil {
+					log.Warnf("failed to watch [%v] of keystore provider [%v]: %v", path, p.Name(), err)
+				}
+			}
+		}
+	}
 	for {
 		select {
 		case event, ok := <-watcher.Events:
 			if !ok {
 				return
 			}
-			if event.Has(fsnotify.Create) {
+			if event.Name == keystoreFile && event.Has(fsnotify.Create) {
 				defaultKeystore, err = initKeystore()
 				if err != nil {
 					log.Error("init keystore error: ", err)
 				}
 			}
+			if event.Has(fsnotify.Chmod) {
+				continue
+			}
+			reloadProviders()
 		case err, ok := <-watcher.Errors:
 			if !ok {
 				return
@@ -0,0 +1,326 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI Ltd. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package keystore
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/rubyniu105/framework/core/util"
+	kslib "github.com/rubyniu105/framework/lib/keystore"
+)
+
+// the providers are configured by environment variables, since secrets are resolved before loading the config file
+const (
+	EnvPrefixEnvKey  = "KEYSTORE_ENV_PREFIX"  //resolve `${keystore.es_password}` from env `<prefix>ES_PASSWORD`
+	SecretsDirEnvKey = "KEYSTORE_SECRETS_DIR" //resolve `${keystore.es_password}` from file `<dir>/es_password`
+	VaultAddrEnvKey  = "VAULT_ADDR"
+	VaultTokenEnvKey = "VAULT_TOKEN"
+	VaultNSEnvKey    = "VAULT_NAMESPACE"
+	VaultMountEnvKey = "KEYSTORE_VAULT_MOUNT" //default: secret
+	VaultPathEnvKey  = "KEYSTORE_VAULT_PATH"  //path of the kv v2 secret, eg: myapp/prod
+	CacheTTLEnvKey   = "KEYSTORE_CACHE_TTL"   //default: 5m
+)
+
+// Provider resolves secrets from a backend, returns ErrKeyDoesntExists if the key was not found
+type Provider interface {
+	Name() string
+	Get(key string) ([]byte, error)
+}
+
+var (
+	providers     []Provider
+	providersOnce sync.Once
+	providerLock  sync.RWMutex
+)
+
+// RegisterProvider adds a provider to the front of the chain, it takes precedence over the builtin ones
+func RegisterProvider(p Provider) {
+	getProviders()
+	providerLock.Lock()
+	defer providerLock.Unlock()
+	providers = append([]Provider{p}, providers...)
+}
+
+func getProviders() []Provider {
+	providersOnce.Do(func() {
+		p := initProviders()
+		providerLock.Lock()
+		providers = append(providers, p...)
+		providerLock.Unlock()
+	})
+	providerLock.RLock()
+	defer providerLock.RUnlock()
+	return providers
+}
+
+// initProviders builds the chain by the environment, the local keystore is always the last one
+func initProviders() []Provider {
+	output := []Provider{}
+	if prefix := os.Getenv(EnvPrefixEnvKey); prefix != "" {
+		output = append(output, &EnvProvider{Prefix: prefix})
+	}
+	if dir := os.Getenv(SecretsDirEnvKey); dir != "" {
+		output = append(output, &DirProvider{Path: dir})
+	}
+	if addr := os.Getenv(VaultAddrEnvKey); addr != "" && os.Getenv(VaultPathEnvKey) != "" {
+		output = append(output, NewVaultProvider(addr, os.Getenv(VaultTokenEnvKey), os.Getenv(VaultMountEnvKey),
+			os.Getenv(VaultPathEnvKey), util.GetDurationOrDefault(os.Getenv(CacheTTLEnvKey), 5*time.Minute)))
+	}
+	for _, v := range output {
+		log.Debugf("keystore provider [%v] enabled", v.Name())
+	}
+	return append(output, &localProvider{})
+}
+
+// resolve walks through the providers, returns ErrKeyDoesntExists if none of them has the key,
+// other errors are returned as is, the providers behind can't tell whether they hold the right value
+func resolve(key string) ([]byte, error) {
+	for _, p := range getProviders() {
+		v, err := p.Get(key)
+		if err == nil {
+			return v, nil
+		}
+		if err != kslib.ErrKeyDoesntExists {
+			return nil, fmt.Errorf("failed to resolve [%v] from keystore provider [%v]: %w", key, p.Name(), err)
+		}
+	}
+	return nil, kslib.ErrKeyDoesntExists
+}
+
+// watchedProvider is implemented by the providers reading secrets from local paths, the paths are watched by Watch
+type watchedProvider interface {
+	WatchPaths() []string
+}
+
+// cachedProvider is implemented by the providers caching secrets, the cache is dropped when Watch noticed a change
+type cachedProvider interface {
+	Invalidate()
+}
+
+var changeListeners []func()
+var changeListenerLock sync.RWMutex
+
+// RegisterChangeListener is notified after the local keystore or the watched secrets were changed
+func RegisterChangeListener(f func()) {
+	changeListenerLock.Lock()
+	defer changeListenerLock.Unlock()
+	changeListeners = append(changeListeners, f)
+}
+
+// reloadProviders drops the cached secrets and notifies the listeners
+func reloadProviders() {
+	for _, p := range getProviders() {
+		if v, ok := p.(cachedProvider); ok {
+			v.Invalidate()
+		}
+	}
+	changeListenerLock.RLock()
+	defer changeListenerLock.RUnlock()
+	for _, f := range changeListeners {
+		f()
+	}
+}
+
+type localProvider struct{}
+
+func (p *localProvider) Name() string {
+	return "local"
+}
+
+func (p *localProvider) Get(key string) ([]byte, error) {
+	ks, err := GetOrInitKeystore()
+	if err != nil {
+		return nil, err
+	}
+	secStr, err := ks.Retrieve(key)
+	if err != nil {
+		return nil, err
+	}
+	return secStr.Get()
+}
+
+type EnvProvider struct {
+	Prefix string
+}
+
+func (p *EnvProvider) Name() string {
+	return "env"
+}
+
+func (p *EnvProvider) Get(key string) ([]byte, error) {
+	name := p.Prefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+	if v, ok := os.LookupEnv(name); ok {
+		return []byte(v), nil
+	}
+	return nil, kslib.ErrKeyDoesntExists
+}
+
+// DirProvider reads secrets from files named by the keys, eg: kubernetes secrets mounted as volume,
+// files are read on every access, so the updates of mounted secrets take effect without restart
+type DirProvider struct {
+	Path string
+}
+
+func (p *DirProvider) Name() string {
+	return "file"
+}
+
+func (p *DirProvider) WatchPaths() []string {
+	return []string{p.Path}
+}
+
+func (p *DirProvider) Get(key string) ([]byte, error) {
+	if key == "" || strings.ContainsAny(key, `/\`) || strings.HasPrefix(key, ".") {
+		return nil, kslib.ErrKeyDoesntExists
+	}
+	b, err := os.ReadFile(filepath.Join(p.Path, key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, kslib.ErrKeyDoesntExists
+		}
+		return nil, err
+	}
+	return []byte(strings.TrimRight(string(b), "\r\n")), nil
+}
+
+// VaultProvider reads secrets from a secret of Vault's kv v2 engine, fields of the secret are used as keys,
+// the secret is cached and refreshed after ttl, the stale one is used if the refresh failed
+type VaultProvider struct {
+	Addr      string
+	Token     string
+	Namespace string
+	Mount     string
+	Path      string
+	TTL       time.Duration
+
+	client    *http.Client
+	lock      sync.Mutex
+	data      map[string]string
+	expiresAt time.Time
+}
+
+func NewVaultProvider(addr, token, mount, path string, ttl time.Duration) *VaultProvider {
+	if mount == "" {
+		mount = "secret"
+	}
+	return &VaultProvider{
+		Addr:      strings.TrimRight(addr, "/"),
+		Token:     token,
+		Namespace: os.Getenv(VaultNSEnvKey),
+		Mount:     strings.Trim(mount, "/"),
+		Path:      strings.Trim(path, "/"),
+		TTL:       ttl,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *VaultProvider) Name() string {
+	return "vault"
+}
+
+func (p *VaultProvider) Get(key string) ([]byte, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.data == nil || time.Now().After(p.expiresAt) {
+		data, err := p.fetch()
+		if err != nil {
+			if p.data == nil {
+				return nil, err
+			}
+			log.Warnf("failed to refresh secrets from vault, use the cached ones: %v", err)
+		} else {
+			p.data = data
+		}
+		p.expiresAt = time.Now().Add(p.TTL)
+	}
+
+	if v, ok := p.data[key]; ok {
+		return []byte(v), nil
+	}
+	return nil, kslib.ErrKeyDoesntExists
+}
+
+// Invalidate forces the secret to be fetched on next access
+func (p *VaultProvider) Invalidate() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.expiresAt = time.Time{}
+}
+
+func (p *VaultProvider) fetch() (map[string]string, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/v1/%s/data/%s", p.Addr, p.Mount, p.Path), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", p.Token)
+	if p.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.Namespace)
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return map[string]string{}, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault responded with status %v", res.StatusCode)
+	}
+
+	obj := struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}{}
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := util.FromJSONBytes(b, &obj); err != nil {
+		return nil, err
+	}
+	data := make(map[string]string, len(obj.Data.Data))
+	for k, v := range obj.Data.Data {
+		if s, ok := v.(string); ok {
+			data[k] = s
+		} else {
+			data[k] = util.MustToJSON(v)
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,184 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI Ltd. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package keystore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/lib/go-ucfg"
+	kslib "github.com/rubyniu105/framework/lib/keystore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("TEST_KS_ES_PASSWORD", "123")
+	p := &EnvProvider{Prefix: "TEST_KS_"}
+	v, err := p.Get("es.password")
+	assert.Nil(t, err)
+	assert.Equal(t, "123", string(v))
+
+	_, err = p.Get("not_found")
+	assert.Equal(t, kslib.ErrKeyDoesntExists, err)
+}
+
+func TestDirProvider(t *testing.T) {
+	dir := t.TempDir()
+	assert.Nil(t, os.WriteFile(path.Join(dir, "es_password"), []byte("123\n"), 0600))
+	p := &DirProvider{Path: dir}
+	v, err := p.Get("es_password")
+	assert.Nil(t, err)
+	assert.Equal(t, "123", string(v))
+
+	//updates take effect immediately
+	assert.Nil(t, os.WriteFile(path.Join(dir, "es_password"), []byte("456"), 0600))
+	v, err = p.Get("es_password")
+	assert.Nil(t, err)
+	assert.Equal(t, "456", string(v))
+
+	_, err = p.Get("../es_password")
+	assert.Equal(t, kslib.ErrKeyDoesntExists, err)
+	_, err = p.Get("not_found")
+	assert.Equal(t, kslib.ErrKeyDoesntExists, err)
+}
+
+func TestVaultProvider(t *testing.T) {
+	var requests int32
+	var password atomic.Value
+	password.Store("v1")
+	var down atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		assert.Equal(t, "/v1/secret/data/myapp", r.URL.Path)
+		w.Write([]byte(`{"data":{"data":{"es_password":"` + password.Load().(string) + `","port":9200},"metadata":{"version":1}}}`))
+	}))
+	defer server.Close()
+
+	_, err := NewVaultProvider(server.URL, "invalid", "", "myapp", time.Minute).Get("es_password")
+	assert.NotNil(t, err)
+
+	p := NewVaultProvider(server.URL, "token", "", "/myapp/", time.Hour)
+	v, err := p.Get("es_password")
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", string(v))
+	v, err = p.Get("port")
+	assert.Nil(t, err)
+	assert.Equal(t, "9200", string(v))
+	_, err = p.Get("not_found")
+	assert.Equal(t, kslib.ErrKeyDoesntExists, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	//cached until expired
+	password.Store("v2")
+	v, _ = p.Get("es_password")
+	assert.Equal(t, "v1", string(v))
+	p.Invalidate()
+	v, _ = p.Get("es_password")
+	assert.Equal(t, "v2", string(v))
+
+	//use the stale one if vault is unavailable
+	down.Store(true)
+	p.Invalidate()
+	v, err = p.Get("es_password")
+	assert.Nil(t, err)
+	assert.Equal(t, "v2", string(v))
+}
+
+type staticProvider map[string]string
+
+func (p staticProvider) Name() string {
+	return "static"
+}
+
+func (p staticProvider) Get(key string) ([]byte, error) {
+	if v, ok := p[key]; ok {
+		return []byte(v), nil
+	}
+	return nil, kslib.ErrKeyDoesntExists
+}
+
+func TestRegisterProvider(t *testing.T) {
+	RegisterProvider(staticProvider{"provider_test_key": "static"})
+	v, err := GetValue("provider_test_key")
+	assert.Nil(t, err)
+	assert.Equal(t, "static", string(v))
+}
+
+type failedProvider struct{}
+
+func (p failedProvider) Name() string {
+	return "failed"
+}
+
+func (p failedProvider) Get(key string) ([]byte, error) {
+	if key == "provider_failed_key" {
+		return nil, errors.New("backend unavailable")
+	}
+	return nil, kslib.ErrKeyDoesntExists
+}
+
+func TestResolveError(t *testing.T) {
+	RegisterProvider(failedProvider{})
+
+	_, err := GetValue("provider_failed_key")
+	assert.NotNil(t, err)
+	_, err = GetValue("provider_missing_key")
+	assert.Equal(t, kslib.ErrKeyDoesntExists, err)
+
+	//a missing key resolves to empty, the failure of provider is not swallowed
+	v, _, err := resolveVariable("keystore.provider_missing_key")
+	assert.Nil(t, err)
+	assert.Equal(t, "", v)
+	_, _, err = resolveVariable("keystore.provider_failed_key")
+	assert.NotNil(t, err)
+	_, _, err = resolveVariable("env.provider_failed_key")
+	assert.Equal(t, ucfg.ErrMissing, err)
+}
+
+func TestReloadProviders(t *testing.T) {
+	var called int32
+	RegisterChangeListener(func() {
+		atomic.AddInt32(&called, 1)
+	})
+	reloadProviders()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&called))
+}
@@ -28,6 +28,8 @@
 package kv
 
 import (
+	"time"
+
 	log "github.com/cihub/seelog"
 	"github.com/rubyniu105/framework/core/errors"
 )
@@ -49,11 +51,29 @@ type KVStore interface {
 
 	DeleteKey(bucket string, key []byte) error
 
+	//AddValueWithTTL add value which will be expired after ttl, ttl <= 0 means never expire
+	AddValueWithTTL(bucket string, key []byte, value []byte, ttl time.Duration) error
+
+	//PutIfAbsent add value only if the key is not exists or already expired, return true if the value was written
+	PutIfAbsent(bucket string, key []byte, value []byte, ttl time.Duration) (bool, error)
+
+	//CompareAndSwap replace the value only if the current value equals to old,
+	//nil old means the key must be absent, nil new will delete the key, return true if swapped
+	CompareAndSwap(bucket string, key []byte, old, new []byte, ttl time.Duration) (bool, error)
+
+	//ScanPrefix iterate over unexpired keys with the prefix, stop when the callback returns false
+	ScanPrefix(bucket string, prefix []byte, fn func(key, value []byte) bool) error
+
 	//DeleteBucket(bucket string) error
 }
 
 var handler KVStore
 
+// IsEnabled returns true if any kv store handler is registered
+func IsEnabled() bool {
+	return handler != nil
+}
+
 func getKVHandler() KVStore {
 
 	if handler == nil {
@@ -86,6 +106,22 @@ func DeleteKey(bucket string, key []byte) error {
 	return getKVHandler().DeleteKey(bucket, key)
 }
 
+func AddValueWithTTL(bucket string, key []byte, value []byte, ttl time.Duration) error {
+	return getKVHandler().AddValueWithTTL(bucket, key, value, ttl)
+}
+
+func PutIfAbsent(bucket string, key []byte, value []byte, ttl time.Duration) (bool, error) {
+	return getKVHandler().PutIfAbsent(bucket, key, value, ttl)
+}
+
+func CompareAndSwap(bucket string, key []byte, old, new []byte, ttl time.Duration) (bool, error) {
+	return getKVHandler().CompareAndSwap(bucket, key, old, new, ttl)
+}
+
+func ScanPrefix(bucket string, prefix []byte, fn func(key, value []byte) bool) error {
+	return getKVHandler().ScanPrefix(bucket, prefix, fn)
+}
+
 //func DeleteBucket(bucket string) error {
 //	return getKVHandler().DeleteBucket(bucket)
 //}
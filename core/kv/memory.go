@@ -0,0 +1,168 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package kv
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a non-persistent KVStore, mainly used for testing and single node setups
+type MemoryStore struct {
+	lock    sync.Mutex
+	data    map[string][]byte
+	expires map[string]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: map[string][]byte{}, expires: map[string]time.Time{}}
+}
+
+func memoryKey(bucket string, key []byte) string {
+	return bucket + "," + string(key)
+}
+
+func (m *MemoryStore) Open() error  { return nil }
+func (m *MemoryStore) Close() error { return nil }
+
+func (m *MemoryStore) get(k string) ([]byte, bool) {
+	v, ok := m.data[k]
+	if !ok {
+		return nil, false
+	}
+	if t, ok := m.expires[k]; ok && !time.Now().Before(t) {
+		delete(m.data, k)
+		delete(m.expires, k)
+		return nil, false
+	}
+	return v, true
+}
+
+func (m *MemoryStore) set(k string, value []byte, ttl time.Duration) {
+	m.data[k] = append([]byte{}, value...)
+	if ttl > 0 {
+		m.expires[k] = time.Now().Add(ttl)
+	} else {
+		delete(m.expires, k)
+	}
+}
+
+func (m *MemoryStore) GetValue(bucket string, key []byte) ([]byte, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	v, ok := m.get(memoryKey(bucket, key))
+	if !ok {
+		return nil, nil
+	}
+	return append([]byte{}, v...), nil
+}
+
+func (m *MemoryStore) GetCompressedValue(bucket string, key []byte) ([]byte, error) {
+	return m.GetValue(bucket, key)
+}
+
+func (m *MemoryStore) AddValueCompress(bucket string, key []byte, value []byte) error {
+	return m.AddValue(bucket, key, value)
+}
+
+func (m *MemoryStore) AddValue(bucket string, key []byte, value []byte) error {
+	return m.AddValueWithTTL(bucket, key, value, 0)
+}
+
+func (m *MemoryStore) ExistsKey(bucket string, key []byte) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	_, ok := m.get(memoryKey(bucket, key))
+	return ok, nil
+}
+
+func (m *MemoryStore) DeleteKey(bucket string, key []byte) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	k := memoryKey(bucket, key)
+	delete(m.data, k)
+	delete(m.expires, k)
+	return nil
+}
+
+func (m *MemoryStore) AddValueWithTTL(bucket string, key []byte, value []byte, ttl time.Duration) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.set(memoryKey(bucket, key), value, ttl)
+	return nil
+}
+
+func (m *MemoryStore) PutIfAbsent(bucket string, key []byte, value []byte, ttl time.Duration) (bool, error) {
+	return m.CompareAndSwap(bucket, key, nil, value, ttl)
+}
+
+func (m *MemoryStore) CompareAndSwap(bucket string, key []byte, old, new []byte, ttl time.Duration) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	k := memoryKey(bucket, key)
+	current, ok := m.get(k)
+	if ok != (old != nil) || (ok && !bytes.Equal(current, old)) {
+		return false, nil
+	}
+	if new == nil {
+		delete(m.data, k)
+		delete(m.expires, k)
+	} else {
+		m.set(k, new, ttl)
+	}
+	return true, nil
+}
+
+func (m *MemoryStore) ScanPrefix(bucket string, prefix []byte, fn func(key, value []byte) bool) error {
+	m.lock.Lock()
+	p := memoryKey(bucket, prefix)
+	keys := []string{}
+	for k := range m.data {
+		if strings.HasPrefix(k, p) {
+			if _, ok := m.get(k); ok {
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	values := make([][]byte, len(keys))
+	for i, k := range keys {
+		values[i] = append([]byte{}, m.data[k]...)
+	}
+	m.lock.Unlock()
+
+	for i, k := range keys {
+		if !fn([]byte(k[len(bucket)+1:]), values[i]) {
+			break
+		}
+	}
+	return nil
+}
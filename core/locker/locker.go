@@ -11,6 +11,7 @@ import (
 	"github.com/rubyniu105/framework/core/global"
 	"github.com/rubyniu105/framework/core/kv"
 	"github.com/rubyniu105/framework/core/util"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -22,95 +23,165 @@ type AllocateInfo struct {
 	Bucket    string
 	Name      string
 	Timestamp time.Time
+	//fencing token, increased every time the lock changes hands
+	Token uint64
+
+	//raw value read from kv, used as the expected value of the next swap
+	raw []byte
 }
 
 func GetKey(bucket, name string) []byte {
 	return []byte(bucket + ":" + name)
 }
 
-func placeLock(bucket, name string, clientID string) (bool, error) {
-	v := fmt.Sprintf("%s/%v", clientID, util.GetLowPrecisionCurrentTime().Unix())
-	err := kv.AddValue(parentBucket, GetKey(bucket, name), []byte(v))
-	return true, err
+// lock value: client_id/unix_timestamp/token, a released lock keeps the token with empty client_id
+func encodeLock(clientID string, token uint64) []byte {
+	return []byte(fmt.Sprintf("%s/%v/%v", clientID, util.GetLowPrecisionCurrentTime().Unix(), token))
 }
 
-func GetAllocateInfo(bucket, name string) (bool, *AllocateInfo, error) {
-	v1, err := kv.GetValue(parentBucket, GetKey(bucket, name))
+func decodeLock(bucket, name string, v []byte) (*AllocateInfo, error) {
+	arr := strings.Split(string(v), "/")
+	if len(arr) != 2 && len(arr) != 3 {
+		return nil, errors.Errorf("invalid locker info: %v", string(v))
+	}
+	unix, err := util.ToInt64(arr[1])
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	inf := &AllocateInfo{raw: v}
+	inf.ClientID = arr[0]
+	inf.Timestamp = util.FromUnixTimestamp(unix)
+	inf.Bucket = bucket
+	inf.Name = name
+	//written by older versions without token
+	if len(arr) == 3 {
+		inf.Token, err = strconv.ParseUint(arr[2], 10, 64)
+		if err != nil {
+			return nil, err
+		}
 	}
+	return inf, nil
+}
 
-	inf := &AllocateInfo{}
+// getLock returns the current lock record, nil if the lock was never placed
+func getLock(bucket, name string) (*AllocateInfo, error) {
+	v1, err := kv.GetValue(parentBucket, GetKey(bucket, name))
+	if err != nil {
+		return nil, err
+	}
 	if v1 == nil {
-		//not found
+		return nil, nil
+	}
+	return decodeLock(bucket, name, v1)
+}
+
+// swapLock atomically replaces the record we read, false means someone else changed it first
+func swapLock(bucket, name string, prev *AllocateInfo, clientID string, token uint64) (bool, error) {
+	var old []byte
+	if prev != nil {
+		old = prev.raw
+	}
+	return kv.CompareAndSwap(parentBucket, GetKey(bucket, name), old, encodeLock(clientID, token), 0)
+}
+
+func GetAllocateInfo(bucket, name string) (bool, *AllocateInfo, error) {
+	inf, err := getLock(bucket, name)
+	if err != nil {
+		return false, nil, err
+	}
+	if inf == nil || inf.ClientID == "" {
+		//not found or released
 		return false, nil, nil
-	} else {
-		arr := strings.Split(string(v1), "/")
-		if len(arr) != 2 {
-			return false, nil, errors.Errorf("invalid locker info: %v", string(v1))
-		}
-		unix, err := util.ToInt64(arr[1])
-		if err != nil {
-			return false, nil, err
-		}
-		inf.ClientID = arr[0]
-		inf.Timestamp = util.FromUnixTimestamp(unix)
-		inf.Bucket = bucket
-		inf.Name = name
-		return true, inf, nil
 	}
+	return true, inf, nil
 }
 
 func Hold(bucket, name string, clientID string, expireTimeout time.Duration, allocateIfNot bool) (bool, error) {
-	ok, info, err := GetAllocateInfo(bucket, name)
+	ok, _, err := HoldWithToken(bucket, name, clientID, expireTimeout, allocateIfNot)
+	return ok, err
+}
+
+// HoldWithToken acquires or renews the lock, the returned fencing token only changes when the lock changes hands,
+// so the protected resource can reject writes carrying an older token
+func HoldWithToken(bucket, name string, clientID string, expireTimeout time.Duration, allocateIfNot bool) (bool, uint64, error) {
+	info, err := getLock(bucket, name)
 	if err != nil {
-		panic(err)
+		return false, 0, err
 	}
 
-	if ok {
-		if expireTimeout.Seconds() <= 0 {
-			expireTimeout = time.Duration(30) * time.Second
+	if info == nil || info.ClientID == "" {
+		if global.Env().IsDebug {
+			log.Debug("no one hold this lock, let's hold the lock, client_id:", bucket, name)
 		}
-
-		if info == nil {
-			panic("allocate info can't be nil")
+		var token uint64 = 1
+		if info != nil {
+			token = info.Token + 1
 		}
+		return placeLock(bucket, name, info, clientID, token)
+	}
 
-		if info.ClientID != clientID {
-			if time.Since(info.Timestamp) > expireTimeout {
-				if allocateIfNot {
-					if global.Env().IsDebug {
-						log.Infof("lost someone, taking over: %v, client_id: %v, local_id:%v, duration: %v", string(GetKey(bucket, name)), info.ClientID, clientID, time.Since(info.Timestamp))
-					}
-					return placeLock(bucket, name, clientID)
-				} else {
-					return false, nil
-				}
-			} else {
-				if global.Env().IsDebug {
-					log.Infof("someone already taken this: %v, client_id: %v, local_id:%v, duration: %v", string(GetKey(bucket, name)), info.ClientID, clientID, time.Since(info.Timestamp))
-				}
-				return false, nil
-			}
-		} else {
+	if expireTimeout.Seconds() <= 0 {
+		expireTimeout = time.Duration(30) * time.Second
+	}
+
+	if info.ClientID == clientID {
+		if global.Env().IsDebug {
+			log.Debug("it's me, let's hold the lock again, bucket:", bucket, ", name:", name, ", client_id:", info.ClientID)
+		}
+		//update timestamp to extend the lease
+		return placeLock(bucket, name, info, clientID, info.Token)
+	}
 
+	if time.Since(info.Timestamp) > expireTimeout {
+		if allocateIfNot {
 			if global.Env().IsDebug {
-				log.Debug("it's me, let's hold the lock again, bucket:", bucket, ", name:", name, ", client_id:", info.ClientID)
+				log.Infof("lost someone, taking over: %v, client_id: %v, local_id:%v, duration: %v", string(GetKey(bucket, name)), info.ClientID, clientID, time.Since(info.Timestamp))
 			}
-			//update timestamp to extend the lease
-			return placeLock(bucket, name, clientID)
+			return placeLock(bucket, name, info, clientID, info.Token+1)
 		}
-	} else {
-		if global.Env().IsDebug {
-			log.Debug("no one hold this lock, let's hold the lock, client_id:", bucket, name)
+		return false, 0, nil
+	}
+
+	if global.Env().IsDebug {
+		log.Infof("someone already taken this: %v, client_id: %v, local_id:%v, duration: %v", string(GetKey(bucket, name)), info.ClientID, clientID, time.Since(info.Timestamp))
+	}
+	return false, 0, nil
+}
+
+func placeLock(bucket, name string, prev *AllocateInfo, clientID string, token uint64) (bool, uint64, error) {
+	ok, err := swapLock(bucket, name, prev, clientID, token)
+	if err != nil || !ok {
+		if global.Env().IsDebug && err == nil {
+			log.Debugf("lost the race of lock: %v, local_id:%v", string(GetKey(bucket, name)), clientID)
 		}
-		//not exists
-		return placeLock(bucket, name, clientID)
+		return false, 0, err
+	}
+	return true, token, nil
+}
+
+// ValidateToken checks the lock is still held by the client with the same fencing token
+func ValidateToken(bucket, name string, clientID string, token uint64) (bool, error) {
+	ok, info, err := GetAllocateInfo(bucket, name)
+	if err != nil || !ok {
+		return false, err
 	}
-	return false, nil
+	return info.ClientID == clientID && info.Token == token, nil
+}
+
+// Revoke forcibly releases the lock only if it is still the record described by info
+func Revoke(info *AllocateInfo) (bool, error) {
+	if info == nil || info.raw == nil {
+		return false, errors.New("invalid allocate info")
+	}
+	return swapLock(info.Bucket, info.Name, info, "", info.Token)
 }
 
 func Release(bucket, name string, clientID string) error {
+	return ReleaseWithToken(bucket, name, clientID, 0)
+}
+
+// ReleaseWithToken releases the lock, a non-zero token must match the current holder's
+func ReleaseWithToken(bucket, name string, clientID string, token uint64) error {
 
 	ok, info, err := GetAllocateInfo(bucket, name)
 	if err != nil {
@@ -118,14 +189,18 @@ func Release(bucket, name string, clientID string) error {
 	}
 
 	if ok {
-		if info.ClientID != clientID {
+		if info.ClientID != clientID || (token > 0 && info.Token != token) {
 			//not your business
-			return errors.Errorf("not your business anymore, client_id: %v, local_id:%v", info.ClientID, clientID)
+			return errors.Errorf("not your business anymore, client_id: %v, local_id:%v, token: %v, local_token: %v", info.ClientID, clientID, info.Token, token)
 		}
-		err := kv.DeleteKey(parentBucket, GetKey(bucket, name))
+		//keep the token, so that the next holder gets a larger one
+		swapped, err := swapLock(bucket, name, info, "", info.Token)
 		if err != nil {
 			return err
 		}
+		if !swapped {
+			return errors.Errorf("lock [%v] was changed by others while releasing", string(GetKey(bucket, name)))
+		}
 	}
 	return nil
 }
@@ -0,0 +1,92 @@
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package locker
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rubyniu105/framework/core/kv"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	kv.Register("memory", kv.NewMemoryStore())
+}
+
+func TestHoldWithToken(t *testing.T) {
+	ok, token, err := HoldWithToken("test", "token", "a", time.Minute, true)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), token)
+
+	//renew keeps the token
+	ok, token, _ = HoldWithToken("test", "token", "a", time.Minute, true)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), token)
+
+	ok, _, _ = HoldWithToken("test", "token", "b", time.Minute, true)
+	assert.False(t, ok)
+
+	assert.NotNil(t, ReleaseWithToken("test", "token", "a", 2))
+	assert.Nil(t, ReleaseWithToken("test", "token", "a", 1))
+
+	exists, _, _ := GetAllocateInfo("test", "token")
+	assert.False(t, exists)
+
+	//the next holder gets a larger token
+	ok, token, _ = HoldWithToken("test", "token", "b", time.Minute, true)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), token)
+
+	valid, _ := ValidateToken("test", "token", "b", 2)
+	assert.True(t, valid)
+	valid, _ = ValidateToken("test", "token", "a", 1)
+	assert.False(t, valid)
+}
+
+func TestTakeOverExpiredLock(t *testing.T) {
+	assert.Nil(t, kv.AddValue(parentBucket, GetKey("test", "expired"), []byte("a/1/5")))
+
+	ok, _, _ := HoldWithToken("test", "expired", "b", time.Minute, false)
+	assert.False(t, ok)
+
+	ok, token, _ := HoldWithToken("test", "expired", "b", time.Minute, true)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(6), token)
+
+	//lock written by older versions has no token
+	assert.Nil(t, kv.AddValue(parentBucket, GetKey("test", "legacy"), []byte("a/1")))
+	exists, info, err := GetAllocateInfo("test", "legacy")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "a", info.ClientID)
+	assert.Equal(t, uint64(0), info.Token)
+
+	revoked, _ := Revoke(info)
+	assert.True(t, revoked)
+	revoked, _ = Revoke(info)
+	assert.False(t, revoked)
+}
+
+func TestConcurrentHold(t *testing.T) {
+	var winners int32
+	wg := sync.WaitGroup{}
+	for _, id := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			ok, _, err := HoldWithToken("test", "concurrent", id, time.Minute, true)
+			assert.Nil(t, err)
+			if ok {
+				atomic.AddInt32(&winners, 1)
+			}
+		}(id)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), winners)
+}
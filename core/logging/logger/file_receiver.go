@@ -66,13 +66,8 @@ func (ar *FileReceiver) AfterParse(initArgs log.CustomReceiverInitArgs) error {
 func (ar *FileReceiver) Flush() {
 }
 
-// Close logs
+// Close logs, the writers are shared by the loggers replaced at runtime, rotate closes them on shutdown
 func (ar *FileReceiver) Close() error {
-	if ar.writer != nil {
-		if wc, ok := ar.writer.(io.WriteCloser); ok {
-			return wc.Close()
-		}
-	}
 	return nil
 }
 
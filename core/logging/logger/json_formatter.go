@@ -0,0 +1,135 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/rubyniu105/framework/core/global"
+	"github.com/rubyniu105/framework/core/util"
+)
+
+// JSONFormat outputs one json object per line, the fields are stable for log shippers
+const JSONFormat = "json"
+
+const jsonTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+type jsonRecord struct {
+	Timestamp string                 `json:"timestamp"`
+	Level     string                 `json:"level"`
+	File      string                 `json:"file"`
+	Line      int                    `json:"line"`
+	Func      string                 `json:"func"`
+	Message   string                 `json:"message"`
+	NodeID    string                 `json:"node_id,omitempty"`
+	Context   map[string]interface{} `json:"context,omitempty"`
+}
+
+var jsonFields map[string]string
+var jsonFieldsLock sync.RWMutex
+
+func setJSONFields(fields map[string]string) {
+	jsonFieldsLock.Lock()
+	defer jsonFieldsLock.Unlock()
+	jsonFields = fields
+}
+
+// getJSONContext merges the static fields with the custom context of the logger, set by log.Current.SetContext
+func getJSONContext(context log.LogContextInterface) map[string]interface{} {
+	jsonFieldsLock.RLock()
+	defer jsonFieldsLock.RUnlock()
+
+	var output map[string]interface{}
+	if len(jsonFields) > 0 {
+		output = make(map[string]interface{}, len(jsonFields))
+		for k, v := range jsonFields {
+			output[k] = v
+		}
+	}
+
+	var custom map[string]interface{}
+	switch v := context.CustomContext().(type) {
+	case map[string]interface{}:
+		custom = v
+	case util.MapStr:
+		custom = v
+	case map[string]string:
+		custom = make(map[string]interface{}, len(v))
+		for k, v1 := range v {
+			custom[k] = v1
+		}
+	}
+	if len(custom) > 0 && output == nil {
+		output = make(map[string]interface{}, len(custom))
+	}
+	for k, v := range custom {
+		output[k] = v
+	}
+	return output
+}
+
+// getNodeID must not trigger the lazy env init, which logs and would block the logger
+func getNodeID() string {
+	if !global.EnvRegistered() {
+		return ""
+	}
+	env := global.Env()
+	if env.SystemConfig == nil {
+		return ""
+	}
+	return env.SystemConfig.NodeConfig.ID
+}
+
+func formatJSON(message string, level log.LogLevel, context log.LogContextInterface) string {
+	t := context.CallTime()
+	if t.IsZero() {
+		t = time.Now()
+	}
+	record := jsonRecord{
+		Timestamp: t.Format(jsonTimeFormat),
+		Level:     level.String(),
+		File:      context.ShortPath(),
+		Line:      context.Line(),
+		Func:      context.Func(),
+		Message:   message,
+		NodeID:    getNodeID(),
+		Context:   getJSONContext(context),
+	}
+	return util.UnsafeBytesToString(util.MustToJSONBytes(record))
+}
+
+func createJSONFormatter(params string) log.FormatterFunc {
+	return func(message string, level log.LogLevel, context log.LogContextInterface) interface{} {
+		return formatJSON(message, level, context)
+	}
+}
+
+func init() {
+	err := log.RegisterCustomFormatter("JSON", createJSONFormatter)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"testing"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/rubyniu105/framework/core/config"
+	"github.com/rubyniu105/framework/core/util"
+	"github.com/stretchr/testify/assert"
+)
+
+type testLogContext struct {
+	custom interface{}
+}
+
+func (c testLogContext) Func() string      { return "logger.TestJSONFormat" }
+func (c testLogContext) Line() int         { return 42 }
+func (c testLogContext) ShortPath() string { return "core/logging/logger/json_formatter_test.go" }
+func (c testLogContext) FullPath() string  { return "/" + c.ShortPath() }
+func (c testLogContext) FileName() string  { return "json_formatter_test.go" }
+func (c testLogContext) IsValid() bool     { return true }
+func (c testLogContext) CallTime() time.Time {
+	return time.Date(2024, 3, 5, 8, 9, 10, 123000000, time.UTC)
+}
+func (c testLogContext) CustomContext() interface{} { return c.custom }
+
+func TestJSONFormat(t *testing.T) {
+	setJSONFields(map[string]string{"service": "gateway"})
+	defer setJSONFields(nil)
+
+	line := formatJSON(`say "hello"`, log.WarnLvl, testLogContext{custom: map[string]interface{}{"request_id": "abc"}})
+	obj := util.MapStr{}
+	assert.Nil(t, util.FromJSONBytes([]byte(line), &obj))
+	assert.Equal(t, "2024-03-05T08:09:10.123Z", obj["timestamp"])
+	assert.Equal(t, "warn", obj["level"])
+	assert.Equal(t, "core/logging/logger/json_formatter_test.go", obj["file"])
+	assert.Equal(t, float64(42), obj["line"])
+	assert.Equal(t, "logger.TestJSONFormat", obj["func"])
+	assert.Equal(t, `say "hello"`, obj["message"])
+	assert.Equal(t, map[string]interface{}{"service": "gateway", "request_id": "abc"}, obj["context"])
+}
+
+func TestRotateConfig(t *testing.T) {
+	compress := false
+	cfg := getRotateConfig(config.LogRotateConfig{MaxFileSizeInMB: 10, MaxFileAgeInDays: 7, Compress: &compress})
+	assert.Equal(t, 10, cfg.MaxFileSize)
+	assert.Equal(t, 10, cfg.MaxFileCount)
+	assert.Equal(t, 7, cfg.MaxFileAge)
+	assert.False(t, cfg.Compress)
+
+	assert.Equal(t, log.LogLevel(log.InfoLvl), getLogLevel("", log.InfoLvl))
+	assert.Equal(t, log.LogLevel(log.ErrorLvl), getLogLevel("ERROR", log.InfoLvl))
+	assert.Equal(t, log.LogLevel(log.DebugLvl), getLogLevel("invalid", log.DebugLvl))
+}
@@ -0,0 +1,104 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/cihub/seelog"
+	"github.com/rubyniu105/framework/core/config"
+	"github.com/rubyniu105/framework/core/errors"
+)
+
+type levelConstraints interface {
+	IsAllowed(level log.LogLevel) bool
+}
+
+// getConstraints allows the logs at or above the level
+func getConstraints(level log.LogLevel) (levelConstraints, error) {
+	if level >= log.Off {
+		c, err := log.NewOffConstraints()
+		return c, err
+	}
+	c, err := log.NewMinMaxConstraints(level, log.CriticalLvl)
+	return c, err
+}
+
+// getOverridePattern returns the source file pattern of the override, file takes precedence over package
+func getOverridePattern(override config.LogLevelOverride) (string, error) {
+	if override.File != "" {
+		return override.File, nil
+	}
+	if override.Package != "" {
+		return "*" + strings.Trim(override.Package, "/") + "/*", nil
+	}
+	return "", errors.New("package or file is required")
+}
+
+func newLevelException(override config.LogLevelOverride) (*log.LogLevelException, log.LogLevel, error) {
+	level, ok := log.LogLevelFromString(strings.ToLower(override.Level))
+	if !ok {
+		return nil, level, errors.Errorf("invalid log level: %v", override.Level)
+	}
+	pattern, err := getOverridePattern(override)
+	if err != nil {
+		return nil, level, err
+	}
+	constraints, err := getConstraints(level)
+	if err != nil {
+		return nil, level, err
+	}
+	exception, err := log.NewLogLevelException("*", pattern, constraints)
+	return exception, level, err
+}
+
+// ValidateLogLevelOverrides checks the levels and patterns of the overrides
+func ValidateLogLevelOverrides(overrides []config.LogLevelOverride) error {
+	for _, override := range overrides {
+		if _, _, err := newLevelException(override); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getExceptions maps the overrides to seelog exceptions, also returns the lowest level allowed,
+// receivers without their own level must not drop the logs allowed by the overrides
+func getExceptions(overrides []config.LogLevelOverride, level log.LogLevel) ([]*log.LogLevelException, log.LogLevel) {
+	exceptions := []*log.LogLevelException{}
+	minLevel := level
+	for _, override := range overrides {
+		exception, l, err := newLevelException(override)
+		if err != nil {
+			fmt.Println("invalid log level override:", err)
+			continue
+		}
+		exceptions = append(exceptions, exception)
+		if l < minLevel {
+			minLevel = l
+		}
+	}
+	return exceptions, minLevel
+}
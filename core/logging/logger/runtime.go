@@ -0,0 +1,162 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/rubyniu105/framework/core/config"
+	"github.com/rubyniu105/framework/core/errors"
+)
+
+// LogLevels is the runtime view of the log levels
+type LogLevels struct {
+	Level     string                    `json:"level"`
+	Overrides []config.LogLevelOverride `json:"overrides,omitempty"`
+	RevertAt  *time.Time                `json:"revert_at,omitempty"`
+}
+
+var runtimeLock sync.Mutex
+
+// levels to restore when the temporary change expires
+var revertTo *LogLevels
+var revertAt time.Time
+var revertTimer *time.Timer
+var revertSeq uint64
+
+// GetLogLevels returns the current log levels
+func GetLogLevels() (*LogLevels, error) {
+	runtimeLock.Lock()
+	defer runtimeLock.Unlock()
+
+	cfg := GetLoggingConfig()
+	if cfg == nil {
+		return nil, errors.New("logging is not initialized")
+	}
+	levels := &LogLevels{Level: cfg.LogLevel, Overrides: cfg.Overrides}
+	if revertTo != nil {
+		t := revertAt
+		levels.RevertAt = &t
+	}
+	return levels, nil
+}
+
+// UpdateLogLevels changes the global level and the overrides at runtime, empty level or nil overrides keep the current ones,
+// the change is reverted after the ttl if it is positive, temporary changes in a row revert to the levels before the first one
+func UpdateLogLevels(level string, overrides []config.LogLevelOverride, ttl time.Duration) error {
+	if level != "" {
+		if _, ok := log.LogLevelFromString(strings.ToLower(level)); !ok {
+			return errors.Errorf("invalid log level: %v", level)
+		}
+	}
+	if err := ValidateLogLevelOverrides(overrides); err != nil {
+		return err
+	}
+
+	runtimeLock.Lock()
+	defer runtimeLock.Unlock()
+
+	cfg := GetLoggingConfig()
+	if cfg == nil {
+		return errors.New("logging is not initialized")
+	}
+
+	stopRevertTimer()
+	if ttl > 0 {
+		if revertTo == nil {
+			revertTo = &LogLevels{Level: cfg.LogLevel, Overrides: cfg.Overrides}
+		}
+	} else {
+		revertTo = nil
+	}
+
+	newCfg := *cfg
+	if level != "" {
+		newCfg.LogLevel = level
+	}
+	if overrides != nil {
+		newCfg.Overrides = append([]config.LogLevelOverride{}, overrides...)
+	}
+	applyLoggingConfig(&newCfg)
+
+	if ttl > 0 {
+		revertAt = time.Now().Add(ttl)
+		seq := revertSeq
+		revertTimer = time.AfterFunc(ttl, func() {
+			runtimeLock.Lock()
+			defer runtimeLock.Unlock()
+			//superseded by a later change
+			if seq != revertSeq {
+				return
+			}
+			revertLogLevels()
+		})
+		log.Infof("log level changed to [%v] with %v overrides, revert in %v", newCfg.LogLevel, len(newCfg.Overrides), ttl)
+	} else {
+		log.Infof("log level changed to [%v] with %v overrides", newCfg.LogLevel, len(newCfg.Overrides))
+	}
+	return nil
+}
+
+// RevertLogLevels restores the levels before the temporary change, returns false if there is nothing to revert
+func RevertLogLevels() bool {
+	runtimeLock.Lock()
+	defer runtimeLock.Unlock()
+	return revertLogLevels()
+}
+
+func revertLogLevels() bool {
+	cfg := GetLoggingConfig()
+	if revertTo == nil || cfg == nil {
+		return false
+	}
+	stopRevertTimer()
+
+	newCfg := *cfg
+	newCfg.LogLevel = revertTo.Level
+	newCfg.Overrides = revertTo.Overrides
+	revertTo = nil
+	applyLoggingConfig(&newCfg)
+
+	log.Infof("log level reverted to [%v] with %v overrides", newCfg.LogLevel, len(newCfg.Overrides))
+	return true
+}
+
+func stopRevertTimer() {
+	revertSeq++
+	if revertTimer != nil {
+		revertTimer.Stop()
+		revertTimer = nil
+	}
+}
+
+func applyLoggingConfig(cfg *config.LoggingConfig) {
+	loggingLock.RLock()
+	appName, baseDir := loggingAppName, loggingBaseDir
+	loggingLock.RUnlock()
+	SetLogging(cfg, appName, baseDir)
+}
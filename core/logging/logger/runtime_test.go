@@ -0,0 +1,140 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package logger
+
+import (
+	"testing"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/rubyniu105/framework/core/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type pathLogContext struct {
+	testLogContext
+	path string
+}
+
+func (c pathLogContext) FullPath() string { return c.path }
+
+func TestLevelOverrides(t *testing.T) {
+	exceptions, minLevel := getExceptions([]config.LogLevelOverride{
+		{Package: "modules/pipeline", Level: "trace"},
+		{File: "*/elastic/bulk_processor.go", Level: "error"},
+		{Level: "debug"},
+	}, log.InfoLvl)
+	assert.Equal(t, 2, len(exceptions))
+	assert.Equal(t, log.LogLevel(log.TraceLvl), minLevel)
+
+	pipeline := pathLogContext{path: "/src/framework/modules/pipeline/pipeline.go"}
+	assert.True(t, exceptions[0].MatchesContext(pipeline))
+	assert.True(t, exceptions[0].IsAllowed(log.TraceLvl))
+	assert.False(t, exceptions[1].MatchesContext(pipeline))
+
+	bulk := pathLogContext{path: "/src/framework/core/elastic/bulk_processor.go"}
+	assert.False(t, exceptions[0].MatchesContext(bulk))
+	assert.True(t, exceptions[1].MatchesContext(bulk))
+	assert.False(t, exceptions[1].IsAllowed(log.WarnLvl))
+
+	assert.NotNil(t, ValidateLogLevelOverrides([]config.LogLevelOverride{{Package: "modules/pipeline", Level: "verbose"}}))
+	assert.NotNil(t, ValidateLogLevelOverrides([]config.LogLevelOverride{{Level: "debug"}}))
+	assert.Nil(t, ValidateLogLevelOverrides([]config.LogLevelOverride{{Package: "modules/pipeline", Level: "off"}}))
+}
+
+func TestMinLogLevel(t *testing.T) {
+	assert.Equal(t, log.LogLevel(log.DebugLvl), getMinLogLevel(log.InfoLvl, log.DebugLvl, log.WarnLvl))
+	assert.Equal(t, log.LogLevel(log.Off), getMinLogLevel())
+
+	//a console level more verbose than the log level is not dropped by the global constraint
+	constraints, err := getConstraints(getMinLogLevel(getLogLevel("info", log.InfoLvl), getLogLevel("debug", log.InfoLvl)))
+	assert.Nil(t, err)
+	assert.True(t, constraints.IsAllowed(log.DebugLvl))
+	assert.False(t, constraints.IsAllowed(log.TraceLvl))
+}
+
+func TestGlobalLogLevel(t *testing.T) {
+	cfg := &config.LoggingConfig{
+		LogLevel:          "info",
+		DisableFileOutput: true,
+		Overrides:         []config.LogLevelOverride{{Package: "modules/pipeline", Level: "debug"}},
+	}
+	l := getLogLevel(cfg.LogLevel, log.InfoLvl)
+	exceptions, _ := getExceptions(cfg.Overrides, l)
+	assert.Equal(t, log.LogLevel(log.InfoLvl), getGlobalLogLevel(cfg, l))
+
+	constraints, err := getConstraints(getGlobalLogLevel(cfg, l))
+	assert.Nil(t, err)
+	loggerConfig := log.NewLoggerConfig(constraints, exceptions, nil)
+
+	//only the package with the override logs at debug
+	pipeline := pathLogContext{path: "/src/framework/modules/pipeline/pipeline.go"}
+	assert.True(t, loggerConfig.IsAllowed(log.DebugLvl, pipeline))
+	other := pathLogContext{path: "/src/framework/modules/elastic/api.go"}
+	assert.False(t, loggerConfig.IsAllowed(log.DebugLvl, other))
+	assert.True(t, loggerConfig.IsAllowed(log.InfoLvl, other))
+
+	//a sink level set explicitly lowers the global level
+	cfg.ConsoleLogLevel = "trace"
+	assert.Equal(t, log.LogLevel(log.TraceLvl), getGlobalLogLevel(cfg, l))
+	cfg.ConsoleLogLevel = ""
+	cfg.FileLogLevel = "debug"
+	assert.Equal(t, log.LogLevel(log.InfoLvl), getGlobalLogLevel(cfg, l))
+	cfg.DisableFileOutput = false
+	assert.Equal(t, log.LogLevel(log.DebugLvl), getGlobalLogLevel(cfg, l))
+}
+
+func TestUpdateLogLevels(t *testing.T) {
+	SetLogging(&config.LoggingConfig{LogLevel: "info", DisableFileOutput: true}, "test", "")
+
+	assert.NotNil(t, UpdateLogLevels("verbose", nil, 0))
+	assert.False(t, RevertLogLevels())
+
+	overrides := []config.LogLevelOverride{{Package: "modules/pipeline", Level: "debug"}}
+	assert.Nil(t, UpdateLogLevels("warn", overrides, time.Hour))
+	levels, err := GetLogLevels()
+	assert.Nil(t, err)
+	assert.Equal(t, "warn", levels.Level)
+	assert.Equal(t, overrides, levels.Overrides)
+	assert.NotNil(t, levels.RevertAt)
+
+	//a temporary change in a row still reverts to the original levels
+	assert.Nil(t, UpdateLogLevels("error", nil, 50*time.Millisecond))
+	levels, _ = GetLogLevels()
+	assert.Equal(t, "error", levels.Level)
+	assert.Equal(t, overrides, levels.Overrides)
+
+	time.Sleep(200 * time.Millisecond)
+	levels, _ = GetLogLevels()
+	assert.Equal(t, "info", levels.Level)
+	assert.Equal(t, 0, len(levels.Overrides))
+	assert.Nil(t, levels.RevertAt)
+	assert.False(t, RevertLogLevels())
+
+	//a permanent change has nothing to revert
+	assert.Nil(t, UpdateLogLevels("debug", nil, 0))
+	assert.False(t, RevertLogLevels())
+	levels, _ = GetLogLevels()
+	assert.Equal(t, "debug", levels.Level)
+}
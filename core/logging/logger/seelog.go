@@ -54,6 +54,7 @@ import (
 var file string
 var loggingLock sync.RWMutex
 var loggingConfig *config.LoggingConfig
+var loggingAppName, loggingBaseDir string
 
 var oldQuoteStr = []byte("\"")
 var newQuoteStr = []byte("”")
@@ -84,6 +85,8 @@ func SetLogging(loggingCfg *config.LoggingConfig, appName string, baseDir string
 	}
 	loggingLock.Lock()
 	loggingConfig = loggingCfg
+	loggingAppName = appName
+	loggingBaseDir = baseDir
 	loggingLock.Unlock()
 
 	if loggingConfig.FuncFilterPattern == "" {
@@ -102,7 +105,10 @@ func SetLogging(loggingCfg *config.LoggingConfig, appName string, baseDir string
 	consoleWriter, _ := NewConsoleWriter()
 
 	format := "[%Date(01-02) %Time] [%LEV] [%File:%Line] %Msg%n"
-	if loggingConfig.LogFormat != "" {
+	if strings.ToLower(loggingConfig.LogFormat) == JSONFormat {
+		setJSONFields(loggingConfig.Fields)
+		format = "%JSON%n"
+	} else if loggingConfig.LogFormat != "" {
 		format = loggingConfig.LogFormat
 	}
 	formatter, err := log.NewFormatter(format)
@@ -110,11 +116,14 @@ func SetLogging(loggingCfg *config.LoggingConfig, appName string, baseDir string
 		fmt.Println(err)
 	}
 
-	l, _ := log.LogLevelFromString(strings.ToLower(loggingConfig.LogLevel))
-	pushl, _ := log.LogLevelFromString(strings.ToLower(loggingConfig.PushLogLevel))
+	l := getLogLevel(loggingConfig.LogLevel, log.InfoLvl)
+	exceptions, minLevel := getExceptions(loggingConfig.Overrides, l)
+	pushl := getLogLevel(loggingConfig.PushLogLevel, l)
+	consoleLevel := getLogLevel(loggingConfig.ConsoleLogLevel, minLevel)
+	fileLevel := getLogLevel(loggingConfig.FileLogLevel, minLevel)
 
 	//logging receivers
-	consoleReceiver := NewFileReceiver(consoleWriter, l)
+	consoleReceiver := NewFileReceiver(consoleWriter, consoleLevel)
 	consoleOutput, err := log.NewCustomReceiverDispatcherByValue(formatter, consoleReceiver, "console", log.CustomReceiverInitArgs{})
 	receivers := []interface{}{consoleOutput}
 
@@ -125,14 +134,8 @@ func SetLogging(loggingCfg *config.LoggingConfig, appName string, baseDir string
 			file = "./log/" + appName + ".log"
 		}
 
-		cfg1 := rotate.RotateConfig{
-			Compress:     true,
-			MaxFileAge:   0,
-			MaxFileCount: 10,
-			MaxFileSize:  100,
-		}
-		fileHandler := rotate.GetFileHandler(file, cfg1)
-		fileReceiver := NewFileReceiver(fileHandler, l)
+		fileHandler := rotate.GetFileHandler(file, getRotateConfig(loggingConfig.Rotate))
+		fileReceiver := NewFileReceiver(fileHandler, fileLevel)
 		realtimeOutput, err := log.NewCustomReceiverDispatcherByValue(formatter, fileReceiver, "file", log.CustomReceiverInitArgs{})
 		if err != nil {
 			fmt.Println(err)
@@ -155,13 +158,11 @@ func SetLogging(loggingCfg *config.LoggingConfig, appName string, baseDir string
 		fmt.Println(err)
 	}
 
-	globalConstraints, err := log.NewMinMaxConstraints(log.TraceLvl, log.DebugLvl)
+	globalConstraints, err := getConstraints(getGlobalLogLevel(loggingConfig, l))
 	if err != nil {
 		panic(err)
 	}
 
-	exceptions := []*log.LogLevelException{}
-
 	logger := log.NewAsyncLoopLogger(log.NewLoggerConfig(globalConstraints, exceptions, root))
 	err = log.ReplaceLogger(logger)
 	if err != nil {
@@ -170,6 +171,59 @@ func SetLogging(loggingCfg *config.LoggingConfig, appName string, baseDir string
 
 }
 
+func getLogLevel(level string, defaultLevel log.LogLevel) log.LogLevel {
+	if level == "" {
+		return defaultLevel
+	}
+	l, ok := log.LogLevelFromString(strings.ToLower(level))
+	if !ok {
+		fmt.Println("invalid log level:", level)
+		return defaultLevel
+	}
+	return l
+}
+
+// getGlobalLogLevel returns the level of the logs without a matched override, the most verbose of
+// the log level and the sink levels set explicitly, the override levels are only allowed by the exceptions
+func getGlobalLogLevel(cfg *config.LoggingConfig, level log.LogLevel) log.LogLevel {
+	levels := []log.LogLevel{level, getLogLevel(cfg.ConsoleLogLevel, level)}
+	if !cfg.DisableFileOutput {
+		levels = append(levels, getLogLevel(cfg.FileLogLevel, level))
+	}
+	if cfg.RealtimePushEnabled {
+		levels = append(levels, getLogLevel(cfg.PushLogLevel, level))
+	}
+	return getMinLogLevel(levels...)
+}
+
+// getMinLogLevel returns the most verbose level of the levels
+func getMinLogLevel(levels ...log.LogLevel) log.LogLevel {
+	minLevel := log.LogLevel(log.Off)
+	for _, level := range levels {
+		if level < minLevel {
+			minLevel = level
+		}
+	}
+	return minLevel
+}
+
+func getRotateConfig(cfg config.LogRotateConfig) rotate.RotateConfig {
+	output := rotate.DefaultConfig
+	if cfg.MaxFileSizeInMB > 0 {
+		output.MaxFileSize = cfg.MaxFileSizeInMB
+	}
+	if cfg.MaxFileCount > 0 {
+		output.MaxFileCount = cfg.MaxFileCount
+	}
+	if cfg.MaxFileAgeInDays > 0 {
+		output.MaxFileAge = cfg.MaxFileAgeInDays
+	}
+	if cfg.Compress != nil {
+		output.Compress = *cfg.Compress
+	}
+	return output
+}
+
 // GetLoggingConfig return logging configs
 func GetLoggingConfig() *config.LoggingConfig {
 	loggingLock.RLock()
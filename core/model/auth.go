@@ -0,0 +1,50 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package model
+
+import (
+	"github.com/rubyniu105/framework/lib/go-ucfg"
+)
+
+// APIKey is sent as `Authorization: ApiKey base64(id:key)`, or as is when only the encoded key was provided
+type APIKey struct {
+	ID  string            `json:"id,omitempty" config:"id" elastic_mapping:"id:{type:keyword}"`
+	Key ucfg.SecretString `json:"key,omitempty" config:"key" yaml:"key" elastic_mapping:"key:{type:keyword}"`
+}
+
+// ClientCert is used for mutual tls, certs can be provided either by file path or by pem content
+type ClientCert struct {
+	CertFile string            `json:"cert_file,omitempty" config:"cert_file" elastic_mapping:"cert_file:{type:keyword}"`
+	KeyFile  string            `json:"key_file,omitempty" config:"key_file" elastic_mapping:"key_file:{type:keyword}"`
+	CAFile   string            `json:"ca_file,omitempty" config:"ca_file" elastic_mapping:"ca_file:{type:keyword}"`
+	Cert     string            `json:"cert,omitempty" config:"cert" elastic_mapping:"cert:{type:keyword,index:false}"`
+	Key      ucfg.SecretString `json:"key,omitempty" config:"key" yaml:"key" elastic_mapping:"key:{type:keyword,index:false}"`
+	CA       string            `json:"ca,omitempty" config:"ca" elastic_mapping:"ca:{type:keyword,index:false}"`
+
+	SkipDomainVerify bool `json:"skip_domain_verify,omitempty" config:"skip_domain_verify"`
+}
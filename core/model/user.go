@@ -32,3 +32,13 @@ type UserInfo struct {
 	ID   string `json:"id" elastic_mapping:"id: { type: keyword }"`
 	Name string `json:"name" elastic_mapping:"name: { type: keyword }"`
 }
+
+// Role grants a set of api permissions, users are bound to roles by their groups
+type Role struct {
+	Name        string   `json:"name" config:"name" elastic_mapping:"name: { type: keyword }"`
+	Description string   `json:"description,omitempty" config:"description" elastic_mapping:"description: { type: text }"`
+	Permissions []string `json:"permissions" config:"permissions" elastic_mapping:"permissions: { type: keyword }"`
+}
+
+// BuiltinAdminRole is allowed to access every api
+var BuiltinAdminRole = Role{Name: "admin", Description: "full access to all apis", Permissions: []string{"*"}}
@@ -74,10 +74,13 @@ type ORM interface {
 
 	GetBy(field string, value interface{}, o interface{}) (error, Result)
 
+	//query of Count and DeleteBy can be *Query or []*Cond, which are supported by all the backends,
+	//raw dsl in []byte is only supported by elasticsearch
 	Count(o interface{}, query interface{}) (int64, error)
 
 	GroupBy(o interface{}, selectField, groupField string, haveQuery string, haveValue interface{}) (error, map[string]interface{})
 	DeleteBy(o interface{}, query interface{}) error
+	//query of UpdateBy can be *UpdateByRequest, or raw update by query dsl in []byte for elasticsearch
 	UpdateBy(o interface{}, query interface{}) error
 }
 
@@ -122,6 +125,13 @@ type Query struct {
 	IndexName      string
 }
 
+// UpdateByRequest merges Doc into every object matched by Query,
+// it is used by backends which can't run update scripts
+type UpdateByRequest struct {
+	Query *Query
+	Doc   util.MapStr
+}
+
 type TemplatedQuery struct {
 	TemplateID string                 `json:"id"`
 	Parameters map[string]interface{} `json:"params"`
@@ -492,6 +502,11 @@ func InitSchema() error {
 
 var handler ORM
 
+// IsEnabled returns true if any ORM handler is registered
+func IsEnabled() bool {
+	return handler != nil
+}
+
 func getHandler() ORM {
 	if handler == nil {
 		panic(errors.New("ORM handler is not registered"))
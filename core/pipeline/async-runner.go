@@ -29,11 +29,7 @@ import (
 	"runtime"
 )
 
-func runAsync(job *Job, ctx *Context) {
-
-	if job.onFailure != nil {
-		defer job.onFailure.Process(ctx)
-	}
+func runAsync(job *Job, ctx *Context) bool {
 
 	var ch chan bool
 	ch = make(chan bool, len(job.tasks)*2)
@@ -47,6 +43,7 @@ func runAsync(job *Job, ctx *Context) {
 
 	for _, task := range job.tasks {
 		go func(task Processor) {
+			var ok bool
 			defer func() {
 				if !global.Env().IsDebug {
 					if r := recover(); r != nil {
@@ -62,15 +59,30 @@ func runAsync(job *Job, ctx *Context) {
 						log.Error(r, v)
 					}
 				}
+				ch <- ok
 			}()
 
-			task.Process(ctx)
-			ch <- true
+			err := task.Process(ctx)
+			if err != nil {
+				log.Errorf("failed to run processor [%v], %v", task.Name(), err)
+				return
+			}
+			ok = true
 		}(task)
 	}
 
+	succeed := true
 	for i := 0; i < waitSignal; i++ {
-		_ = <-ch
+		if !<-ch {
+			succeed = false
+		}
+	}
+
+	if !succeed {
+		if job.onFailure != nil {
+			job.onFailure.Process(ctx)
+		}
+		return false
 	}
 
 	if job.onComplete != nil {
@@ -78,4 +90,5 @@ func runAsync(job *Job, ctx *Context) {
 			v.Process(ctx)
 		}
 	}
+	return true
 }
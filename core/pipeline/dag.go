@@ -52,17 +52,16 @@ func (dag *Dag) lastJob() *Job {
 	return dag.jobs[jobsCount-1]
 }
 
-func (dag *Dag) Parse(dsl string) *Dag {
-	return dag
-}
-
 // Run starts the tasks
 // It will block until all functions are done
 func (dag *Dag) Run(ctx *Context) {
 
 	//fmt.Println("total jobs:",len(dag.jobs))
 	for _, job := range dag.jobs {
-		run(job, ctx)
+		//stop the following jobs once a job failed
+		if !run(job, ctx) {
+			break
+		}
 	}
 
 }
@@ -0,0 +1,247 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pipeline
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/rubyniu105/framework/core/config"
+	"github.com/rubyniu105/framework/core/errors"
+)
+
+// DAG DSL, stages are joined by `->`, parallel stages are wrapped by `[]`,
+// hooks are attached to the stage right before them:
+//
+//	a -> b -> [c, d] on_complete e -> f on_failure g on_complete [h, i]
+//
+// consecutive single processors are merged into one sequential job.
+
+const onFailureKeyword = "on_failure"
+const onCompleteKeyword = "on_complete"
+
+type dslToken struct {
+	value string
+	pos   int
+}
+
+func tokenizeDSL(dsl string) ([]dslToken, error) {
+	tokens := []dslToken{}
+	runes := []rune(dsl)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '-' && i+1 < len(runes) && runes[i+1] == '>':
+			tokens = append(tokens, dslToken{"->", i})
+			i += 2
+		case c == '[' || c == ']' || c == ',':
+			tokens = append(tokens, dslToken{string(c), i})
+			i++
+		case isDSLNameRune(c):
+			start := i
+			for i < len(runes) && isDSLNameRune(runes[i]) && !(runes[i] == '-' && i+1 < len(runes) && runes[i+1] == '>') {
+				i++
+			}
+			tokens = append(tokens, dslToken{string(runes[start:i]), start})
+		default:
+			return nil, errors.Errorf("invalid character '%c' at position %v", c, i)
+		}
+	}
+	return tokens, nil
+}
+
+func isDSLNameRune(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || c == '_' || c == '.' || c == '-'
+}
+
+type dslParser struct {
+	tokens   []dslToken
+	pos      int
+	resolver func(name string) (Processor, error)
+}
+
+func (p *dslParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos].value
+	}
+	return ""
+}
+
+func (p *dslParser) next() string {
+	v := p.peek()
+	p.pos++
+	return v
+}
+
+func (p *dslParser) errorf(format string, args ...interface{}) error {
+	pos := -1
+	if p.pos < len(p.tokens) {
+		pos = p.tokens[p.pos].pos
+	}
+	if pos < 0 {
+		return errors.Errorf("dsl: "+format+" at the end", args...)
+	}
+	return errors.Errorf("dsl: "+format+" at position %v", append(args, pos)...)
+}
+
+func isDSLKeyword(v string) bool {
+	return v == onFailureKeyword || v == onCompleteKeyword
+}
+
+func (p *dslParser) parseName() (Processor, error) {
+	v := p.peek()
+	if v == "" || v == "->" || v == "[" || v == "]" || v == "," || isDSLKeyword(v) {
+		return nil, p.errorf("processor name expected, got '%v'", v)
+	}
+	processor, err := p.resolver(v)
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	p.next()
+	return processor, nil
+}
+
+// parseGroup parses `name` or `[name, name]`, returns whether it is a parallel group
+func (p *dslParser) parseGroup() ([]Processor, bool, error) {
+	if p.peek() != "[" {
+		processor, err := p.parseName()
+		if err != nil {
+			return nil, false, err
+		}
+		return []Processor{processor}, false, nil
+	}
+
+	p.next()
+	processors := []Processor{}
+	for {
+		processor, err := p.parseName()
+		if err != nil {
+			return nil, false, err
+		}
+		processors = append(processors, processor)
+		switch p.peek() {
+		case ",":
+			p.next()
+		case "]":
+			p.next()
+			return processors, true, nil
+		default:
+			return nil, false, p.errorf("',' or ']' expected, got '%v'", p.peek())
+		}
+	}
+}
+
+func (p *dslParser) parse(dag *Dag) error {
+	if len(p.tokens) == 0 {
+		return errors.New("dsl: empty pipeline")
+	}
+
+	var current *Job
+	for {
+		processors, parallel, err := p.parseGroup()
+		if err != nil {
+			return err
+		}
+
+		if parallel {
+			current = &Job{tasks: processors, sequential: false, mode: dag.mode}
+			dag.jobs = append(dag.jobs, current)
+		} else if current != nil && current.sequential && current.onFailure == nil && current.onComplete == nil {
+			current.tasks = append(current.tasks, processors...)
+		} else {
+			current = &Job{tasks: processors, sequential: true}
+			dag.jobs = append(dag.jobs, current)
+		}
+
+		for isDSLKeyword(p.peek()) {
+			keyword := p.next()
+			hooks, _, err := p.parseGroup()
+			if err != nil {
+				return err
+			}
+			if keyword == onFailureKeyword {
+				if len(hooks) != 1 || current.onFailure != nil {
+					return p.errorf("only one %v processor is allowed", onFailureKeyword)
+				}
+				current.onFailure = hooks[0]
+			} else {
+				current.onComplete = append(current.onComplete, hooks...)
+			}
+		}
+
+		switch p.peek() {
+		case "":
+			return nil
+		case "->":
+			p.next()
+		default:
+			return p.errorf("'->' expected, got '%v'", p.peek())
+		}
+	}
+}
+
+// getRegisteredProcessor creates processor by its registered name with empty config
+func getRegisteredProcessor(name string) (Processor, error) {
+	gen, exists := registry.processorReg[name]
+	if !exists {
+		return nil, errors.Errorf("the processor %s does not exist", name)
+	}
+	return gen.ProcessorPlugin()(config.NewConfig())
+}
+
+// Parse appends jobs declared by the dsl, processors are resolved from the registered processors
+func (dag *Dag) Parse(dsl string) (*Dag, error) {
+	return dag.ParseWithSteps(dsl, nil)
+}
+
+// ParseWithSteps works as Parse, names defined in steps are created with their own processor config,
+// each step has exactly one processor, eg: `{"index_a": {"bulk_indexing": {...}}}`
+func (dag *Dag) ParseWithSteps(dsl string, steps map[string]*config.Config) (*Dag, error) {
+	tokens, err := tokenizeDSL(strings.TrimSpace(dsl))
+	if err != nil {
+		return nil, errors.Errorf("dsl: %v", err)
+	}
+
+	parser := dslParser{tokens: tokens, resolver: func(name string) (Processor, error) {
+		if cfg, ok := steps[name]; ok {
+			processors, err := getProcessors([]*config.Config{cfg})
+			if err != nil {
+				return nil, errors.Errorf("step [%v]: %v", name, err)
+			}
+			return processors[0], nil
+		}
+		return getRegisteredProcessor(name)
+	}}
+
+	//keep the dag unchanged on error
+	parsed := NewDAG(dag.mode)
+	err = parser.parse(parsed)
+	if err != nil {
+		return nil, err
+	}
+	dag.jobs = append(dag.jobs, parsed.jobs...)
+	return dag, nil
+}
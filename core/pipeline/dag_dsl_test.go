@@ -0,0 +1,124 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pipeline
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/rubyniu105/framework/core/config"
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordProcessor struct {
+	name string
+	fail bool
+}
+
+var recordLock sync.Mutex
+var records []string
+
+func (p *recordProcessor) Name() string {
+	return p.name
+}
+
+func (p *recordProcessor) Process(ctx *Context) error {
+	recordLock.Lock()
+	records = append(records, p.name)
+	recordLock.Unlock()
+	if p.fail {
+		return errors.New("failed")
+	}
+	return nil
+}
+
+func registerRecordProcessor(name string) {
+	RegisterProcessorPlugin(name, func(c *config.Config) (Processor, error) {
+		cfg := struct {
+			Name string `config:"name"`
+			Fail bool   `config:"fail"`
+		}{Name: name}
+		if err := c.Unpack(&cfg); err != nil {
+			return nil, err
+		}
+		return &recordProcessor{name: cfg.Name, fail: cfg.Fail}, nil
+	})
+}
+
+func init() {
+	for _, v := range []string{"dsl_a", "dsl_b", "dsl_c", "dsl_d", "dsl_e"} {
+		registerRecordProcessor(v)
+	}
+}
+
+func resetRecords() []string {
+	recordLock.Lock()
+	defer recordLock.Unlock()
+	v := records
+	records = nil
+	return v
+}
+
+func TestParseDSL(t *testing.T) {
+	dag, err := NewDAG("").Parse("dsl_a -> dsl_b -> [dsl_c, dsl_d] on_complete dsl_e -> dsl_a on_failure dsl_e")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(dag.jobs))
+	assert.True(t, dag.jobs[0].sequential)
+	assert.Equal(t, 2, len(dag.jobs[0].tasks))
+	assert.False(t, dag.jobs[1].sequential)
+	assert.Equal(t, 1, len(dag.jobs[1].onComplete))
+	assert.NotNil(t, dag.jobs[2].onFailure)
+
+	resetRecords()
+	dag.Run(&Context{})
+	v := resetRecords()
+	assert.Equal(t, []string{"dsl_a", "dsl_b"}, v[:2])
+	assert.ElementsMatch(t, []string{"dsl_c", "dsl_d"}, v[2:4])
+	//on_failure only runs when the job failed
+	assert.Equal(t, []string{"dsl_e", "dsl_a"}, v[4:])
+
+	for _, dsl := range []string{"", "dsl_a ->", "[dsl_a, dsl_b", "dsl_a dsl_b", "dsl_a -> not_exists", "dsl_a on_failure [dsl_b, dsl_c]", "dsl_a # dsl_b"} {
+		_, err = NewDAG("").Parse(dsl)
+		assert.NotNil(t, err, dsl)
+	}
+}
+
+func TestDAGProcessorWithDSL(t *testing.T) {
+	cfg, err := config.NewConfigFrom(map[string]interface{}{
+		"dsl": "dsl_a -> failed_step on_failure alert -> dsl_b",
+		"steps": map[string]interface{}{
+			"failed_step": map[string]interface{}{"dsl_c": map[string]interface{}{"fail": true}},
+			"alert":       map[string]interface{}{"dsl_d": map[string]interface{}{"name": "alert"}},
+		},
+	})
+	assert.Nil(t, err)
+
+	processor, err := NewDAGProcessor(cfg)
+	assert.Nil(t, err)
+
+	resetRecords()
+	assert.Nil(t, processor.Process(&Context{}))
+	assert.Equal(t, []string{"dsl_a", "dsl_c", "alert"}, resetRecords())
+}
@@ -90,11 +90,20 @@ func NewDAGProcessor(c *config2.Config) (Processor, error) {
 
 	//log.Info("init dag processor")
 
+	processor.dag = NewDAG(cfg.Mode)
+
+	if cfg.DSL != "" {
+		_, err := processor.dag.ParseWithSteps(cfg.DSL, cfg.Steps)
+		if err != nil {
+			return nil, err
+		}
+		return &processor, nil
+	}
+
 	if len(cfg.ParallelProcessors) == 0 {
 		return nil, errors.New("parallel is not set")
 	}
 
-	processor.dag = NewDAG(cfg.Mode)
 	var dsl *spawnsResult
 	p, err := getProcessors(cfg.ParallelProcessors)
 	if err != nil {
@@ -134,6 +143,10 @@ type DAGConfig struct {
 	FirstFinishedProcessors []*config2.Config `config:"first"`
 	AfterJoinAllProcessors  []*config2.Config `config:"join"`
 	AfterAnyProcessors      []*config2.Config `config:"end"`
+
+	//declare the dag in text, eg: `a -> [b, c] -> d on_failure e`, takes precedence over the processors above
+	DSL   string                     `config:"dsl"`
+	Steps map[string]*config2.Config `config:"steps"`
 }
 
 func (this DAGProcessor) Process(c *Context) error {
@@ -23,12 +23,12 @@
 
 package pipeline
 
-func run(job *Job,ctx *Context) {
+// run executes the job, returns false if any task of the job failed
+func run(job *Job, ctx *Context) bool {
 
 	if job.sequential {
-		runSync(job,ctx)
-	} else {
-		runAsync(job,ctx)
+		return runSync(job, ctx)
 	}
+	return runAsync(job, ctx)
 
 }
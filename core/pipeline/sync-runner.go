@@ -22,21 +22,37 @@
 // along with this program. If not, see <http://www.gnu.org/licenses/>.
 
 package pipeline
-func runSync(job *Job,ctx *Context) {
+
+import (
+	log "github.com/cihub/seelog"
+)
+
+func runSync(job *Job, ctx *Context) bool {
 
 	if job.onFailure != nil {
-		defer job.onFailure.Process(ctx)
+		defer func() {
+			if r := recover(); r != nil {
+				job.onFailure.Process(ctx)
+				panic(r)
+			}
+		}()
 	}
 
 	for _, task := range job.tasks {
-
-		task.Process(ctx)
+		err := task.Process(ctx)
+		if err != nil {
+			log.Errorf("failed to run processor [%v], %v", task.Name(), err)
+			if job.onFailure != nil {
+				job.onFailure.Process(ctx)
+			}
+			return false
+		}
 	}
 
 	if job.onComplete != nil {
-		for _,v:=range job.onComplete{
+		for _, v := range job.onComplete {
 			v.Process(ctx)
 		}
 	}
-
+	return true
 }
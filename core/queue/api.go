@@ -34,6 +34,7 @@ import (
 	log "github.com/cihub/seelog"
 	"github.com/rubyniu105/framework/core/errors"
 	"github.com/rubyniu105/framework/core/global"
+	"github.com/rubyniu105/framework/core/locker"
 	"github.com/rubyniu105/framework/core/stats"
 	"github.com/rubyniu105/framework/core/util"
 )
@@ -127,8 +128,31 @@ var consumerCfgLock = sync.RWMutex{}
 
 const BucketWhoOwnsThisTopic = "who_owns_this_topic" //queue_group: node_id/timestamp
 
+const BucketConsumersInFighting = "consumers_in_fighting" //queue_id+consumer_key: client_id/timestamp/token
+
+// consumers held by this process, queue_id+consumer_key: *fightingHolder
 var consumersInFighting = sync.Map{}
 
+type fightingHolder struct {
+	clientID  string
+	token     uint64
+	expire    time.Duration
+	lastRenew time.Time
+}
+
+var ErrConsumerFenced = errors.New("consumer was taken over by others")
+
+// defaultConsumerLeaseTimeout is used when consume_timeout is not set, the lease must expire,
+// otherwise a crashed holder blocks the consumer forever
+const defaultConsumerLeaseTimeout = 60 * time.Second
+
+func getConsumerLeaseTimeout(consumer *ConsumerConfig) time.Duration {
+	if consumer.ConsumeTimeoutInSeconds > 0 {
+		return time.Duration(consumer.ConsumeTimeoutInSeconds) * time.Second
+	}
+	return defaultConsumerLeaseTimeout
+}
+
 func AcquireConsumer(k *QueueConfig, consumer *ConsumerConfig, clientID string) (ConsumerAPI, error) {
 	if k == nil || k.ID == "" {
 		panic(errors.New("queue name can't be nil"))
@@ -142,39 +166,114 @@ func AcquireConsumer(k *QueueConfig, consumer *ConsumerConfig, clientID string)
 		panic(errors.New("clientID can't be nil"))
 	}
 
+	fightingKey := k.ID + consumer.Key()
+
 	//check if the consumer is in fighting list
-	if v, ok := consumersInFighting.Load(k.ID + consumer.Key()); ok {
-		if v != clientID {
-			//check the last touch time
-			if consumer.ConsumeTimeoutInSeconds > 0 {
-				t := consumer.GetLastActiveTime()
-				if t != nil && int(time.Since(*t).Seconds()) > consumer.ConsumeTimeoutInSeconds {
-					consumersInFighting.Delete(k.ID + consumer.Key())
-					stats.Increment("consumer", k.ID, consumer.GetID(), "expired")
-					//the consumer is in fighting and is already timeout
-					return nil, errors.Errorf("consumer:%v is already in fighting list, but expired in: %v, remove it from the fighting list", consumer.Key(), time.Since(*t).Seconds())
-				}
+	ok, info, err := locker.GetAllocateInfo(BucketConsumersInFighting, fightingKey)
+	if err != nil {
+		return nil, err
+	}
+	expire := getConsumerLeaseTimeout(consumer)
+	if ok && info.ClientID != clientID {
+		//check the last touch time
+		t := consumer.GetLastActiveTime()
+		if t == nil {
+			//no local activity, the holder may be gone with a previous process
+			t = &info.Timestamp
+		}
+		if time.Since(*t) > expire {
+			//only remove the record we just read, in case someone else already took over
+			if _, err := locker.Revoke(info); err != nil {
+				return nil, err
 			}
-			stats.Increment("consumer", k.ID, consumer.GetID(), "contend")
-			//the consumer is in fighting list and the clientID is not the same
-			return nil, errors.New("the consumer is in fighting list")
+			stats.Increment("consumer", k.ID, consumer.GetID(), "expired")
+			//the consumer is in fighting and is already timeout
+			return nil, errors.Errorf("consumer:%v is already in fighting list, but expired in: %v, remove it from the fighting list", consumer.Key(), time.Since(*t).Seconds())
 		}
+		stats.Increment("consumer", k.ID, consumer.GetID(), "contend")
+		//the consumer is in fighting list and the clientID is not the same
+		return nil, errors.New("the consumer is in fighting list")
 	}
 
 	handler := getAdvancedHandler(k)
-	if handler != nil {
-		v1, err := handler.AcquireConsumer(k, consumer)
-		if err != nil {
-			stats.Increment("consumer", k.ID, consumer.GetID(), "error_on_acquire")
-			return nil, err
+	if handler == nil {
+		panic(errors.New("handler is not registered"))
+	}
+
+	//add the consumer to the fighting list, only one client wins the swap
+	ok, token, err := locker.HoldWithToken(BucketConsumersInFighting, fightingKey, clientID, expire, false)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		stats.Increment("consumer", k.ID, consumer.GetID(), "contend")
+		return nil, errors.New("the consumer is in fighting list")
+	}
+
+	v1, err := handler.AcquireConsumer(k, consumer)
+	if err != nil {
+		stats.Increment("consumer", k.ID, consumer.GetID(), "error_on_acquire")
+		if err1 := locker.ReleaseWithToken(BucketConsumersInFighting, fightingKey, clientID, token); err1 != nil {
+			log.Warn(err1)
 		}
+		return nil, err
+	}
 
-		//add the consumer to the fighting list
-		consumersInFighting.Store(k.ID+consumer.Key(), clientID)
-		stats.Increment("consumer", k.ID, consumer.GetID(), "acquired")
-		return v1, nil
+	consumersInFighting.Store(fightingKey, &fightingHolder{clientID: clientID, token: token, expire: expire, lastRenew: time.Now()})
+	stats.Increment("consumer", k.ID, consumer.GetID(), "acquired")
+	return v1, nil
+}
+
+// RenewConsumer extends the lease of the consumer held by this process, should be called while consuming,
+// returns ErrConsumerFenced if the lease was revoked or taken over by others
+func RenewConsumer(k *QueueConfig, c *ConsumerConfig) error {
+	fightingKey := k.ID + c.Key()
+	v, ok := consumersInFighting.Load(fightingKey)
+	if !ok {
+		return nil
 	}
-	panic(errors.New("handler is not registered"))
+	holder := v.(*fightingHolder)
+
+	//the lease is stored with second precision, no need to touch it on every fetch
+	interval := holder.expire / 3
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	if time.Since(holder.lastRenew) < interval {
+		return nil
+	}
+
+	ok, token, err := locker.HoldWithToken(BucketConsumersInFighting, fightingKey, holder.clientID, holder.expire, false)
+	if err != nil {
+		return err
+	}
+	if !ok || token != holder.token {
+		consumersInFighting.Delete(fightingKey)
+		stats.Increment("consumer", k.ID, c.GetID(), "fenced")
+		return ErrConsumerFenced
+	}
+	holder.lastRenew = time.Now()
+	return nil
+}
+
+// validateConsumer checks the consumer held by this process still owns the fencing token
+func validateConsumer(k *QueueConfig, c *ConsumerConfig) error {
+	fightingKey := k.ID + c.Key()
+	v, ok := consumersInFighting.Load(fightingKey)
+	if !ok {
+		return nil
+	}
+	holder := v.(*fightingHolder)
+	ok, err := locker.ValidateToken(BucketConsumersInFighting, fightingKey, holder.clientID, holder.token)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		consumersInFighting.Delete(fightingKey)
+		stats.Increment("consumer", k.ID, c.GetID(), "fenced")
+		return ErrConsumerFenced
+	}
+	return nil
 }
 
 func ReleaseConsumer(k *QueueConfig, c *ConsumerConfig, consumer ConsumerAPI) error {
@@ -183,7 +282,14 @@ func ReleaseConsumer(k *QueueConfig, c *ConsumerConfig, consumer ConsumerAPI) er
 	}
 
 	//remove the consumer from the fighting list
-	consumersInFighting.Delete(k.ID + c.Key())
+	fightingKey := k.ID + c.Key()
+	if v, ok := consumersInFighting.LoadAndDelete(fightingKey); ok {
+		holder := v.(*fightingHolder)
+		//a stale holder can't release the one who took over, as the token was increased
+		if err := locker.ReleaseWithToken(BucketConsumersInFighting, fightingKey, holder.clientID, holder.token); err != nil {
+			log.Warn(err)
+		}
+	}
 
 	stats.Increment("consumer", k.ID, c.GetID(), "released")
 
@@ -348,6 +454,11 @@ func CommitOffset(k *QueueConfig, consumer *ConsumerConfig, offset Offset) (bool
 		return false, errors.Errorf("consumer %v for queue %v was not found", consumer.Key(), k.ID)
 	}
 
+	//a stale consumer must not move the offset after someone else took over
+	if err := validateConsumer(k, consumer); err != nil {
+		return false, err
+	}
+
 	handler := getAdvancedHandler(k)
 	if handler != nil {
 		return handler.CommitOffset(k, consumer, offset)
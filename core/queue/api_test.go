@@ -0,0 +1,76 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rubyniu105/framework/core/kv"
+	"github.com/rubyniu105/framework/core/locker"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	kv.Register("memory", kv.NewMemoryStore())
+}
+
+func TestRenewConsumerFenced(t *testing.T) {
+	q := &QueueConfig{ID: "fencing"}
+	c := &ConsumerConfig{Group: "group", Name: "consumer", ConsumeTimeoutInSeconds: 60}
+	fightingKey := q.ID + c.Key()
+
+	ok, token, err := locker.HoldWithToken(BucketConsumersInFighting, fightingKey, "a", time.Minute, false)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	//pretend the lease was renewed long ago
+	consumersInFighting.Store(fightingKey, &fightingHolder{clientID: "a", token: token, expire: time.Minute})
+
+	assert.Nil(t, RenewConsumer(q, c))
+	assert.Nil(t, validateConsumer(q, c))
+
+	//someone revoked the lease and took over
+	_, info, _ := locker.GetAllocateInfo(BucketConsumersInFighting, fightingKey)
+	ok, _ = locker.Revoke(info)
+	assert.True(t, ok)
+	ok, _, _ = locker.HoldWithToken(BucketConsumersInFighting, fightingKey, "b", time.Minute, false)
+	assert.True(t, ok)
+
+	assert.Equal(t, ErrConsumerFenced, validateConsumer(q, c))
+
+	consumersInFighting.Store(fightingKey, &fightingHolder{clientID: "a", token: token, expire: time.Minute})
+	assert.Equal(t, ErrConsumerFenced, RenewConsumer(q, c))
+	_, held := consumersInFighting.Load(fightingKey)
+	assert.False(t, held)
+}
+
+func TestConsumerLeaseTimeout(t *testing.T) {
+	assert.Equal(t, defaultConsumerLeaseTimeout, getConsumerLeaseTimeout(&ConsumerConfig{}))
+	assert.Equal(t, defaultConsumerLeaseTimeout, getConsumerLeaseTimeout(&ConsumerConfig{ConsumeTimeoutInSeconds: -1}))
+	assert.Equal(t, 10*time.Second, getConsumerLeaseTimeout(&ConsumerConfig{ConsumeTimeoutInSeconds: 10}))
+}
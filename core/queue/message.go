@@ -165,6 +165,9 @@ type Message struct {
 	NextOffset Offset `config:"next_offset" json:"next_offset"  parquet:"next_offset"` //offset for next message
 	Size       int    `config:"size" json:"size"  parquet:"size"`
 	Data       []byte `config:"data" json:"data"  parquet:"data,zstd"`
+
+	Key     []byte            `config:"key" json:"key,omitempty"  parquet:"key,optional"`             //routing key, used for partitioning
+	Headers map[string]string `config:"headers" json:"headers,omitempty"  parquet:"headers,optional"` //eg: trace_id, content_type
 }
 
 func (m *Message) String() string {
@@ -172,9 +175,10 @@ func (m *Message) String() string {
 }
 
 type ProduceRequest struct {
-	Topic string `config:"topic" json:"topic"` //queue_id
-	Key   []byte `config:"key" json:"key"`
-	Data  []byte `config:"data" json:"data"`
+	Topic   string            `config:"topic" json:"topic"` //queue_id
+	Key     []byte            `config:"key" json:"key"`
+	Headers map[string]string `config:"headers" json:"headers,omitempty"`
+	Data    []byte            `config:"data" json:"data"`
 }
 
 type ProduceResponse struct {
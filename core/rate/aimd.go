@@ -0,0 +1,161 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rate
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// AIMDConfig configures an adaptive limiter, the limit is increased additively and decreased multiplicatively
+type AIMDConfig struct {
+	Min            float64
+	Max            float64
+	IncreaseStep   float64       //added to the limit on every increase, default 5% of max
+	DecreaseFactor float64       //multiplied to the limit on every decrease, default 0.5
+	Interval       time.Duration //min interval between two changes, default 1s
+	Burst          int           //fixed burst size, follows the current limit if not set
+}
+
+// AIMDLimiter is a token bucket with the limit adjusted by feedbacks
+type AIMDLimiter struct {
+	mu         sync.Mutex
+	cfg        AIMDConfig
+	limiter    *rate.Limiter
+	current    float64
+	lastChange time.Time
+}
+
+func NewAIMDLimiter(cfg AIMDConfig) *AIMDLimiter {
+	if cfg.Max < 1 {
+		cfg.Max = 1
+	}
+	if cfg.Min <= 0 || cfg.Min > cfg.Max {
+		cfg.Min = cfg.Max / 10
+	}
+	if cfg.Min < 1 {
+		cfg.Min = 1
+	}
+	if cfg.IncreaseStep <= 0 {
+		cfg.IncreaseStep = cfg.Max / 20
+	}
+	if cfg.IncreaseStep < 1 {
+		cfg.IncreaseStep = 1
+	}
+	if cfg.DecreaseFactor <= 0 || cfg.DecreaseFactor >= 1 {
+		cfg.DecreaseFactor = 0.5
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	l := &AIMDLimiter{cfg: cfg, current: cfg.Max}
+	l.limiter = rate.NewLimiter(rate.Limit(l.current), l.burst())
+	return l
+}
+
+func (l *AIMDLimiter) burst() int {
+	if l.cfg.Burst > 0 {
+		return l.cfg.Burst
+	}
+	if l.current < 1 {
+		return 1
+	}
+	return int(l.current)
+}
+
+func (l *AIMDLimiter) Allow() bool {
+	return l.limiter.Allow()
+}
+
+func (l *AIMDLimiter) AllowN(t time.Time, n int) bool {
+	return l.limiter.AllowN(t, n)
+}
+
+// Limit returns the current limit per second
+func (l *AIMDLimiter) Limit() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.current
+}
+
+// Decrease backs off the limit, returns false if skipped by the interval or already at min
+func (l *AIMDLimiter) Decrease() bool {
+	return l.update(func(v float64) float64 {
+		return v * l.cfg.DecreaseFactor
+	})
+}
+
+// Increase recovers the limit gradually, returns false if skipped by the interval or already at max
+func (l *AIMDLimiter) Increase() bool {
+	return l.update(func(v float64) float64 {
+		return v + l.cfg.IncreaseStep
+	})
+}
+
+func (l *AIMDLimiter) update(f func(float64) float64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastChange) < l.cfg.Interval {
+		return false
+	}
+	v := f(l.current)
+	if v < l.cfg.Min {
+		v = l.cfg.Min
+	}
+	if v > l.cfg.Max {
+		v = l.cfg.Max
+	}
+	if v == l.current {
+		return false
+	}
+	l.current = v
+	l.lastChange = now
+	l.limiter.SetLimitAt(now, rate.Limit(v))
+	l.limiter.SetBurstAt(now, l.burst())
+	return true
+}
+
+var aimdLimiters = map[string]map[string]*AIMDLimiter{}
+
+// GetAIMDLimiter returns the adaptive limiter of the key, the config only takes effect on creation
+func GetAIMDLimiter(category, key string, cfg AIMDConfig) *AIMDLimiter {
+	mu.Lock()
+	defer mu.Unlock()
+
+	_, ok := aimdLimiters[category]
+	if !ok {
+		aimdLimiters[category] = map[string]*AIMDLimiter{}
+	}
+
+	limiter, exists := aimdLimiters[category][key]
+	if !exists {
+		limiter = NewAIMDLimiter(cfg)
+		aimdLimiters[category][key] = limiter
+	}
+	return limiter
+}
@@ -0,0 +1,58 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package rate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAIMDLimiter(t *testing.T) {
+	l := NewAIMDLimiter(AIMDConfig{Max: 100, Min: 10, IncreaseStep: 10, Interval: time.Millisecond})
+	assert.Equal(t, float64(100), l.Limit())
+
+	//already at max
+	assert.False(t, l.Increase())
+
+	assert.True(t, l.Decrease())
+	assert.Equal(t, float64(50), l.Limit())
+
+	//changes are limited by the interval
+	assert.False(t, l.Decrease())
+
+	time.Sleep(2 * time.Millisecond)
+	assert.True(t, l.Decrease())
+	time.Sleep(2 * time.Millisecond)
+	assert.True(t, l.Decrease())
+	assert.Equal(t, 12.5, l.Limit())
+	time.Sleep(2 * time.Millisecond)
+	assert.True(t, l.Decrease())
+	assert.Equal(t, float64(10), l.Limit())
+
+	time.Sleep(2 * time.Millisecond)
+	assert.True(t, l.Increase())
+	assert.Equal(t, float64(20), l.Limit())
+}
@@ -57,6 +57,7 @@ import (
 	"google.golang.org/grpc/reflection"
 	"net"
 	"runtime"
+	"sync"
 	"time"
 )
 
@@ -79,6 +80,7 @@ func ObtainLocalConnection() (conn *ClientConn, err error) {
 func ObtainConnection(addr string) (client *ClientConn, err error) {
 	log.Trace("obtain client connection: ", addr)
 
+	var dialOption grpc.DialOption
 	if rpcConfig.TLSConfig.TLSEnabled {
 		var creds credentials.TransportCredentials
 		log.Trace("using tls connection")
@@ -89,18 +91,6 @@ func ObtainConnection(addr string) (client *ClientConn, err error) {
 		if cert != "" && key != "" {
 			log.Trace("use pre-defined cert")
 
-			// Create tls based credential.
-			creds, err = credentials.NewClientTLSFromFile(cert, key)
-			if err != nil {
-				log.Errorf("failed to load credentials: %v", err)
-			}
-
-			// Set up a connection to the server.
-			//conn, err := grpc.Dial(addr, grpc.WithTransportCredentials(creds))
-			//if err != nil {
-			//	log.Errorf("did not connect: %v", err)
-			//}
-
 			// Load the client certificates from disk
 			certificate, err := tls.LoadX509KeyPair(cert, key)
 			if err != nil {
@@ -126,51 +116,48 @@ func ObtainConnection(addr string) (client *ClientConn, err error) {
 			})
 		}
 
-		dialOption := grpc.WithTransportCredentials(creds)
-
-		p, err := New(func() (*grpc.ClientConn, error) {
-			return grpc.Dial(addr, dialOption)
-		}, 1, 2, 0)
-
-		// Get a client
-		client, err := p.Get(context.Background())
-		if err != nil {
-			log.Errorf("Get returned an error: %s", err.Error())
-		}
-		if client == nil {
-			log.Error("client was nil")
-		}
-		return client, nil
-
-		//conn, err = grpc.Dial(addr, dialOption)
-		//if err != nil {
-		//	log.Errorf("cannot connect to %s, %v", addr, err)
-		//}
-
+		dialOption = grpc.WithTransportCredentials(creds)
 	} else {
 		log.Trace("using insecure tcp connection")
+		dialOption = grpc.WithInsecure()
+	}
 
-		p, err := New(func() (*grpc.ClientConn, error) {
-			return grpc.Dial(addr, grpc.WithInsecure())
-		}, 1, 2, 0)
+	p, err := getPool(addr, dialOption)
+	if err != nil {
+		return nil, err
+	}
 
-		// Get a client
-		client, err := p.Get(context.Background())
-		if err != nil {
-			log.Errorf("Get returned an error: %s", err.Error())
-		}
-		if client == nil {
-			log.Error("client was nil")
-		}
+	// Get a client
+	client, err = p.Get(context.Background())
+	if err != nil {
+		log.Errorf("Get returned an error: %s", err.Error())
+	}
+	if client == nil {
+		log.Error("client was nil")
+	}
+	return client, err
+}
 
-		return client, err
+// pools by address, close the obtained client to return it to the pool
+var pools = map[string]*Pool{}
+var poolLock sync.Mutex
 
-		//conn, err = grpc.Dial(addr, grpc.WithInsecure())
-		//if err != nil {
-		//	log.Errorf("cannot connect to %s, %v", addr, err)
-		//}
+func getPool(addr string, dialOption grpc.DialOption) (*Pool, error) {
+	poolLock.Lock()
+	defer poolLock.Unlock()
 
+	if p, ok := pools[addr]; ok && !p.IsClosed() {
+		return p, nil
+	}
+
+	p, err := New(func() (*grpc.ClientConn, error) {
+		return grpc.Dial(addr, dialOption)
+	}, 1, 2, 0)
+	if err != nil {
+		return nil, err
 	}
+	pools[addr] = p
+	return p, nil
 }
 
 var s *grpc.Server
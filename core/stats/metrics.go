@@ -0,0 +1,337 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package stats
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type MetricType string
+
+const (
+	CounterType   MetricType = "counter"
+	GaugeType     MetricType = "gauge"
+	HistogramType MetricType = "histogram"
+	SummaryType   MetricType = "summary"
+)
+
+// Labels are attached to the metric instead of being concatenated into the key
+type Labels map[string]string
+
+// DefaultBuckets are upper bounds in milliseconds, used by histograms without buckets defined
+var DefaultBuckets = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000}
+
+// DefaultObjectives are the quantiles calculated by summaries without objectives defined
+var DefaultObjectives = []float64{0.5, 0.9, 0.99}
+
+const defaultMaxSamples = 1024
+
+type MetricOptions struct {
+	Help       string
+	Buckets    []float64     //histogram only
+	Objectives []float64     //summary only
+	MaxSamples int           //summary only, max num of recent samples to calculate quantiles
+	MaxAge     time.Duration //summary only, samples older than this will be dropped
+}
+
+type metricDesc struct {
+	name string
+	typ  MetricType
+	opts MetricOptions
+}
+
+var descs = sync.Map{}
+
+// MetricName returns the prometheus compatible name of the metric
+func MetricName(category, key string) string {
+	name := category
+	if key != "" {
+		name = category + "_" + key
+	}
+	var b strings.Builder
+	for i, c := range name {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == ':' || (c >= '0' && c <= '9' && i > 0) {
+			b.WriteRune(c)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+func define(category, key string, typ MetricType, opts MetricOptions) {
+	name := MetricName(category, key)
+	descs.Store(name, &metricDesc{name: name, typ: typ, opts: opts})
+}
+
+// DefineHistogram configures the buckets of the histogram, should be called before any observation
+func DefineHistogram(category, key string, opts MetricOptions) {
+	if len(opts.Buckets) == 0 {
+		opts.Buckets = DefaultBuckets
+	}
+	buckets := append([]float64{}, opts.Buckets...)
+	sort.Float64s(buckets)
+	opts.Buckets = buckets
+	define(category, key, HistogramType, opts)
+}
+
+// DefineSummary configures the objectives of the summary, should be called before any observation
+func DefineSummary(category, key string, opts MetricOptions) {
+	if len(opts.Objectives) == 0 {
+		opts.Objectives = DefaultObjectives
+	}
+	if opts.MaxSamples <= 0 {
+		opts.MaxSamples = defaultMaxSamples
+	}
+	define(category, key, SummaryType, opts)
+}
+
+func getDesc(category, key string, typ MetricType) *metricDesc {
+	name := MetricName(category, key)
+	v, ok := descs.Load(name)
+	if ok {
+		return v.(*metricDesc)
+	}
+	desc := &metricDesc{name: name, typ: typ}
+	if typ == HistogramType {
+		desc.opts.Buckets = DefaultBuckets
+	}
+	v, _ = descs.LoadOrStore(name, desc)
+	return v.(*metricDesc)
+}
+
+type Bucket struct {
+	UpperBound float64 `json:"le"`
+	Count      uint64  `json:"count"` //cumulative count
+}
+
+type Quantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+type Metric struct {
+	Labels    Labels     `json:"labels,omitempty"`
+	Value     float64    `json:"value,omitempty"` //counter or gauge
+	Count     uint64     `json:"count,omitempty"`
+	Sum       float64    `json:"sum,omitempty"`
+	Buckets   []Bucket   `json:"buckets,omitempty"`
+	Quantiles []Quantile `json:"quantiles,omitempty"`
+}
+
+type MetricFamily struct {
+	Name    string     `json:"name"`
+	Help    string     `json:"help,omitempty"`
+	Type    MetricType `json:"type"`
+	Metrics []*Metric  `json:"metrics"`
+}
+
+type series struct {
+	lock    sync.Mutex
+	labels  Labels
+	value   float64
+	count   uint64
+	sum     float64
+	buckets []uint64 //non-cumulative
+	samples []sample
+	next    int
+}
+
+type sample struct {
+	value float64
+	time  time.Time
+}
+
+type family struct {
+	desc   *metricDesc
+	series sync.Map //labels signature -> *series
+}
+
+// Registry keeps the labeled metrics in memory, could be embedded by stats handlers
+type Registry struct {
+	families sync.Map //name -> *family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func labelsSignature(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(labels[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+func (r *Registry) getSeries(category, key string, typ MetricType, labels Labels) (*family, *series) {
+	desc := getDesc(category, key, typ)
+	v, ok := r.families.Load(desc.name)
+	if !ok {
+		v, _ = r.families.LoadOrStore(desc.name, &family{desc: desc})
+	}
+	f := v.(*family)
+	sig := labelsSignature(labels)
+	s, ok := f.series.Load(sig)
+	if !ok {
+		copied := make(Labels, len(labels))
+		for k, v := range labels {
+			copied[k] = v
+		}
+		newSeries := &series{labels: copied}
+		if f.desc.typ == HistogramType {
+			newSeries.buckets = make([]uint64, len(f.desc.opts.Buckets))
+		}
+		s, _ = f.series.LoadOrStore(sig, newSeries)
+	}
+	return f, s.(*series)
+}
+
+func (r *Registry) IncrementWithLabels(category, key string, labels Labels, value int64) {
+	_, s := r.getSeries(category, key, CounterType, labels)
+	s.lock.Lock()
+	s.value += float64(value)
+	s.lock.Unlock()
+}
+
+func (r *Registry) GaugeWithLabels(category, key string, labels Labels, value float64) {
+	_, s := r.getSeries(category, key, GaugeType, labels)
+	s.lock.Lock()
+	s.value = value
+	s.lock.Unlock()
+}
+
+// Observe records the value to histogram or summary, undefined metrics are treated as histograms with default buckets
+func (r *Registry) Observe(category, key string, labels Labels, value float64) {
+	f, s := r.getSeries(category, key, HistogramType, labels)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.count++
+	s.sum += value
+	switch f.desc.typ {
+	case HistogramType:
+		for i, bound := range f.desc.opts.Buckets {
+			if value <= bound {
+				s.buckets[i]++
+				break
+			}
+		}
+	case SummaryType:
+		if len(s.samples) < f.desc.opts.MaxSamples {
+			s.samples = append(s.samples, sample{value: value, time: time.Now()})
+		} else {
+			s.samples[s.next] = sample{value: value, time: time.Now()}
+			s.next = (s.next + 1) % len(s.samples)
+		}
+	}
+}
+
+func (s *series) collect(desc *metricDesc) *Metric {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	m := &Metric{Labels: s.labels}
+	switch desc.typ {
+	case CounterType, GaugeType:
+		m.Value = s.value
+	case HistogramType:
+		m.Count = s.count
+		m.Sum = s.sum
+		var cumulative uint64
+		for i, bound := range desc.opts.Buckets {
+			cumulative += s.buckets[i]
+			m.Buckets = append(m.Buckets, Bucket{UpperBound: bound, Count: cumulative})
+		}
+	case SummaryType:
+		m.Count = s.count
+		m.Sum = s.sum
+		values := make([]float64, 0, len(s.samples))
+		for _, v := range s.samples {
+			if desc.opts.MaxAge > 0 && time.Since(v.time) > desc.opts.MaxAge {
+				continue
+			}
+			values = append(values, v.value)
+		}
+		sort.Float64s(values)
+		for _, q := range desc.opts.Objectives {
+			m.Quantiles = append(m.Quantiles, Quantile{Quantile: q, Value: quantile(values, q)})
+		}
+	}
+	return m
+}
+
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	idx := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// Collect returns a snapshot of all labeled metrics, ordered by name
+func (r *Registry) Collect() []*MetricFamily {
+	result := []*MetricFamily{}
+	r.families.Range(func(key, value any) bool {
+		f := value.(*family)
+		mf := &MetricFamily{Name: f.desc.name, Help: f.desc.opts.Help, Type: f.desc.typ}
+		sigs := []string{}
+		f.series.Range(func(key, value any) bool {
+			sigs = append(sigs, key.(string))
+			return true
+		})
+		sort.Strings(sigs)
+		for _, sig := range sigs {
+			s, ok := f.series.Load(sig)
+			if ok {
+				mf.Metrics = append(mf.Metrics, s.(*series).collect(f.desc))
+			}
+		}
+		result = append(result, mf)
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
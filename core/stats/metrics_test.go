@@ -0,0 +1,90 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package stats
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricName(t *testing.T) {
+	assert.Equal(t, "elasticsearch_bulk_latency_ms", MetricName("elasticsearch", "bulk_latency_ms"))
+	assert.Equal(t, "elasticsearch_c1_bulk_elapsed_ms", MetricName("elasticsearch.c1.bulk", "elapsed_ms"))
+	assert.Equal(t, "_queue_push", MetricName("9queue", "push"))
+}
+
+func TestRegistryHistogram(t *testing.T) {
+	DefineHistogram("test", "latency", MetricOptions{Help: "latency", Buckets: []float64{100, 10, 50}})
+	r := NewRegistry()
+	for _, v := range []float64{1, 10, 20, 60, 200} {
+		r.Observe("test", "latency", Labels{"host": "a"}, v)
+	}
+	r.Observe("test", "latency", Labels{"host": "b"}, 5)
+
+	families := r.Collect()
+	assert.Equal(t, 1, len(families))
+	f := families[0]
+	assert.Equal(t, "test_latency", f.Name)
+	assert.Equal(t, HistogramType, f.Type)
+	assert.Equal(t, 2, len(f.Metrics))
+
+	m := f.Metrics[0]
+	assert.Equal(t, "a", m.Labels["host"])
+	assert.Equal(t, uint64(5), m.Count)
+	assert.Equal(t, float64(291), m.Sum)
+	assert.Equal(t, []Bucket{{10, 2}, {50, 3}, {100, 4}}, m.Buckets)
+}
+
+func TestRegistrySummary(t *testing.T) {
+	DefineSummary("test", "summary", MetricOptions{Objectives: []float64{0.5, 0.99}, MaxSamples: 100})
+	r := NewRegistry()
+	for i := 1; i <= 200; i++ {
+		r.Observe("test", "summary", nil, float64(i))
+	}
+	m := r.Collect()[0].Metrics[0]
+	assert.Equal(t, uint64(200), m.Count)
+	//only the latest 100 samples are kept
+	assert.Equal(t, []Quantile{{0.5, 150}, {0.99, 199}}, m.Quantiles)
+
+	assert.True(t, math.IsNaN(quantile(nil, 0.5)))
+}
+
+func TestRegistryCounterAndGauge(t *testing.T) {
+	r := NewRegistry()
+	r.IncrementWithLabels("test", "requests", Labels{"code": "200"}, 2)
+	r.IncrementWithLabels("test", "requests", Labels{"code": "200"}, 3)
+	r.IncrementWithLabels("test", "requests", Labels{"code": "500"}, 1)
+	r.GaugeWithLabels("test", "inflight", nil, 7)
+
+	families := r.Collect()
+	assert.Equal(t, 2, len(families))
+	assert.Equal(t, "test_inflight", families[0].Name)
+	assert.Equal(t, GaugeType, families[0].Type)
+	assert.Equal(t, float64(7), families[0].Metrics[0].Value)
+	assert.Equal(t, CounterType, families[1].Type)
+	assert.Equal(t, float64(5), families[1].Metrics[0].Value)
+	assert.Equal(t, float64(1), families[1].Metrics[1].Value)
+}
@@ -70,6 +70,14 @@ type StatsInterface interface {
 	RecordTimestamp(category, key string, value time.Time)
 	//get the last timestamp for specify operation
 	GetTimestamp(category, key string) (time.Time, error)
+
+	//labeled metrics
+	IncrementWithLabels(category, key string, labels Labels, value int64)
+	GaugeWithLabels(category, key string, labels Labels, v float64)
+	//record a sample for histogram or summary
+	Observe(category, key string, labels Labels, v float64)
+	//return the labeled metrics
+	Collect() []*MetricFamily
 }
 
 var handlers = []StatsInterface{}
@@ -166,6 +174,36 @@ func Gauge(category, key string, value int64) {
 	}
 }
 
+func IncrementWithLabels(category, key string, labels Labels, value int64) {
+	for _, v := range handlers {
+		v.IncrementWithLabels(category, key, labels, value)
+	}
+}
+
+func GaugeWithLabels(category, key string, labels Labels, value float64) {
+	for _, v := range handlers {
+		v.GaugeWithLabels(category, key, labels, value)
+	}
+}
+
+// Observe records a sample for histogram or summary, use DefineHistogram or DefineSummary to configure the metric
+func Observe(category, key string, labels Labels, value float64) {
+	for _, v := range handlers {
+		v.Observe(category, key, labels, value)
+	}
+}
+
+// Collect returns the labeled metrics from the first handler which has
+func Collect() []*MetricFamily {
+	for _, v := range handlers {
+		b := v.Collect()
+		if len(b) > 0 {
+			return b
+		}
+	}
+	return nil
+}
+
 func Stat(category, key string) int64 {
 	for _, v := range handlers {
 		b := v.Stat(category, key)
@@ -92,6 +92,11 @@ func CreateCronTrigger(expression string, location *time.Location) (*CronTrigger
 	return trigger, nil
 }
 
+// NextTime returns the next execution time after t
+func (trigger *CronTrigger) NextTime(t time.Time) time.Time {
+	return trigger.cronExpression.NextTime(t.In(trigger.location)).In(t.Location())
+}
+
 func (trigger *CronTrigger) NextExecutionTime(ctx TriggerContext) time.Time {
 	now := time.Now()
 	lastCompletion := ctx.LastCompletionTime()
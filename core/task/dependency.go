@@ -0,0 +1,101 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package task
+
+import (
+	"context"
+
+	log "github.com/cihub/seelog"
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/util"
+)
+
+// ValidateDependencies checks the task doesn't depend on itself through the registered tasks
+func ValidateDependencies(taskID string, dependsOn []string) error {
+	visited := map[string]bool{}
+	var visit func(id string, path []string) error
+	visit = func(id string, path []string) error {
+		path = append(path, id)
+		if id == taskID {
+			return errors.Errorf("circular task dependencies: %v", util.JoinArray(path, " -> "))
+		}
+		if visited[id] {
+			return nil
+		}
+		visited[id] = true
+		v, ok := Tasks.Load(id)
+		if !ok {
+			//parent may be registered later, checked by then
+			return nil
+		}
+		for _, parent := range v.(*ScheduleTask).DependsOn {
+			if err := visit(parent, path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, parent := range dependsOn {
+		if err := visit(parent, []string{taskID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// triggerDependents runs the tasks depending on the completed task, once all of their parents completed
+func triggerDependents(taskID string) {
+	if !started {
+		return
+	}
+	Tasks.Range(func(key, value any) bool {
+		child, ok := value.(*ScheduleTask)
+		if !ok || child.State == Canceled || !util.StringInArray(child.DependsOn, taskID) {
+			return true
+		}
+		if child.parentCompleted(taskID) {
+			log.Debugf("task [%v] completed, trigger dependent task [%v][%v]", taskID, child.ID, child.Description)
+			go child.Task(context.Background())
+		}
+		return true
+	})
+}
+
+// parentCompleted marks the parent as completed, returns true if all parents completed since last trigger
+func (task *ScheduleTask) parentCompleted(parentID string) bool {
+	task.depsLock.Lock()
+	defer task.depsLock.Unlock()
+
+	if task.depsDone == nil {
+		task.depsDone = map[string]bool{}
+	}
+	task.depsDone[parentID] = true
+	for _, v := range task.DependsOn {
+		if !task.depsDone[v] {
+			return false
+		}
+	}
+	task.depsDone = nil
+	return true
+}
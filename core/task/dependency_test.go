@@ -0,0 +1,115 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package task
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircularDependencies(t *testing.T) {
+	assert.Nil(t, ValidateDependencies("cycle_a", nil))
+	_, err := registerScheduleTask(&ScheduleTask{ID: "cycle_a", DependsOn: []string{"cycle_c"}, Task: func(ctx context.Context) {}})
+	assert.Nil(t, err)
+	_, err = registerScheduleTask(&ScheduleTask{ID: "cycle_b", DependsOn: []string{"cycle_a"}, Task: func(ctx context.Context) {}})
+	assert.Nil(t, err)
+
+	_, err = registerScheduleTask(&ScheduleTask{ID: "cycle_c", DependsOn: []string{"cycle_b"}, Task: func(ctx context.Context) {}})
+	assert.NotNil(t, err)
+	_, err = registerScheduleTask(&ScheduleTask{ID: "cycle_d", DependsOn: []string{"cycle_d"}, Task: func(ctx context.Context) {}})
+	assert.NotNil(t, err)
+
+	DeleteTask("cycle_a")
+	DeleteTask("cycle_b")
+}
+
+func TestTaskRetryAndDependents(t *testing.T) {
+	started = true
+	defer func() { started = false }()
+
+	var attempts int32
+	RegisterScheduleTask(ScheduleTask{
+		ID:         "snapshot",
+		Type:       Dependent,
+		RetryTimes: 2,
+		RetryDelay: "10ms",
+		Runner: func(ctx context.Context) error {
+			if atomic.AddInt32(&attempts, 1) < 3 {
+				return errors.New("failed on purpose")
+			}
+			return nil
+		},
+	})
+
+	verified := make(chan bool, 1)
+	RegisterScheduleTask(ScheduleTask{
+		ID:        "verify",
+		DependsOn: []string{"snapshot"},
+		Task: func(ctx context.Context) {
+			verified <- true
+		},
+	})
+	defer DeleteTask("snapshot")
+	defer DeleteTask("verify")
+
+	v, _ := Tasks.Load("snapshot")
+	v.(*ScheduleTask).Task(context.Background())
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+
+	select {
+	case <-verified:
+	case <-time.After(time.Second):
+		t.Fatal("dependent task was not triggered")
+	}
+}
+
+func TestTaskTimeout(t *testing.T) {
+	var attempts int32
+	var err error
+	RegisterScheduleTask(ScheduleTask{
+		ID:         "timeout",
+		Type:       Dependent,
+		Timeout:    "20ms",
+		RetryTimes: 1,
+		RetryDelay: "1ms",
+		Runner: func(ctx context.Context) error {
+			atomic.AddInt32(&attempts, 1)
+			<-ctx.Done()
+			err = ctx.Err()
+			return nil
+		},
+	})
+	defer DeleteTask("timeout")
+
+	v, _ := Tasks.Load("timeout")
+	v.(*ScheduleTask).Task(context.Background())
+	//timed out runs are retried
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,233 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package task
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/global"
+	"github.com/rubyniu105/framework/core/orm"
+	"github.com/rubyniu105/framework/core/util"
+)
+
+// TaskRunType is the metadata type of task execution records
+const TaskRunType = "task_run"
+
+var historyEnabled atomic.Bool
+
+// EnableHistory turns on recording task executions through ORM
+func EnableHistory(enabled bool) {
+	historyEnabled.Store(enabled)
+}
+
+// taskRun is one execution of a task, it can be canceled while running
+type taskRun struct {
+	ID        string
+	TaskID    string
+	Group     string
+	Type      string
+	Desc      string
+	StartTime time.Time
+	Attempt   int
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	canceled bool
+}
+
+var runs = sync.Map{}
+
+// startRun creates an execution of the task, the context is canceled after timeout if it's greater than 0
+func startRun(task *ScheduleTask, parent context.Context, timeout time.Duration, attempt int) *taskRun {
+	if parent == nil {
+		parent = context.Background()
+	}
+	r := &taskRun{
+		ID:        util.GetUUID(),
+		TaskID:    task.ID,
+		Group:     task.Group,
+		Type:      task.Type,
+		Desc:      task.Description,
+		StartTime: time.Now(),
+		Attempt:   attempt,
+	}
+	if timeout > 0 {
+		r.ctx, r.cancel = context.WithTimeout(parent, timeout)
+	} else {
+		r.ctx, r.cancel = context.WithCancel(parent)
+	}
+	runs.Store(r.ID, r)
+	return r
+}
+
+// execute runs f and records the result, panics are recorded as errors
+func (r *taskRun) execute(f func(ctx context.Context) error) (err error) {
+	defer func() {
+		v := recover()
+		if v != nil {
+			err = errors.Errorf("%v", v)
+		}
+		err = r.finish(err)
+		if v != nil && global.Env().IsDebug {
+			panic(v)
+		}
+	}()
+	return f(r.ctx)
+}
+
+// finish records the result, a run exceeded the timeout is considered as failed
+func (r *taskRun) finish(err error) error {
+	switch r.ctx.Err() {
+	case context.DeadlineExceeded:
+		if err == nil || err == context.DeadlineExceeded {
+			err = errors.Errorf("task [%v][%v] timed out", r.TaskID, r.Desc)
+		}
+	case context.Canceled:
+		r.canceled = true
+	}
+	canceled := r.canceled
+	r.cancel()
+	runs.Delete(r.ID)
+
+	if !historyEnabled.Load() || !orm.IsEnabled() {
+		return err
+	}
+
+	record := r.toTask()
+	end := time.Now()
+	record.CompletedTime = &end
+	record.Metadata.Labels["duration_in_ms"] = end.Sub(r.StartTime).Milliseconds()
+	record.Result = &TaskResult{Success: err == nil && !canceled}
+	if err != nil {
+		record.Result.Error = err.Error()
+	}
+	switch {
+	case canceled:
+		record.Status = StatusStopped
+	case err != nil:
+		record.Status = StatusError
+	default:
+		record.Status = StatusComplete
+	}
+	if err := orm.Create(nil, record); err != nil {
+		log.Warnf("failed to save execution of task [%v][%v], %v", r.TaskID, r.Desc, err)
+	}
+	return err
+}
+
+func (r *taskRun) toTask() *Task {
+	record := &Task{
+		ParentId:          []string{r.TaskID},
+		StartTimeInMillis: r.StartTime.UnixMilli(),
+		Cancellable:       true,
+		RetryTimes:        r.Attempt,
+		Status:            StatusRunning,
+		Description:       r.Desc,
+		Metadata: Metadata{
+			Type: TaskRunType,
+			Labels: map[string]interface{}{
+				"task_id": r.TaskID,
+				"group":   r.Group,
+				"type":    r.Type,
+				"node_id": global.Env().SystemConfig.NodeConfig.ID,
+			},
+		},
+	}
+	record.ID = r.ID
+	record.Created = &r.StartTime
+	return record
+}
+
+// CancelTask cancels the running executions of the task, id can be the task id or the id of an execution,
+// returns the number of canceled executions
+func CancelTask(id string) int {
+	var canceled int
+	runs.Range(func(key, value any) bool {
+		r := value.(*taskRun)
+		if r.ID == id || r.TaskID == id {
+			log.Infof("cancel execution [%v] of task [%v][%v]", r.ID, r.TaskID, r.Desc)
+			r.cancel()
+			canceled++
+		}
+		return true
+	})
+	return canceled
+}
+
+// GetRunningExecutions returns the executions of the task which are still running
+func GetRunningExecutions(taskID string) []*Task {
+	output := []*Task{}
+	runs.Range(func(key, value any) bool {
+		r := value.(*taskRun)
+		if r.TaskID == taskID {
+			output = append(output, r.toTask())
+		}
+		return true
+	})
+	sort.Slice(output, func(i, j int) bool {
+		return output[i].StartTimeInMillis > output[j].StartTimeInMillis
+	})
+	return output
+}
+
+// CleanupHistory deletes the execution records started before the retention
+func CleanupHistory(retention time.Duration) error {
+	if retention <= 0 || !orm.IsEnabled() {
+		return nil
+	}
+	before := time.Now().Add(-retention).UnixMilli()
+	return orm.DeleteBy(Task{}, orm.And(orm.Eq("metadata.type", TaskRunType), orm.Lt("start_time_in_millis", before)))
+}
+
+// GetHistory returns the finished executions of the task, latest first
+func GetHistory(taskID string, from, size int) ([]*Task, int64, error) {
+	if !orm.IsEnabled() {
+		return nil, 0, errors.New("task history requires ORM")
+	}
+	q := &orm.Query{
+		From:  from,
+		Size:  size,
+		Conds: orm.And(orm.Eq("parent_id", taskID), orm.Eq("metadata.type", TaskRunType)),
+		Sort:  &[]orm.Sort{{Field: "start_time_in_millis", SortType: orm.DESC}},
+	}
+	err, result := orm.Search(Task{}, q)
+	if err != nil {
+		return nil, 0, err
+	}
+	output := make([]*Task, 0, len(result.Result))
+	for _, v := range result.Result {
+		record := &Task{}
+		if err := util.FromJSONBytes(util.MustToJSONBytes(v), record); err != nil {
+			return nil, 0, errors.Errorf("invalid task record: %v", err)
+		}
+		output = append(output, record)
+	}
+	return output, result.Total, nil
+}
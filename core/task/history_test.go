@@ -0,0 +1,58 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package task
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancelTransientTask(t *testing.T) {
+	done := make(chan error, 1)
+	id := RunWithContext("blocking", func(ctx context.Context) error {
+		<-ctx.Done()
+		done <- ctx.Err()
+		return ctx.Err()
+	}, context.WithValue(context.Background(), "key", "value"))
+
+	running := GetRunningExecutions(id)
+	assert.Equal(t, 1, len(running))
+	assert.Equal(t, id, running[0].ParentId[0])
+	assert.Equal(t, StatusRunning, running[0].Status)
+
+	StopTask(id)
+	select {
+	case err := <-done:
+		assert.Equal(t, context.Canceled, err)
+	case <-time.After(time.Second):
+		t.Fatal("transient task was not canceled")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, 0, len(GetRunningExecutions(id)))
+	assert.Equal(t, 0, CancelTask(id))
+}
@@ -0,0 +1,171 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package task
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/rubyniu105/framework/core/kv"
+	"github.com/rubyniu105/framework/core/task/chrono"
+	"github.com/rubyniu105/framework/core/util"
+)
+
+// misfire policies of crontab tasks, decide what to do with the runs missed while the process was down
+const (
+	MisfireSkip    = "skip"
+	MisfireRunOnce = "run_once"
+	MisfireRunAll  = "run_all"
+)
+
+const lastFireBucket = "task_last_fire"
+
+// maxMisfireRuns limits the catch-up runs of run_all
+const maxMisfireRuns = 100
+
+type fireTimeKey struct{}
+
+// GetFireTime returns the scheduled time of the crontab run, it's in the past for catch-up runs
+func GetFireTime(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(fireTimeKey{}).(time.Time)
+	return t, ok
+}
+
+func getLastFireTime(taskID string) (time.Time, error) {
+	v, err := kv.GetValue(lastFireBucket, []byte(taskID))
+	if err != nil || v == nil {
+		return time.Time{}, err
+	}
+	unix, err := strconv.ParseInt(string(v), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(unix, 0), nil
+}
+
+// saveLastFireTime records the fire time, unless a later one was already recorded
+func saveLastFireTime(taskID string, t time.Time) {
+	if !kv.IsEnabled() {
+		return
+	}
+	if last, _ := getLastFireTime(taskID); last.After(t) {
+		return
+	}
+	err := kv.AddValue(lastFireBucket, []byte(taskID), []byte(strconv.FormatInt(t.Unix(), 10)))
+	if err != nil {
+		log.Warnf("failed to save last fire time of task [%v], %v", taskID, err)
+	}
+}
+
+// getMissedFireTimes returns the fire times after last and before now, the latest ones are kept if exceeded the limit
+func getMissedFireTimes(crontab string, last, now time.Time, limit int) ([]time.Time, error) {
+	trigger, err := chrono.CreateCronTrigger(crontab, nil)
+	if err != nil {
+		return nil, err
+	}
+	missed := []time.Time{}
+	for t := trigger.NextTime(last); !t.IsZero() && !t.After(now); t = trigger.NextTime(t) {
+		missed = append(missed, t)
+		if len(missed) > limit {
+			missed = missed[1:]
+		}
+	}
+	return missed, nil
+}
+
+// misfireKey identifies the task across restarts, falls back to the hash of group, description and crontab
+// if no explicit id was given, as the generated id changes on every boot
+func (task *ScheduleTask) misfireKey() string {
+	if !task.generatedID {
+		return task.ID
+	}
+	return "crontab_" + util.MD5digest(task.Group+"|"+task.Description+"|"+task.Crontab)
+}
+
+// fireCrontab runs the crontab task with the fire time recorded, the run is delayed by a random jitter if configured
+func (task *ScheduleTask) fireCrontab(ctx context.Context, fireTime time.Time, jitter bool) {
+	saveLastFireTime(task.misfireKey(), fireTime)
+
+	if jitter {
+		if d := util.GetDurationOrDefault(task.Jitter, 0); d > 0 {
+			select {
+			case <-time.After(time.Duration(rand.Int63n(int64(d)))):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+	task.Task(context.WithValue(ctx, fireTimeKey{}, fireTime))
+}
+
+// catchUpMisfires applies the misfire policy to the runs missed since the last fire time
+func (task *ScheduleTask) catchUpMisfires() {
+	if !kv.IsEnabled() {
+		log.Debugf("kv store is not enabled, skip misfire check of task [%v][%v]", task.ID, task.Description)
+		return
+	}
+	policy := task.MisfirePolicy
+	if policy != "" && policy != MisfireSkip && task.generatedID {
+		log.Warnf("task [%v][%v] has misfire policy [%v] but no id, the missed runs are tracked by group, description and crontab, "+
+			"set an explicit id to keep them stable", task.ID, task.Description, policy)
+	}
+
+	key := task.misfireKey()
+	now := time.Now()
+	last, err := getLastFireTime(key)
+	if err != nil {
+		log.Warnf("failed to get last fire time of task [%v], %v", task.ID, err)
+		return
+	}
+	if last.IsZero() {
+		//first time to schedule, runs missed after this can be detected
+		saveLastFireTime(key, now)
+		return
+	}
+
+	if policy == "" || policy == MisfireSkip {
+		return
+	}
+
+	missed, err := getMissedFireTimes(task.Crontab, last, now, maxMisfireRuns)
+	if err != nil || len(missed) == 0 {
+		return
+	}
+	if policy == MisfireRunOnce {
+		missed = missed[len(missed)-1:]
+	}
+	log.Infof("task [%v][%v] missed runs since [%v], catch up [%v] runs with policy [%v]", task.ID, task.Description, last, len(missed), policy)
+
+	go func() {
+		for _, t := range missed {
+			if task.State == Canceled {
+				return
+			}
+			task.fireCrontab(context.Background(), t, false)
+		}
+	}()
+}
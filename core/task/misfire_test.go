@@ -0,0 +1,110 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package task
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rubyniu105/framework/core/kv"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	kv.Register("memory", kv.NewMemoryStore())
+}
+
+func TestMissedFireTimes(t *testing.T) {
+	last := time.Date(2024, 1, 1, 23, 0, 0, 0, time.Local)
+	now := time.Date(2024, 1, 4, 1, 0, 0, 0, time.Local)
+	missed, err := getMissedFireTimes("0 0 0 * * *", last, now, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(missed))
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local), missed[0])
+	assert.Equal(t, time.Date(2024, 1, 4, 0, 0, 0, 0, time.Local), missed[2])
+
+	//keep the latest ones
+	missed, _ = getMissedFireTimes("0 0 0 * * *", last, now, 2)
+	assert.Equal(t, 2, len(missed))
+	assert.Equal(t, time.Date(2024, 1, 3, 0, 0, 0, 0, time.Local), missed[0])
+}
+
+func TestMisfirePolicy(t *testing.T) {
+	lock := sync.Mutex{}
+	fired := map[string][]time.Time{}
+	newTask := func(id, policy string) *ScheduleTask {
+		task := &ScheduleTask{ID: id, Type: Crontab, Crontab: "0 0 * * * *", MisfirePolicy: policy}
+		task.Task = func(ctx context.Context) {
+			t, _ := GetFireTime(ctx)
+			lock.Lock()
+			fired[id] = append(fired[id], t)
+			lock.Unlock()
+		}
+		return task
+	}
+
+	//the process was down for the last 3 hours
+	last := time.Now().Add(-3*time.Hour - time.Minute)
+	for _, policy := range []string{MisfireSkip, MisfireRunOnce, MisfireRunAll} {
+		saveLastFireTime("misfire_"+policy, last)
+		newTask("misfire_"+policy, policy).catchUpMisfires()
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, 0, len(fired["misfire_skip"]))
+	assert.Equal(t, 1, len(fired["misfire_run_once"]))
+	assert.Equal(t, 3, len(fired["misfire_run_all"]))
+	assert.True(t, fired["misfire_run_all"][0].Before(fired["misfire_run_all"][2]))
+
+	latest, _ := getLastFireTime("misfire_run_all")
+	assert.Equal(t, fired["misfire_run_all"][2].Unix(), latest.Unix())
+
+	//first time to schedule
+	newTask("misfire_new", MisfireRunAll).catchUpMisfires()
+	recorded, _ := getLastFireTime("misfire_new")
+	assert.False(t, recorded.IsZero())
+}
+
+func TestMisfireKey(t *testing.T) {
+	task := &ScheduleTask{ID: "explicit", Group: "g", Description: "d", Crontab: "0 0 * * * *"}
+	assert.Equal(t, "explicit", task.misfireKey())
+
+	//generated ids change on every boot, the key must not
+	register := func(crontab string) *ScheduleTask {
+		task := &ScheduleTask{Group: "g", Description: "d", Crontab: crontab, Task: func(ctx context.Context) {}}
+		_, err := registerScheduleTask(task)
+		assert.Nil(t, err)
+		Tasks.Delete(task.ID)
+		return task
+	}
+	t1 := register("0 0 * * * *")
+	t2 := register("0 0 * * * *")
+	assert.NotEqual(t, t1.ID, t2.ID)
+	assert.Equal(t, t1.misfireKey(), t2.misfireKey())
+	assert.NotEqual(t, t1.misfireKey(), register("0 30 * * * *").misfireKey())
+}
@@ -77,24 +77,14 @@ func registerTransientTask(group, tag string, f func(ctx context.Context) error,
 	task.Ctx = ctxInput
 	Tasks.Store(task.ID, &task)
 
+	run := startRun(&task, ctxInput, 0, 0)
+
 	go func(func2 func(ctx context.Context) error) {
 
 		defer func() {
-			if !global.Env().IsDebug {
-				if r := recover(); r != nil {
-					var v string
-					switch r.(type) {
-					case error:
-						v = r.(error).Error()
-					case runtime.Error:
-						v = r.(runtime.Error).Error()
-					case string:
-						v = r.(string)
-					}
-					log.Error(r, v)
-				}
+			if task.State != Canceled {
+				task.State = Finished
 			}
-			task.State = Finished
 			t := time.Now()
 			task.EndTime = &t
 			Tasks.Delete(task.ID)
@@ -104,13 +94,10 @@ func registerTransientTask(group, tag string, f func(ctx context.Context) error,
 		task.StartTime = &t
 		task.EndTime = nil
 		task.State = Running
-		err := func2(ctxInput)
+		err := run.execute(func2)
 		if err != nil {
 			log.Error(err)
 		}
-		t = time.Now()
-		task.EndTime = &t
-		task.State = Finished
 	}(f)
 	return task.ID
 }
@@ -129,21 +116,59 @@ type ScheduleTask struct {
 	// Ensures the task runs as a singleton, preventing duplicate executions when previous attempt is not finished.
 	Singleton bool `config:"singleton" json:"singleton,omitempty"`
 
-	Task     func(ctx context.Context) `config:"-" json:"-"`
+	// Timeout cancels the context of each run after the duration, the task is expected to respect the context.
+	Timeout string `config:"timeout" json:"timeout,omitempty"`
+	// RetryTimes retries a failed run with backoff, starting from RetryDelay and doubled every retry.
+	RetryTimes int    `config:"retry_times" json:"retry_times,omitempty"`
+	RetryDelay string `config:"retry_delay" json:"retry_delay,omitempty"`
+	// DependsOn triggers the task after all the parent tasks completed successfully.
+	DependsOn []string `config:"depends_on" json:"depends_on,omitempty"`
+	// MisfirePolicy decides whether to run the crontab task for the runs missed while the process was down,
+	// `skip` by default, or `run_once`, `run_all`.
+	MisfirePolicy string `config:"misfire_policy" json:"misfire_policy,omitempty"`
+	// Jitter delays each crontab run randomly within the duration, to spread the load across nodes.
+	Jitter string `config:"jitter" json:"jitter,omitempty"`
+
+	Task func(ctx context.Context) `config:"-" json:"-"`
+	// Runner is used instead of Task when set, the returned error marks the run as failed and will be retried.
+	Runner   func(ctx context.Context) error `config:"-" json:"-"`
 	taskItem chrono.ScheduledTask
 	State    State           `config:"state" json:"state,omitempty"`
 	Ctx      context.Context `config:"-" json:"-"` //for transient task
 
-	isTaskRunning atomic.Bool
+	isTaskRunning  atomic.Bool
+	misfireChecked bool
+	//the id was generated on registration, it changes on every boot
+	generatedID bool
+
+	depsLock sync.Mutex
+	depsDone map[string]bool
 }
 
 const Interval = "interval"
 const Crontab = "crontab"
 const Transient = "transient"
+const Dependent = "dependent"
 
+const defaultRetryDelay = time.Second
+const maxRetryDelay = 5 * time.Minute
+
+// RegisterScheduleTask registers the task, invalid tasks like circular dependencies are logged and skipped
 func RegisterScheduleTask(task ScheduleTask) (taskID string) {
+	taskID, err := registerScheduleTask(&task)
+	if err != nil {
+		log.Errorf("failed to register task [%v][%v], %v", task.ID, task.Description, err)
+	}
+	return taskID
+}
+
+func registerScheduleTask(task *ScheduleTask) (taskID string, err error) {
 	if task.ID == "" {
 		task.ID = util.GetUUID()
+		task.generatedID = true
+	}
+	if err := ValidateDependencies(task.ID, task.DependsOn); err != nil {
+		return "", err
 	}
 	task.CreateTime = time.Now()
 	task.State = Pending
@@ -151,10 +176,25 @@ func RegisterScheduleTask(task ScheduleTask) (taskID string) {
 		task.Type = Interval
 	} else if task.Type == "" && task.Crontab != "" {
 		task.Type = Crontab
+	} else if task.Type == "" && len(task.DependsOn) > 0 {
+		task.Type = Dependent
 	}
 
+	timeout := util.GetDurationOrDefault(task.Timeout, 0)
+	retryDelay := util.GetDurationOrDefault(task.RetryDelay, defaultRetryDelay)
+
 	tempTask := task.Task
+	runner := task.Runner
+	if runner == nil {
+		runner = func(ctx context.Context) error {
+			tempTask(ctx)
+			return nil
+		}
+	}
 	task.Task = func(ctx context.Context) {
+		if ctx == nil {
+			ctx = context.Background()
+		}
 
 		//for scheduled task, you may need to prevent task rerun
 		if task.Singleton {
@@ -187,11 +227,40 @@ func RegisterScheduleTask(task ScheduleTask) (taskID string) {
 		task.StartTime = &t
 		task.EndTime = nil
 
-		tempTask(ctx)
+		var err error
+		delay := retryDelay
+		for attempt := 0; ; attempt++ {
+			run := startRun(task, ctx, timeout, attempt)
+			err = run.execute(runner)
+			if err == nil || run.canceled || attempt >= task.RetryTimes {
+				break
+			}
+			log.Warnf("error on task [%v][%v], retry #%v in %v, %v", task.ID, task.Description, attempt+1, delay, err)
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			delay *= 2
+			if delay > maxRetryDelay {
+				delay = maxRetryDelay
+			}
+		}
+		if err != nil {
+			log.Errorf("error on task [%v][%v], %v", task.ID, task.Description, err)
+		}
 
 		t = time.Now()
 		task.EndTime = &t
-		task.State = Finished
+		if task.State != Canceled {
+			task.State = Finished
+		}
+
+		if err == nil && ctx.Err() == nil {
+			triggerDependents(task.ID)
+		}
 	}
 
 	_, ok := Tasks.Load(task.ID)
@@ -199,14 +268,14 @@ func RegisterScheduleTask(task ScheduleTask) (taskID string) {
 		StopTask(task.ID)
 	}
 
-	Tasks.Store(task.ID, &task)
+	Tasks.Store(task.ID, task)
 
 	//start after register
 	if started {
-		runTask(&task)
+		runTask(task)
 	}
 
-	return task.ID
+	return task.ID, nil
 }
 
 var quit = make(chan struct{})
@@ -244,12 +313,23 @@ func runTask(task *ScheduleTask) {
 		task.taskItem = task1
 		break
 	case Crontab:
-		task1, err := taskScheduler.ScheduleWithCron(task.Task, task.Crontab)
+		task1, err := taskScheduler.ScheduleWithCron(func(ctx context.Context) {
+			task.fireCrontab(ctx, time.Now(), true)
+		}, task.Crontab)
 		if err != nil {
 			log.Error("failed to scheduled crontab task:", task.Type, ",", task.Interval, ",", task.Description)
+			break
 		}
 		task.State = Running
 		task.taskItem = task1
+		if !task.misfireChecked {
+			task.misfireChecked = true
+			task.catchUpMisfires()
+		}
+		break
+	case Dependent:
+		//triggered by parent tasks
+		task.State = Running
 		break
 	case Transient:
 		//no need to schedule
@@ -285,16 +365,21 @@ func StopTask(id string) {
 						item.taskItem.Cancel()
 						item.State = Canceled
 					}
+					CancelTask(item.ID)
 					break
 				case Crontab:
 					if item.taskItem != nil {
 						item.taskItem.Cancel()
 						item.State = Canceled
 					}
+					CancelTask(item.ID)
+					break
+				case Dependent:
+					item.State = Canceled
+					CancelTask(item.ID)
 					break
 				case Transient:
-					if item.Ctx != nil {
-						item.Ctx.Done()
+					if CancelTask(item.ID) > 0 {
 						item.State = Canceled
 					}
 				}
@@ -5,7 +5,8 @@ package basic
 import (
 	"context"
 	"errors"
-	"github.com/rubyniu105/framework/lib/fasthttp"
+	"net/http"
+
 	"github.com/rubyniu105/framework/lib/guardian/auth"
 )
 
@@ -23,26 +24,26 @@ var (
 // the authenticate function invoked by Authenticate Strategy method after extracting user credentials
 // to compare against DB or other service, if extracting user credentials from request failed a nil info
 // with ErrMissingPrams returned, Otherwise, return Authenticate invocation result.
-type AuthenticateFunc func(ctx context.Context, r *fasthttp.Request, userName, password []byte) (auth.Info, error)
+type AuthenticateFunc func(ctx context.Context, r *http.Request, userName, password []byte) (auth.Info, error)
 
 type basic struct {
 	fn     AuthenticateFunc
 	parser Parser
 }
 
-func (b basic) Authenticate(ctx context.Context, r *fasthttp.Request) (auth.Info, error) {
-	exists, user, pass := r.ParseBasicAuth()
-	if !exists {
-		return nil, errors.New("failed to Authenticate")
+func (b basic) Authenticate(ctx context.Context, r *http.Request) (auth.Info, error) {
+	user, pass, err := b.parser.Credentials(r)
+	if err != nil {
+		return nil, err
 	}
-	return b.fn(ctx, r, user, pass)
+	return b.fn(ctx, r, []byte(user), []byte(pass))
 }
 
 // New return new auth.Strategy.
 func New(fn AuthenticateFunc, opts ...auth.Option) auth.Strategy {
 	b := new(basic)
 	b.fn = fn
-	//b.parser = AuthorizationParser()
+	b.parser = AuthorizationParser()
 	for _, opt := range opts {
 		opt.Apply(b)
 	}
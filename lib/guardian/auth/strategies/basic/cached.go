@@ -3,9 +3,9 @@ package basic
 import (
 	"context"
 	"github.com/rubyniu105/framework/core/util"
-	"github.com/rubyniu105/framework/lib/fasthttp"
 	"github.com/rubyniu105/framework/lib/guardian/auth"
 	"github.com/rubyniu105/framework/lib/guardian/auth/internal"
+	"net/http"
 )
 
 // ExtensionKey represents a key for the password in info extensions.
@@ -40,7 +40,7 @@ type cachedBasic struct {
 	hasher     internal.Hasher
 }
 
-func (c *cachedBasic) authenticate(ctx context.Context, r *fasthttp.Request, userName, pass []byte) (auth.Info, error) { // nolint:lll
+func (c *cachedBasic) authenticate(ctx context.Context, r *http.Request, userName, pass []byte) (auth.Info, error) { // nolint:lll
 	hash := c.hasher.Hash(util.UnsafeBytesToString(userName))
 	v, ok := c.cache.Load(hash)
 
@@ -57,7 +57,7 @@ func (c *cachedBasic) authenticate(ctx context.Context, r *fasthttp.Request, use
 	return ent.info, c.comparator.Compare(ent.password, util.UnsafeBytesToString(pass))
 }
 
-func (c *cachedBasic) authenticatAndHash(ctx context.Context, r *fasthttp.Request, hash string, userName, pass []byte) (auth.Info, error) { //nolint:lll
+func (c *cachedBasic) authenticatAndHash(ctx context.Context, r *http.Request, hash string, userName, pass []byte) (auth.Info, error) { //nolint:lll
 	info, err := c.fn(ctx, r, userName, pass)
 	if err != nil {
 		return nil, err
@@ -9,9 +9,9 @@ import (
 	"fmt"
 	"github.com/go-ldap/ldap/v3"
 	"github.com/rubyniu105/framework/core/util"
-	"github.com/rubyniu105/framework/lib/fasthttp"
 	"github.com/rubyniu105/framework/lib/guardian/auth"
 	"github.com/rubyniu105/framework/lib/guardian/auth/strategies/basic"
+	"net/http"
 	"strings"
 )
 
@@ -72,7 +72,7 @@ type client struct {
 	cfg  *Config
 }
 
-func (c client) authenticate(ctx context.Context, r *fasthttp.Request, userName, password []byte) (auth.Info, error) { //nolint:lll
+func (c client) authenticate(ctx context.Context, r *http.Request, userName, password []byte) (auth.Info, error) { //nolint:lll
 	l, err := c.dial(c.cfg)
 
 	if err != nil {
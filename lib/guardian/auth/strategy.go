@@ -3,7 +3,7 @@ package auth
 import (
 	"context"
 	"errors"
-	"github.com/rubyniu105/framework/lib/fasthttp"
+	"net/http"
 )
 
 // ErrInvalidStrategy is returned by Append/Revoke functions,
@@ -13,7 +13,7 @@ var ErrInvalidStrategy = errors.New("auth: Invalid strategy")
 // Strategy represents an authentication mechanism or method to authenticate users requests.
 type Strategy interface {
 	// Authenticate users requests and return user information or error.
-	Authenticate(ctx context.Context, r *fasthttp.Request) (Info, error)
+	Authenticate(ctx context.Context, r *http.Request) (Info, error)
 }
 
 // Option configures Strategy using the functional options paradigm popularized by Rob Pike and Dave Cheney.
@@ -57,10 +57,10 @@ func (module *APIModule) Name() string {
 }
 
 func init() {
-	api.HandleAPIMethod(api.GET, "/_whoami", whoisAPIHandler)
-	api.HandleAPIMethod(api.GET, "/_version", versionAPIHandler)
-	api.HandleAPIMethod(api.GET, "/_info", infoAPIHandler)
-	api.HandleAPIMethod(api.GET, "/health", healthAPIHandler)
+	api.HandleAPIMethod(api.GET, "/_whoami", whoisAPIHandler, api.AllowAuthenticated())
+	api.HandleAPIMethod(api.GET, "/_version", versionAPIHandler, api.RequirePermission("system:read"))
+	api.HandleAPIMethod(api.GET, "/_info", infoAPIHandler, api.RequirePermission("system:read"))
+	api.HandleAPIMethod(api.GET, "/health", healthAPIHandler, api.AllowAuthenticated())
 }
 
 func whoisAPIHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
@@ -162,7 +162,7 @@ func (module *APIModule) Setup() {
 		if p1 == "" {
 			p1 = "/"
 		}
-		api.HandleAPIMethod(api.GET, p1, defaultHandler)
+		api.HandleAPIMethod(api.GET, p1, defaultHandler, api.RequirePermission("system:read"))
 	}
 }
 
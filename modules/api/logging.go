@@ -0,0 +1,97 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI Ltd. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rubyniu105/framework/core/api"
+	httprouter "github.com/rubyniu105/framework/core/api/router"
+	"github.com/rubyniu105/framework/core/config"
+	"github.com/rubyniu105/framework/core/logging/logger"
+	"github.com/rubyniu105/framework/core/util"
+)
+
+func init() {
+	api.HandleAPIMethod(api.GET, "/_logging", getLoggingAPIHandler, api.RequirePermission("logging:read"))
+	api.HandleAPIMethod(api.PUT, "/_logging", updateLoggingAPIHandler, api.RequirePermission("logging:write"))
+	api.HandleAPIMethod(api.DELETE, "/_logging", revertLoggingAPIHandler, api.RequirePermission("logging:write"))
+}
+
+type loggingRequest struct {
+	Level     string                    `json:"level,omitempty"`
+	Overrides []config.LogLevelOverride `json:"overrides,omitempty"`
+	//revert the change after ttl, eg: 30m, empty means permanent
+	TTL string `json:"ttl,omitempty"`
+}
+
+func getLoggingAPIHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	levels, err := logger.GetLogLevels()
+	if err != nil {
+		api.DefaultAPI.WriteError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.DefaultAPI.WriteOKJSON(w, levels)
+}
+
+func updateLoggingAPIHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	request := loggingRequest{}
+	err := api.DefaultAPI.DecodeJSON(req, &request)
+	if err != nil {
+		api.DefaultAPI.Error400(w, err.Error())
+		return
+	}
+
+	var ttl time.Duration
+	if request.TTL != "" {
+		ttl, err = util.ParseDuration(request.TTL)
+		if err != nil || ttl <= 0 {
+			api.DefaultAPI.Error400(w, "invalid ttl: "+request.TTL)
+			return
+		}
+	}
+
+	err = logger.UpdateLogLevels(request.Level, request.Overrides, ttl)
+	if err != nil {
+		api.DefaultAPI.Error400(w, err.Error())
+		return
+	}
+
+	levels, err := logger.GetLogLevels()
+	if err != nil {
+		api.DefaultAPI.WriteError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.DefaultAPI.WriteOKJSON(w, levels)
+}
+
+func revertLoggingAPIHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	reverted := logger.RevertLogLevels()
+	api.DefaultAPI.WriteAckJSON(w, true, http.StatusOK, util.MapStr{"reverted": reverted})
+}
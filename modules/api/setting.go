@@ -40,8 +40,10 @@ import (
 )
 
 func init() {
-	api.HandleAPIFunc("/setting/logger", LoggingSettingAction)
-	api.HandleAPIMethod(api.GET, "/setting/application", appSettingsAPIHandler)
+	api.HandleAPIMethod(api.GET, "/setting/logger", loggingSettingAPIHandler, api.RequirePermission("logging:read"))
+	api.HandleAPIMethod(api.PUT, "/setting/logger", loggingSettingAPIHandler, api.RequirePermission("logging:write"))
+	api.HandleAPIMethod(api.POST, "/setting/logger", loggingSettingAPIHandler, api.RequirePermission("logging:write"))
+	api.HandleAPIMethod(api.GET, "/setting/application", appSettingsAPIHandler, api.RequirePermission("setting:read"))
 }
 
 // LoggingSettingAction is the ajax request to update logging config
@@ -74,6 +76,10 @@ func LoggingSettingAction(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+func loggingSettingAPIHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	LoggingSettingAction(w, req)
+}
+
 func appSettingsAPIHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	obj := util.MapStr{
 		"auth_enabled": api.IsAuthEnable(),
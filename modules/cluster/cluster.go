@@ -0,0 +1,117 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package cluster
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rubyniu105/framework/core/api"
+	httprouter "github.com/rubyniu105/framework/core/api/router"
+	"github.com/rubyniu105/framework/core/cluster"
+	"github.com/rubyniu105/framework/core/global"
+	"github.com/rubyniu105/framework/core/rpc"
+	"github.com/rubyniu105/framework/core/util"
+)
+
+type ClusterModule struct {
+	api.Handler
+	membership *cluster.Membership
+}
+
+func (module *ClusterModule) Name() string {
+	return "cluster"
+}
+
+func (module *ClusterModule) Setup() {
+	api.HandleAPIMethod(api.GET, "/_cluster/nodes", module.GetNodes, api.RequirePermission("cluster:read"))
+
+	cfg := global.Env().SystemConfig.ClusterConfig
+	if !cfg.Enabled {
+		return
+	}
+
+	rpc.Setup(&global.Env().SystemConfig.ClusterConfig.RPCConfig)
+
+	nodeCfg := global.Env().SystemConfig.NodeConfig
+	local := cluster.Node{
+		ID:        nodeCfg.ID,
+		Name:      nodeCfg.Name,
+		StartTime: time.Now().UnixMilli(),
+		Labels:    nodeCfg.Labels,
+	}
+	module.membership = cluster.NewMembership(cfg, local, &cluster.RPCTransport{})
+	cluster.RegisterRPCService(rpc.GetRPCServer(), module.membership)
+}
+
+func (module *ClusterModule) Start() error {
+	if module.membership == nil {
+		return nil
+	}
+
+	rpc.StartRPCServer()
+
+	rpcCfg := global.Env().SystemConfig.ClusterConfig.RPCConfig.NetworkConfig
+	addr := util.GetSafetyInternalAddress(rpc.GetRPCAddress())
+	if rpcCfg.Publish != "" {
+		addr = rpcCfg.GetPublishAddr()
+	}
+	module.membership.SetLocalAddress(addr)
+
+	cluster.SetMembership(module.membership)
+	module.membership.Start()
+	return nil
+}
+
+func (module *ClusterModule) Stop() error {
+	if module.membership == nil {
+		return nil
+	}
+	module.membership.Stop()
+	cluster.SetMembership(nil)
+	if s := rpc.GetRPCServer(); s != nil {
+		s.Stop()
+	}
+	return nil
+}
+
+func (module *ClusterModule) GetNodes(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	cfg := global.Env().SystemConfig.ClusterConfig
+	if module.membership == nil {
+		module.WriteError(w, "cluster is not enabled", http.StatusNotFound)
+		return
+	}
+
+	output := util.MapStr{
+		"cluster_name":  cfg.Name,
+		"minimum_nodes": cfg.MinimumNodes,
+		"local_node":    module.membership.GetLocalNode().ID,
+		"term":          module.membership.GetTerm(),
+		"nodes":         module.membership.GetNodes(),
+	}
+	if leader := module.membership.GetLeader(); leader != nil {
+		output["leader"] = leader.ID
+	}
+	module.WriteJSON(w, output, http.StatusOK)
+}
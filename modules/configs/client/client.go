@@ -39,6 +39,7 @@ import (
 	"github.com/rubyniu105/framework/core/model"
 	"github.com/rubyniu105/framework/core/task"
 	"github.com/rubyniu105/framework/core/util"
+	kslib "github.com/rubyniu105/framework/lib/keystore"
 	"github.com/rubyniu105/framework/modules/configs/common"
 	"github.com/rubyniu105/framework/modules/configs/config"
 	"net/http"
@@ -75,7 +76,11 @@ func ConnectToManager() error {
 		return errors.Errorf("no config manager was found")
 	}
 
-	info := model.GetInstanceInfo()
+	_, publicKey, err := getKeyPair()
+	if err != nil {
+		return err
+	}
+	info := common.InstanceRegisterRequest{Instance: model.GetInstanceInfo(), PublicKey: string(publicKey)}
 
 	req := util.Request{Method: util.Verb_POST}
 	req.ContentType = "application/json"
@@ -115,6 +120,32 @@ func submitRequestToManager(req *util.Request) (string, *util.Result, error) {
 	return "", nil, err
 }
 
+var keyPairLock = sync.Mutex{}
+
+// getKeyPair loads the key pair of instance from the local keystore, generates one on first use,
+// the manager pins the public key on registration, so it must survive restarts
+func getKeyPair() (privateKey, publicKey []byte, err error) {
+	keyPairLock.Lock()
+	defer keyPairLock.Unlock()
+
+	privateKey, err = keystore.GetValue(common.InstancePrivateKeyName)
+	if err != nil && err != kslib.ErrKeyDoesntExists {
+		return nil, nil, err
+	}
+	if len(privateKey) == 0 {
+		privateKey, publicKey, err = common.GenerateKeyPair()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err = keystore.SetValue(common.InstancePrivateKeyName, privateKey); err != nil {
+			return nil, nil, err
+		}
+		return privateKey, publicKey, nil
+	}
+	publicKey, err = common.GetPublicKey(privateKey)
+	return privateKey, publicKey, err
+}
+
 var clientInitLock = sync.Once{}
 var mTLSClient *http.Client
 
@@ -136,6 +167,13 @@ func ListenConfigChanges() error {
 			req := common.ConfigSyncRequest{}
 			req.Client = model.GetInstanceInfo()
 
+			//the key pair was pinned by manager on registration
+			privateKey, publicKey, err := getKeyPair()
+			if err != nil {
+				panic(err)
+			}
+			req.PublicKey = string(publicKey)
+
 			var syncFunc = func() {
 				if global.Env().IsDebug {
 					log.Trace("fetch configs from manger")
@@ -161,6 +199,18 @@ func ListenConfigChanges() error {
 					return
 				}
 
+				if res != nil && res.StatusCode == http.StatusNotFound {
+					//instances registered by older managers are not bound to the user, register again
+					log.Warn("instance is not registered to config manager, register again")
+					if err := kv.DeleteKey(bucketName, []byte(global.Env().SystemConfig.NodeConfig.ID)); err != nil {
+						log.Error(err)
+					}
+					if err := ConnectToManager(); err != nil {
+						log.Error("failed to register to config manager,", err)
+					}
+					return
+				}
+
 				if res != nil {
 					obj := common.ConfigSyncResponse{}
 					err := util.FromJSONBytes(res.Body, &obj)
@@ -174,18 +224,26 @@ func ListenConfigChanges() error {
 
 					if obj.Changed {
 
-						//update secrets //TODO client send salt to manager first, manager encrypt secrets with salt and send back
+						//update secrets
 						if obj.Secrets != nil {
-							for k, v := range obj.Secrets.Keystore {
-								if v.Type == "plaintext" {
-									err := saveKeystore(k, v.Value)
-									if err != nil {
-										log.Error("error on save keystore:", k, ",", err)
-									}
+							secrets, err := common.DecryptSecrets(obj.Secrets, privateKey)
+							if err != nil {
+								log.Error("error on decrypt secrets,", err)
+							}
+							for k, v := range secrets {
+								if common.IsReservedSecret(k) {
+									log.Errorf("secret [%v] is reserved, skip saving", k)
+									continue
+								}
+								if obj.Secrets.Keystore[k].Type == common.SecretTypePlaintext {
+									log.Warnf("secret [%v] was delivered in plaintext", k)
+								}
+								log.Debug("save keystore:", k)
+								err := keystore.SetValue(k, v)
+								if err != nil {
+									log.Error("error on save keystore:", k, ",", err)
 								}
 							}
-
-							//TODO maybe we have other secrets
 						}
 
 						for _, v := range obj.Configs.DeletedConfigs {
@@ -270,19 +328,3 @@ func ListenConfigChanges() error {
 
 	return nil
 }
-
-func saveKeystore(k string, v string) error {
-
-	log.Debug("save keystore:", k)
-
-	ks, err := keystore.GetWriteableKeystore()
-	if err != nil {
-		return err
-	}
-	err = ks.Store(k, util.UnsafeStringToBytes(v))
-	if err != nil {
-		return err
-	}
-	err = ks.Save()
-	return err
-}
@@ -32,6 +32,9 @@ import "github.com/rubyniu105/framework/core/model"
 const REGISTER_API = "/instance/_register"
 const SYNC_API = "/configs/_sync"
 
+// the definition of config groups, instance groups and secrets, located in the root of the configs repo
+const REPO_CONFIG_FILE = "repo.yml"
+
 type ConfigFile struct {
 	Name     string `json:"name,omitempty"`
 	Location string `json:"location,omitempty"`
@@ -57,11 +60,18 @@ type ConfigUpdateRequest struct {
 	Configs map[string]string `json:"configs"`
 }
 
+// InstanceRegisterRequest pins the public key of instance, secrets are only delivered to the pinned key
+type InstanceRegisterRequest struct {
+	model.Instance
+	PublicKey string `json:"public_key,omitempty"` //pem encoded
+}
+
 type ConfigSyncRequest struct {
 	ForceSync bool           `json:"force_sync"` //ignore hash check in server
 	Hash      string         `json:"hash"`
 	Client    model.Instance `json:"client"`
 	Configs   ConfigList     `json:"configs"`
+	PublicKey string         `json:"public_key,omitempty"` //pem encoded, must be the one pinned on registration
 }
 
 type ConfigSyncResponse struct {
@@ -85,18 +95,32 @@ type ConfigGroup struct {
 }
 
 type InstanceGroup struct {
-	ConfigGroups []string `config:"configs"`
-	Instances    []string `config:"instances"`
-	Secrets      []string `config:"secrets"`
+	ConfigGroups []string       `config:"configs"`
+	Instances    []string       `config:"instances"`
+	Secrets      []string       `config:"secrets"`
+	Rollout      *RolloutConfig `config:"rollout"`
+}
+
+// RolloutConfig delivers new config versions to a subset of instances first,
+// the rest stay on the stable versions until the rollout is promoted or removed
+type RolloutConfig struct {
+	Canary     []string `config:"canary" json:"canary,omitempty"`         //instance id or name, wildcard supported
+	Percentage int      `config:"percentage" json:"percentage,omitempty"` //0-100, picked by the hash of instance id
+}
+
+func (cfg *RolloutConfig) IsActive() bool {
+	return cfg != nil && cfg.Percentage < 100
 }
 
 type Secrets struct {
 	Keystore map[string]KeystoreValue `json:"keystore,omitempty"`
+	Key      string                   `json:"key,omitempty"` //base64 encoded data key, encrypted by the public key of instance
 }
 
 type KeystoreValue struct {
 	Type  string `json:"type"`
 	Value string `json:"value"`
+	Salt  string `json:"salt,omitempty"`
 }
 
 type ConfigRepo struct {
@@ -0,0 +1,171 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package common
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/util"
+)
+
+const SecretTypePlaintext = "plaintext"
+
+// InstancePrivateKeyName is the keystore key of the instance private key, delivered secrets must not overwrite it
+const InstancePrivateKeyName = "configs_instance_private_key"
+
+// IsReservedSecret tells whether the keystore key is used by the instance itself and can't be delivered
+func IsReservedSecret(name string) bool {
+	return name == InstancePrivateKeyName
+}
+
+// SecretTypeEncrypted values are encrypted by aes-gcm with a random data key,
+// the data key is encrypted by the public key of instance with rsa-oaep
+const SecretTypeEncrypted = "aes_gcm"
+
+// GenerateKeyPair returns the pem encoded private and public key of instance
+func GenerateKeyPair() (privateKey, publicKey []byte, err error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	privateKey = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	publicKey = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub})
+	return privateKey, publicKey, nil
+}
+
+// GetPublicKey returns the pem encoded public key of the private key generated by GenerateKeyPair
+func GetPublicKey(privateKey []byte) ([]byte, error) {
+	block, _ := pem.Decode(privateKey)
+	if block == nil {
+		return nil, errors.New("invalid private key")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}), nil
+}
+
+// EncryptSecrets encrypts all the plaintext values with the public key of instance
+func EncryptSecrets(secrets *Secrets, publicKey []byte) (*Secrets, error) {
+	block, _ := pem.Decode(publicKey)
+	if block == nil {
+		return nil, errors.New("invalid public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not a rsa key")
+	}
+
+	dataKey, err := util.RandomBytes(32)
+	if err != nil {
+		return nil, err
+	}
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, dataKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	output := &Secrets{
+		Keystore: map[string]KeystoreValue{},
+		Key:      base64.StdEncoding.EncodeToString(encryptedKey),
+	}
+	for k, v := range secrets.Keystore {
+		if v.Type != "" && v.Type != SecretTypePlaintext {
+			return nil, errors.Errorf("unsupported secret type [%v] of [%v]", v.Type, k)
+		}
+		value, salt, err := util.AesGcmEncrypt([]byte(v.Value), dataKey)
+		if err != nil {
+			return nil, err
+		}
+		output.Keystore[k] = KeystoreValue{Type: SecretTypeEncrypted, Value: string(value), Salt: string(salt)}
+	}
+	return output, nil
+}
+
+// DecryptSecrets decrypts the values with the private key of instance
+func DecryptSecrets(secrets *Secrets, privateKey []byte) (map[string][]byte, error) {
+	output := map[string][]byte{}
+	var dataKey []byte
+	for k, v := range secrets.Keystore {
+		switch v.Type {
+		case SecretTypePlaintext:
+			output[k] = []byte(v.Value)
+		case SecretTypeEncrypted:
+			if dataKey == nil {
+				key, err := decryptDataKey(secrets.Key, privateKey)
+				if err != nil {
+					return nil, err
+				}
+				dataKey = key
+			}
+			value, err := util.AesGcmDecrypt([]byte(v.Value), dataKey, []byte(v.Salt))
+			if err != nil {
+				return nil, errors.Errorf("failed to decrypt secret [%v]: %v", k, err)
+			}
+			output[k] = value
+		default:
+			return nil, errors.Errorf("unsupported secret type [%v] of [%v]", v.Type, k)
+		}
+	}
+	return output, nil
+}
+
+func decryptDataKey(encryptedKey string, privateKey []byte) ([]byte, error) {
+	block, _ := pem.Decode(privateKey)
+	if block == nil {
+		return nil, errors.New("invalid private key")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	b, err := base64.StdEncoding.DecodeString(encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+	return rsa.DecryptOAEP(sha256.New(), rand.Reader, key, b, nil)
+}
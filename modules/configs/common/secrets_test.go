@@ -0,0 +1,53 @@
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptSecrets(t *testing.T) {
+	privateKey, publicKey, err := GenerateKeyPair()
+	assert.Nil(t, err)
+
+	secrets := &Secrets{Keystore: map[string]KeystoreValue{
+		"es_user":     {Type: SecretTypePlaintext, Value: "elastic"},
+		"es_password": {Value: "123456"},
+	}}
+	encrypted, err := EncryptSecrets(secrets, publicKey)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, encrypted.Key)
+	for _, v := range encrypted.Keystore {
+		assert.Equal(t, SecretTypeEncrypted, v.Type)
+		assert.NotEmpty(t, v.Salt)
+	}
+
+	values, err := DecryptSecrets(encrypted, privateKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "elastic", string(values["es_user"]))
+	assert.Equal(t, "123456", string(values["es_password"]))
+
+	//other instance can't decrypt
+	otherKey, _, err := GenerateKeyPair()
+	assert.Nil(t, err)
+	_, err = DecryptSecrets(encrypted, otherKey)
+	assert.NotNil(t, err)
+
+	_, err = EncryptSecrets(secrets, []byte("invalid"))
+	assert.NotNil(t, err)
+}
+
+func TestGetPublicKey(t *testing.T) {
+	privateKey, publicKey, err := GenerateKeyPair()
+	assert.Nil(t, err)
+	v, err := GetPublicKey(privateKey)
+	assert.Nil(t, err)
+	assert.Equal(t, string(publicKey), string(v))
+
+	_, err = GetPublicKey([]byte("invalid"))
+	assert.NotNil(t, err)
+}
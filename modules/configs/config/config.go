@@ -24,12 +24,12 @@ import (
 )
 
 func init() {
-	api.HandleAPIMethod(api.GET, "/config/", listConfigAction)
-	api.HandleAPIMethod(api.PUT, "/config/", saveConfigAction)
-	api.HandleAPIMethod(api.DELETE, "/config/", deleteConfigAction)
-	api.HandleAPIMethod(api.POST, "/config/_reload", reloadConfigAction)
-	api.HandleAPIMethod(api.GET, "/config/runtime", getConfigAction)
-	api.HandleAPIMethod(api.GET, "/environments", getEnvAction)
+	api.HandleAPIMethod(api.GET, "/config/", listConfigAction, api.RequirePermission("config:read"))
+	api.HandleAPIMethod(api.PUT, "/config/", saveConfigAction, api.RequirePermission("config:write"))
+	api.HandleAPIMethod(api.DELETE, "/config/", deleteConfigAction, api.RequirePermission("config:delete"))
+	api.HandleAPIMethod(api.POST, "/config/_reload", reloadConfigAction, api.RequirePermission("config:write"))
+	api.HandleAPIMethod(api.GET, "/config/runtime", getConfigAction, api.RequirePermission("config:read"))
+	api.HandleAPIMethod(api.GET, "/environments", getEnvAction, api.RequirePermission("config:read"))
 
 }
 
@@ -0,0 +1,140 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package server
+
+import (
+	"net/http"
+
+	log "github.com/cihub/seelog"
+	"github.com/rubyniu105/framework/core/api"
+	httprouter "github.com/rubyniu105/framework/core/api/router"
+	"github.com/rubyniu105/framework/core/global"
+	"github.com/rubyniu105/framework/core/util"
+	"github.com/rubyniu105/framework/modules/configs/common"
+)
+
+type APIHandler struct {
+	api.Handler
+}
+
+// InitAPI registers the manager apis, must be called before the api server starts and only when the manager is enabled
+func InitAPI() {
+	if !global.Env().SystemConfig.APIConfig.Security.Enabled {
+		log.Warn("api security is not enabled, managed instances are not able to register or sync configs")
+	}
+	handler := APIHandler{}
+	api.HandleAPIMethod(api.POST, common.REGISTER_API, handler.registerInstance, api.RequirePermission("configs:sync"))
+	api.HandleAPIMethod(api.POST, common.SYNC_API, handler.syncConfigs, api.RequirePermission("configs:sync"))
+	api.HandleAPIMethod(api.GET, "/configs/_rollout", handler.getRolloutStatus, api.RequirePermission("configs:read"))
+	api.HandleAPIMethod(api.POST, "/configs/_rollout/:group/_promote", handler.promoteRollout, api.RequirePermission("configs:write"))
+}
+
+func (h *APIHandler) checkManager(w http.ResponseWriter) bool {
+	if manager == nil {
+		h.WriteError(w, "config manager is not enabled", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// checkAuthenticated rejects anonymous instances, they are able to overwrite instance records and receive secrets
+func (h *APIHandler) checkAuthenticated(w http.ResponseWriter, req *http.Request) bool {
+	if api.GetUser(req) == nil {
+		h.WriteError(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+func getErrorStatus(err error) int {
+	switch err {
+	case errPublicKeyMismatch, errInstanceOwnerMismatch:
+		return http.StatusForbidden
+	case errInstanceNotRegistered:
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+func (h *APIHandler) registerInstance(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	if !h.checkManager(w) || !h.checkAuthenticated(w, req) {
+		return
+	}
+	request := common.InstanceRegisterRequest{}
+	if err := h.DecodeJSON(req, &request); err != nil {
+		h.WriteError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	created, err := manager.Register(&request, api.GetUser(req).GetUserName())
+	if err != nil {
+		h.WriteError(w, err.Error(), getErrorStatus(err))
+		return
+	}
+	instance := &request.Instance
+	if !created {
+		h.WriteAckJSON(w, true, http.StatusOK, util.MapStr{"result": "exists"})
+		return
+	}
+	log.Infof("instance [%v] registered, endpoint: %v", instance.ID, instance.Endpoint)
+	h.WriteAckJSON(w, true, http.StatusOK, util.MapStr{"result": "created"})
+}
+
+func (h *APIHandler) syncConfigs(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	if !h.checkManager(w) || !h.checkAuthenticated(w, req) {
+		return
+	}
+	request := common.ConfigSyncRequest{}
+	if err := h.DecodeJSON(req, &request); err != nil {
+		h.WriteError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := manager.Sync(&request, api.GetUser(req).GetUserName())
+	if err != nil {
+		h.WriteError(w, err.Error(), getErrorStatus(err))
+		return
+	}
+	h.WriteJSON(w, resp, http.StatusOK)
+}
+
+func (h *APIHandler) getRolloutStatus(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	if !h.checkManager(w) {
+		return
+	}
+	h.WriteJSON(w, manager.GetRolloutStatus(), http.StatusOK)
+}
+
+func (h *APIHandler) promoteRollout(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	if !h.checkManager(w) {
+		return
+	}
+	if err := manager.Promote(ps.MustGetParameter("group")); err != nil {
+		h.WriteError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.WriteAckOKJSON(w)
+}
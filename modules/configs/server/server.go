@@ -0,0 +1,611 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package server
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/rubyniu105/framework/core/config"
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/global"
+	"github.com/rubyniu105/framework/core/kv"
+	"github.com/rubyniu105/framework/core/model"
+	"github.com/rubyniu105/framework/core/stats"
+	"github.com/rubyniu105/framework/core/util"
+	"github.com/rubyniu105/framework/modules/configs/common"
+)
+
+const stateBucket = "configs_manager"                         //versions: file versions, stable: stable versions of instance groups
+const snapshotBucket = "configs_manager_snapshots"            //config_group/file:version: content
+const instanceBucket = "configs_manager_instances"            //instance_id: instance
+const instanceSyncBucket = "configs_manager_last_sync"        //instance_id: sync state
+const instanceKeyBucket = "configs_manager_instance_keys"     //instance_id: public key pinned on registration
+const instanceOwnerBucket = "configs_manager_instance_owners" //instance_id: user bound on registration
+
+var errPublicKeyMismatch = errors.New("public key doesn't match the one pinned on registration")
+var errInstanceOwnerMismatch = errors.New("instance was registered by another user")
+var errInstanceNotRegistered = errors.New("instance is not registered")
+
+type fileVersion struct {
+	Hash    string `json:"hash"`
+	Version int64  `json:"version"`
+}
+
+type instanceSyncState struct {
+	Hash        string `json:"hash"`
+	SecretsHash string `json:"secrets_hash,omitempty"`
+	Timestamp   int64  `json:"timestamp"`
+}
+
+type Manager struct {
+	repoPath string
+
+	lock     sync.RWMutex
+	repo     common.ConfigRepo
+	versions map[string]*fileVersion     //config_group/file: latest version
+	contents map[string]string           //config_group/file: latest content
+	stable   map[string]map[string]int64 //instance_group: config_group/file: stable version
+}
+
+func NewManager(repoPath string) *Manager {
+	return &Manager{
+		repoPath: repoPath,
+		versions: map[string]*fileVersion{},
+		contents: map[string]string{},
+		stable:   map[string]map[string]int64{},
+	}
+}
+
+var manager *Manager
+
+func StartManager() error {
+	cfg := global.Env().SystemConfig.Configs.ManagerConfig
+	if cfg.LocalConfigsRepoPath == "" {
+		return errors.New("configs.manager.local_configs_repo_path is required")
+	}
+
+	m := NewManager(cfg.LocalConfigsRepoPath)
+	if err := m.restore(); err != nil {
+		return err
+	}
+	if err := m.Load(); err != nil {
+		return err
+	}
+	manager = m
+
+	global.RegisterBackgroundCallback(&global.BackgroundTask{
+		Tag:      "reload configs repo",
+		Interval: util.GetDurationOrDefault(cfg.ReloadInterval, time.Duration(30)*time.Second),
+		Func: func() {
+			if err := m.Load(); err != nil {
+				log.Error("failed to reload configs repo,", err)
+			}
+		},
+	})
+	log.Infof("config manager started, repo: %v", cfg.LocalConfigsRepoPath)
+	return nil
+}
+
+func fileKey(configGroup, file string) string {
+	return configGroup + "/" + file
+}
+
+// restore the versions persisted by previous runs, so that versions keep increasing
+func (m *Manager) restore() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if v, err := kv.GetValue(stateBucket, []byte("versions")); err != nil {
+		return err
+	} else if v != nil {
+		if err := util.FromJSONBytes(v, &m.versions); err != nil {
+			return err
+		}
+	}
+	if v, err := kv.GetValue(stateBucket, []byte("stable")); err != nil {
+		return err
+	} else if v != nil {
+		if err := util.FromJSONBytes(v, &m.stable); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) persist() error {
+	if err := kv.AddValue(stateBucket, []byte("versions"), util.MustToJSONBytes(m.versions)); err != nil {
+		return err
+	}
+	return kv.AddValue(stateBucket, []byte("stable"), util.MustToJSONBytes(m.stable))
+}
+
+// Load reads the repo, a file gets a new version when its content hash changed
+func (m *Manager) Load() error {
+	repoFile := path.Join(m.repoPath, common.REPO_CONFIG_FILE)
+	b, err := util.FileGetContent(repoFile)
+	if err != nil {
+		return err
+	}
+	//parse directly, the `configs` section of repo is not a config template
+	cfg, err := config.NewConfigWithYAML(b, repoFile)
+	if err != nil {
+		return err
+	}
+	repo := common.ConfigRepo{}
+	if err := cfg.Unpack(&repo); err != nil {
+		return err
+	}
+	for groupName, group := range repo.SecretGroups {
+		for k := range group.Keystore {
+			if common.IsReservedSecret(k) {
+				return errors.Errorf("secret [%v] of secret group [%v] is reserved", k, groupName)
+			}
+		}
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	contents := map[string]string{}
+	for groupName, group := range repo.ConfigGroups {
+		for _, file := range group.Files {
+			content, err := m.readRepoFile(file)
+			if err != nil {
+				log.Errorf("failed to read file [%v] of config group [%v]: %v", file, groupName, err)
+				continue
+			}
+			key := fileKey(groupName, file)
+			contents[key] = content
+
+			hash := util.MD5digest(content)
+			ver, ok := m.versions[key]
+			if ok && ver.Hash == hash {
+				continue
+			}
+			if !ok {
+				ver = &fileVersion{}
+				m.versions[key] = ver
+			}
+			ver.Hash = hash
+			ver.Version++
+			if err := kv.AddValueCompress(snapshotBucket, []byte(fmt.Sprintf("%v:%v", key, ver.Version)), []byte(content)); err != nil {
+				return err
+			}
+			stats.Increment("configs_manager", "new_version")
+			log.Infof("config [%v] changed, new version: %v", key, ver.Version)
+		}
+	}
+
+	//without rollout the latest version is the stable one, a new group starts from the latest too
+	stable := map[string]map[string]int64{}
+	for groupName, group := range repo.InstanceGroups {
+		prev, known := m.stable[groupName]
+		stable[groupName] = map[string]int64{}
+		for _, configGroup := range group.ConfigGroups {
+			for _, file := range repo.ConfigGroups[configGroup].Files {
+				key := fileKey(configGroup, file)
+				ver, ok := m.versions[key]
+				if !ok {
+					continue
+				}
+				if !known || !group.Rollout.IsActive() {
+					stable[groupName][key] = ver.Version
+				} else if v, ok := prev[key]; ok {
+					stable[groupName][key] = v
+				}
+			}
+		}
+	}
+
+	m.repo = repo
+	m.contents = contents
+	m.stable = stable
+	return m.persist()
+}
+
+func (m *Manager) readRepoFile(file string) (string, error) {
+	name := path.Join(m.repoPath, file)
+	repoPath, _ := filepath.Abs(m.repoPath)
+	absName, _ := filepath.Abs(name)
+	if !util.IsFileWithinFolder(absName, repoPath) {
+		return "", errors.Errorf("file [%v] is outside of the repo", file)
+	}
+	b, err := util.FileGetContent(name)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func matchInstance(patterns []string, instance *model.Instance) bool {
+	for _, p := range patterns {
+		if p == instance.ID || p == instance.Name {
+			return true
+		}
+		if ok, _ := path.Match(p, instance.ID); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, instance.Name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// inRollout tells whether the instance receives the latest versions of the instance group
+func inRollout(groupName string, rollout *common.RolloutConfig, instance *model.Instance) bool {
+	if !rollout.IsActive() {
+		return true
+	}
+	if matchInstance(rollout.Canary, instance) {
+		return true
+	}
+	if rollout.Percentage <= 0 {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(groupName + "/" + instance.ID))
+	return int(h.Sum32()%100) < rollout.Percentage
+}
+
+// instanceGroups returns the names of groups the instance belongs to, sorted for a stable precedence
+func (m *Manager) instanceGroups(instance *model.Instance) []string {
+	groups := []string{}
+	for name, group := range m.repo.InstanceGroups {
+		if matchInstance(group.Instances, instance) {
+			groups = append(groups, name)
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// desiredConfigs returns the configs the instance should have, keyed by file name
+func (m *Manager) desiredConfigs(instance *model.Instance) (map[string]common.ConfigFile, error) {
+	configs := map[string]common.ConfigFile{}
+	for _, groupName := range m.instanceGroups(instance) {
+		group := m.repo.InstanceGroups[groupName]
+		latest := inRollout(groupName, group.Rollout, instance)
+		for _, configGroup := range group.ConfigGroups {
+			for _, file := range m.repo.ConfigGroups[configGroup].Files {
+				key := fileKey(configGroup, file)
+				ver, ok := m.versions[key]
+				if !ok {
+					continue
+				}
+
+				name := filepath.Base(file)
+				if _, ok := configs[name]; ok {
+					log.Warnf("config [%v] was already delivered by other groups, skip [%v] of instance group [%v]", name, key, groupName)
+					continue
+				}
+
+				version := ver.Version
+				content := m.contents[key]
+				if !latest {
+					version = m.stable[groupName][key]
+					if version <= 0 {
+						//new file during rollout, only canary instances get it
+						continue
+					}
+					if version != ver.Version {
+						b, err := kv.GetCompressedValue(snapshotBucket, []byte(fmt.Sprintf("%v:%v", key, version)))
+						if err != nil {
+							return nil, err
+						}
+						content = string(b)
+					}
+				}
+
+				configs[name] = common.ConfigFile{
+					Name:    name,
+					Content: content,
+					Version: version,
+					Managed: true,
+					Hash:    util.MD5digest(content),
+				}
+			}
+		}
+	}
+	return configs, nil
+}
+
+func (m *Manager) desiredSecrets(instance *model.Instance) *common.Secrets {
+	secrets := &common.Secrets{Keystore: map[string]common.KeystoreValue{}}
+	for _, groupName := range m.instanceGroups(instance) {
+		for _, name := range m.repo.InstanceGroups[groupName].Secrets {
+			group, ok := m.repo.SecretGroups[name]
+			if !ok {
+				log.Warnf("secret group [%v] of instance group [%v] not found", name, groupName)
+				continue
+			}
+			for k, v := range group.Keystore {
+				if v.Type == "" {
+					v.Type = common.SecretTypePlaintext
+				}
+				secrets.Keystore[k] = v
+			}
+		}
+	}
+	if len(secrets.Keystore) == 0 {
+		return nil
+	}
+	return secrets
+}
+
+// Register saves the instance, binds it to the registering user and pins its public key,
+// an instance can't be taken over by registering with another user or key
+func (m *Manager) Register(req *common.InstanceRegisterRequest, owner string) (bool, error) {
+	instance := &req.Instance
+	if instance.ID == "" {
+		return false, errors.New("instance id is required")
+	}
+	if req.PublicKey == "" {
+		return false, errors.New("public key is required")
+	}
+	if owner == "" {
+		return false, errors.New("owner is required")
+	}
+
+	if err := pinPublicKey(instance.ID, req.PublicKey); err != nil {
+		return false, err
+	}
+	if err := bindOwner(instance.ID, owner); err != nil {
+		return false, err
+	}
+
+	exists, err := kv.ExistsKey(instanceBucket, []byte(instance.ID))
+	if err != nil {
+		return false, err
+	}
+	if err := kv.AddValue(instanceBucket, []byte(instance.ID), util.MustToJSONBytes(instance)); err != nil {
+		return false, err
+	}
+	return !exists, nil
+}
+
+// bindOwner binds the instance to the first user registered it, instances registered by
+// older versions were not bound, they are bound to the user registers them again
+func bindOwner(instanceID, owner string) error {
+	bound, err := kv.PutIfAbsent(instanceOwnerBucket, []byte(instanceID), []byte(owner), 0)
+	if err != nil || bound {
+		return err
+	}
+	return checkOwner(instanceID, owner)
+}
+
+func checkOwner(instanceID, owner string) error {
+	v, err := kv.GetValue(instanceOwnerBucket, []byte(instanceID))
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return errInstanceNotRegistered
+	}
+	if string(v) != owner {
+		log.Warnf("user [%v] tried to access instance [%v] registered by another user", owner, instanceID)
+		return errInstanceOwnerMismatch
+	}
+	return nil
+}
+
+// pinPublicKey pins the first public key of instance, secrets are only encrypted for the pinned one
+func pinPublicKey(instanceID, publicKey string) error {
+	pinned, err := kv.PutIfAbsent(instanceKeyBucket, []byte(instanceID), []byte(publicKey), 0)
+	if err != nil || pinned {
+		return err
+	}
+	return checkPublicKey(instanceID, publicKey)
+}
+
+func checkPublicKey(instanceID, publicKey string) error {
+	v, err := kv.GetValue(instanceKeyBucket, []byte(instanceID))
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		return errInstanceNotRegistered
+	}
+	if string(v) != publicKey {
+		log.Warnf("instance [%v] provided a public key other than the pinned one", instanceID)
+		return errPublicKeyMismatch
+	}
+	return nil
+}
+
+// getRegisteredInstance returns the instance saved on registration after checking it was registered by the owner,
+// the groups are matched against the saved one, as the instance info in sync requests is self-reported
+func getRegisteredInstance(instanceID, owner string) (*model.Instance, error) {
+	if err := checkOwner(instanceID, owner); err != nil {
+		return nil, err
+	}
+	v, err := kv.GetValue(instanceBucket, []byte(instanceID))
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, errInstanceNotRegistered
+	}
+	instance := &model.Instance{}
+	if err := util.FromJSONBytes(v, instance); err != nil {
+		return nil, err
+	}
+	return instance, nil
+}
+
+// Sync compares the configs reported by the instance with the desired ones by version,
+// only the instances registered by the owner are able to sync
+func (m *Manager) Sync(req *common.ConfigSyncRequest, owner string) (*common.ConfigSyncResponse, error) {
+	if req.Client.ID == "" {
+		return nil, errors.New("instance id is required")
+	}
+	instance, err := getRegisteredInstance(req.Client.ID, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	m.lock.RLock()
+	desired, err := m.desiredConfigs(instance)
+	secrets := m.desiredSecrets(instance)
+	m.lock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &common.ConfigSyncResponse{}
+	resp.Configs.CreatedConfigs = map[string]common.ConfigFile{}
+	resp.Configs.UpdatedConfigs = map[string]common.ConfigFile{}
+	resp.Configs.DeletedConfigs = map[string]common.ConfigFile{}
+
+	for name, cfg := range desired {
+		current, ok := req.Configs.Configs[name]
+		if !ok {
+			resp.Configs.CreatedConfigs[name] = cfg
+			continue
+		}
+		if !current.Managed {
+			log.Warnf("config [%v] of instance [%v] is not managed, skip updating", name, instance.ID)
+			continue
+		}
+		if current.Version != cfg.Version || req.ForceSync {
+			resp.Configs.UpdatedConfigs[name] = cfg
+		}
+	}
+	for name, current := range req.Configs.Configs {
+		//only remove the files delivered by manager
+		if _, ok := desired[name]; !ok && current.Managed && current.Version > 0 {
+			resp.Configs.DeletedConfigs[name] = current
+		}
+	}
+
+	state := instanceSyncState{}
+	if v, err := kv.GetValue(instanceSyncBucket, []byte(instance.ID)); err == nil && v != nil {
+		util.FromJSONBytes(v, &state)
+	}
+
+	resp.Changed = len(resp.Configs.CreatedConfigs)+len(resp.Configs.UpdatedConfigs)+len(resp.Configs.DeletedConfigs) > 0
+	if secrets != nil {
+		secretsHash := util.MD5digestString(util.MustToJSONBytes(secrets))
+		if resp.Changed || req.ForceSync || secretsHash != state.SecretsHash {
+			if req.PublicKey == "" {
+				//never ship secrets in plaintext
+				log.Warnf("instance [%v] didn't provide public key, skip delivering secrets", instance.ID)
+			} else {
+				if err := checkPublicKey(instance.ID, req.PublicKey); err != nil {
+					return nil, err
+				}
+				encrypted, err := common.EncryptSecrets(secrets, []byte(req.PublicKey))
+				if err != nil {
+					return nil, err
+				}
+				resp.Secrets = encrypted
+				resp.Changed = true
+				state.SecretsHash = secretsHash
+			}
+		}
+	}
+
+	state.Hash = req.Hash
+	state.Timestamp = time.Now().Unix()
+	if err := kv.AddValue(instanceSyncBucket, []byte(instance.ID), util.MustToJSONBytes(state)); err != nil {
+		return nil, err
+	}
+
+	if resp.Changed {
+		stats.Increment("configs_manager", "sync_changed")
+		log.Debugf("configs of instance [%v] changed, created: %v, updated: %v, deleted: %v", instance.ID,
+			len(resp.Configs.CreatedConfigs), len(resp.Configs.UpdatedConfigs), len(resp.Configs.DeletedConfigs))
+	}
+	stats.Increment("configs_manager", "sync")
+	return resp, nil
+}
+
+// Promote marks the latest versions as stable, all instances of the group will get them
+func (m *Manager) Promote(instanceGroup string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	group, ok := m.repo.InstanceGroups[instanceGroup]
+	if !ok {
+		return errors.Errorf("instance group [%v] not found", instanceGroup)
+	}
+	stable := map[string]int64{}
+	for _, configGroup := range group.ConfigGroups {
+		for _, file := range m.repo.ConfigGroups[configGroup].Files {
+			key := fileKey(configGroup, file)
+			if ver, ok := m.versions[key]; ok {
+				stable[key] = ver.Version
+			}
+		}
+	}
+	m.stable[instanceGroup] = stable
+	log.Infof("rollout of instance group [%v] was promoted", instanceGroup)
+	return m.persist()
+}
+
+type RolloutStatus struct {
+	Rollout *common.RolloutConfig `json:"rollout,omitempty"`
+	Files   map[string]struct {
+		Latest int64 `json:"latest"`
+		Stable int64 `json:"stable"`
+	} `json:"files"`
+}
+
+func (m *Manager) GetRolloutStatus() map[string]RolloutStatus {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	output := map[string]RolloutStatus{}
+	for groupName, group := range m.repo.InstanceGroups {
+		status := RolloutStatus{Rollout: group.Rollout}
+		status.Files = map[string]struct {
+			Latest int64 `json:"latest"`
+			Stable int64 `json:"stable"`
+		}{}
+		for _, configGroup := range group.ConfigGroups {
+			for _, file := range m.repo.ConfigGroups[configGroup].Files {
+				key := fileKey(configGroup, file)
+				if ver, ok := m.versions[key]; ok {
+					v := status.Files[key]
+					v.Latest = ver.Version
+					v.Stable = m.stable[groupName][key]
+					status.Files[key] = v
+				}
+			}
+		}
+		output[groupName] = status
+	}
+	return output
+}
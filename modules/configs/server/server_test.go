@@ -0,0 +1,287 @@
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/rubyniu105/framework/core/kv"
+	"github.com/rubyniu105/framework/core/model"
+	"github.com/rubyniu105/framework/core/util"
+	"github.com/rubyniu105/framework/modules/configs/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	kv.Register("memory", kv.NewMemoryStore())
+}
+
+const testRepo = `
+configs:
+  base:
+    files:
+      - base/input.yml
+      - base/output.yml
+instances:
+  gateway:
+    configs: ["base"]
+    instances: ["gw-*"]
+    secrets: ["es"]
+secrets:
+  es:
+    keystore:
+      es_password:
+        value: "123456"
+`
+
+func writeFile(t *testing.T, dir, name, content string) {
+	file := path.Join(dir, name)
+	assert.Nil(t, os.MkdirAll(path.Dir(file), 0755))
+	assert.Nil(t, os.WriteFile(file, []byte(content), 0644))
+}
+
+func newTestManager(t *testing.T, repo string) (*Manager, string) {
+	dir := t.TempDir()
+	writeFile(t, dir, common.REPO_CONFIG_FILE, repo)
+	writeFile(t, dir, "base/input.yml", "input: v1")
+	writeFile(t, dir, "base/output.yml", "output: v1")
+	m := NewManager(dir)
+	assert.Nil(t, m.Load())
+	return m, dir
+}
+
+const testOwner = "agent"
+
+// register registers the instance with a new key pair, returns the public key
+func register(t *testing.T, m *Manager, id string) string {
+	_, publicKey, err := common.GenerateKeyPair()
+	assert.Nil(t, err)
+	req := &common.InstanceRegisterRequest{PublicKey: string(publicKey)}
+	req.ID = id
+	_, err = m.Register(req, testOwner)
+	assert.Nil(t, err)
+	return string(publicKey)
+}
+
+func newSyncRequest(id string, configs map[string]common.ConfigFile) *common.ConfigSyncRequest {
+	req := &common.ConfigSyncRequest{}
+	req.Client = model.Instance{}
+	req.Client.ID = id
+	req.Configs.Configs = configs
+	return req
+}
+
+func TestSync(t *testing.T) {
+	m, dir := newTestManager(t, testRepo)
+
+	privateKey, publicKey, err := common.GenerateKeyPair()
+	assert.Nil(t, err)
+	req := &common.InstanceRegisterRequest{PublicKey: string(publicKey)}
+	req.ID = "gw-sync"
+	_, err = m.Register(req, testOwner)
+	assert.Nil(t, err)
+
+	sync := newSyncRequest("gw-sync", nil)
+	sync.PublicKey = string(publicKey)
+	resp, err := m.Sync(sync, testOwner)
+	assert.Nil(t, err)
+	assert.True(t, resp.Changed)
+	assert.Equal(t, 2, len(resp.Configs.CreatedConfigs))
+	assert.Equal(t, int64(1), resp.Configs.CreatedConfigs["input.yml"].Version)
+	assert.True(t, resp.Configs.CreatedConfigs["input.yml"].Managed)
+	assert.Equal(t, common.SecretTypeEncrypted, resp.Secrets.Keystore["es_password"].Type)
+	assert.NotEqual(t, "123456", resp.Secrets.Keystore["es_password"].Value)
+	secrets, err := common.DecryptSecrets(resp.Secrets, privateKey)
+	assert.Nil(t, err)
+	assert.Equal(t, "123456", string(secrets["es_password"]))
+
+	//secrets are not delivered without public key
+	register(t, m, "gw-plain")
+	plain, err := m.Sync(newSyncRequest("gw-plain", nil), testOwner)
+	assert.Nil(t, err)
+	assert.True(t, plain.Changed)
+	assert.Nil(t, plain.Secrets)
+
+	//up to date
+	configs := resp.Configs.CreatedConfigs
+	configs["local.yml"] = common.ConfigFile{Name: "local.yml"}
+	resp, err = m.Sync(newSyncRequest("gw-sync", configs), testOwner)
+	assert.Nil(t, err)
+	assert.False(t, resp.Changed)
+	assert.Nil(t, resp.Secrets)
+
+	//new version
+	writeFile(t, dir, "base/input.yml", "input: v2")
+	assert.Nil(t, m.Load())
+	resp, err = m.Sync(newSyncRequest("gw-sync", configs), testOwner)
+	assert.Nil(t, err)
+	assert.True(t, resp.Changed)
+	assert.Equal(t, 1, len(resp.Configs.UpdatedConfigs))
+	assert.Equal(t, int64(2), resp.Configs.UpdatedConfigs["input.yml"].Version)
+	assert.Equal(t, "input: v2", resp.Configs.UpdatedConfigs["input.yml"].Content)
+
+	//removed from repo, unmanaged files are kept
+	writeFile(t, dir, common.REPO_CONFIG_FILE, `
+configs:
+  base:
+    files: ["base/input.yml"]
+instances:
+  gateway:
+    configs: ["base"]
+    instances: ["gw-*"]
+`)
+	assert.Nil(t, m.Load())
+	resp, err = m.Sync(newSyncRequest("gw-sync", configs), testOwner)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(resp.Configs.DeletedConfigs))
+	_, ok := resp.Configs.DeletedConfigs["output.yml"]
+	assert.True(t, ok)
+
+	//not in any instance group
+	register(t, m, "other")
+	resp, err = m.Sync(newSyncRequest("other", nil), testOwner)
+	assert.Nil(t, err)
+	assert.False(t, resp.Changed)
+}
+
+func TestRollout(t *testing.T) {
+	m, dir := newTestManager(t, `
+configs:
+  base:
+    files: ["base/input.yml"]
+instances:
+  gateway:
+    configs: ["base"]
+    instances: ["rl-*"]
+    rollout:
+      canary: ["rl-canary"]
+      percentage: 0
+`)
+
+	register(t, m, "rl-1")
+	register(t, m, "rl-canary")
+
+	//the first version is stable
+	resp, err := m.Sync(newSyncRequest("rl-1", nil), testOwner)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), resp.Configs.CreatedConfigs["input.yml"].Version)
+
+	writeFile(t, dir, "base/input.yml", "input: v2")
+	assert.Nil(t, m.Load())
+
+	resp, err = m.Sync(newSyncRequest("rl-1", nil), testOwner)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), resp.Configs.CreatedConfigs["input.yml"].Version)
+	assert.Equal(t, "input: v1", resp.Configs.CreatedConfigs["input.yml"].Content)
+
+	resp, err = m.Sync(newSyncRequest("rl-canary", nil), testOwner)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), resp.Configs.CreatedConfigs["input.yml"].Version)
+
+	status := m.GetRolloutStatus()
+	assert.Equal(t, int64(2), status["gateway"].Files["base/base/input.yml"].Latest)
+	assert.Equal(t, int64(1), status["gateway"].Files["base/base/input.yml"].Stable)
+
+	assert.Nil(t, m.Promote("gateway"))
+	resp, err = m.Sync(newSyncRequest("rl-1", nil), testOwner)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), resp.Configs.CreatedConfigs["input.yml"].Version)
+	assert.NotNil(t, m.Promote("not_found"))
+}
+
+func TestAnonymousInstanceRejected(t *testing.T) {
+	m, _ := newTestManager(t, testRepo)
+	manager = m
+	defer func() { manager = nil }()
+
+	h := APIHandler{}
+	body := util.MustToJSONBytes(model.Instance{ID: "gw-anonymous"})
+	w := httptest.NewRecorder()
+	h.registerInstance(w, httptest.NewRequest(http.MethodPost, common.REGISTER_API, bytes.NewReader(body)), nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	exists, _ := kv.ExistsKey(instanceBucket, []byte("gw-anonymous"))
+	assert.False(t, exists)
+
+	w = httptest.NewRecorder()
+	h.syncConfigs(w, httptest.NewRequest(http.MethodPost, common.SYNC_API, bytes.NewReader(util.MustToJSONBytes(newSyncRequest("gw-anonymous", nil)))), nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestPinPublicKey(t *testing.T) {
+	m, _ := newTestManager(t, testRepo)
+
+	_, publicKey, err := common.GenerateKeyPair()
+	assert.Nil(t, err)
+	_, otherKey, err := common.GenerateKeyPair()
+	assert.Nil(t, err)
+
+	req := &common.InstanceRegisterRequest{PublicKey: string(publicKey)}
+	req.ID = "gw-pinned"
+	created, err := m.Register(req, testOwner)
+	assert.Nil(t, err)
+	assert.True(t, created)
+	created, err = m.Register(req, testOwner)
+	assert.Nil(t, err)
+	assert.False(t, created)
+
+	//someone else can't take over the instance or receive its secrets
+	req.PublicKey = string(otherKey)
+	_, err = m.Register(req, testOwner)
+	assert.Equal(t, errPublicKeyMismatch, err)
+
+	sync := newSyncRequest("gw-pinned", nil)
+	sync.PublicKey = string(otherKey)
+	_, err = m.Sync(sync, testOwner)
+	assert.Equal(t, errPublicKeyMismatch, err)
+
+	sync.PublicKey = string(publicKey)
+	resp, err := m.Sync(sync, testOwner)
+	assert.Nil(t, err)
+	assert.NotNil(t, resp.Secrets)
+}
+
+func TestSyncRegisteredInstance(t *testing.T) {
+	m, _ := newTestManager(t, testRepo)
+
+	_, err := m.Sync(newSyncRequest("gw-unregistered", nil), testOwner)
+	assert.Equal(t, errInstanceNotRegistered, err)
+
+	publicKey := register(t, m, "web-owned")
+
+	//the same instance can't be registered or synced by another user
+	req := &common.InstanceRegisterRequest{PublicKey: publicKey}
+	req.ID = "web-owned"
+	_, err = m.Register(req, "other")
+	assert.Equal(t, errInstanceOwnerMismatch, err)
+	_, err = m.Sync(newSyncRequest("web-owned", nil), "other")
+	assert.Equal(t, errInstanceOwnerMismatch, err)
+
+	//groups are matched against the registered instance, not the reported name
+	sync := newSyncRequest("web-owned", nil)
+	sync.Client.Name = "gw-spoofed"
+	sync.PublicKey = publicKey
+	resp, err := m.Sync(sync, testOwner)
+	assert.Nil(t, err)
+	assert.False(t, resp.Changed)
+	assert.Nil(t, resp.Secrets)
+}
+
+func TestReservedSecret(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, common.REPO_CONFIG_FILE, `
+secrets:
+  es:
+    keystore:
+      configs_instance_private_key:
+        value: "overwritten"
+`)
+	assert.NotNil(t, NewManager(dir).Load())
+}
@@ -158,9 +158,12 @@ func (c *ESAPIV0) Request(ctx context.Context, method, url string, body []byte)
 	req.Context = ctx
 
 	req.SetContentType(util.ContentTypeJson)
-	if c.GetMetadata().Config.BasicAuth != nil {
+	if auth := c.GetMetadata().Config.GetAuthorizationHeader(); auth != "" {
+		req.AddHeader("Authorization", auth)
+	} else if c.GetMetadata().Config.BasicAuth != nil {
 		req.SetBasicAuth(c.GetMetadata().Config.BasicAuth.Username, c.GetMetadata().Config.BasicAuth.Password.Get())
 	}
+	httpClient := c.GetMetadata().GetNetHttpClient()
 
 	if c.GetMetadata().Config.HttpProxy != "" {
 		req.SetProxy(c.GetMetadata().Config.HttpProxy)
@@ -180,7 +183,7 @@ func (c *ESAPIV0) Request(ctx context.Context, method, url string, body []byte)
 				log.Errorf("error in request, sleep 1s and retry [%v]: %s\n", count, err)
 				time.Sleep(1 * time.Second)
 				var err1 error
-				resp, err1 = util.ExecuteRequest(req)
+				resp, err1 = util.ExecuteRequestWithCatchFlag(httpClient, req, true)
 				if err1 != nil {
 					log.Errorf("retry still have error in request, sleep 10s and retry [%v]: %s\n", count, err)
 					goto RETRY
@@ -190,7 +193,7 @@ func (c *ESAPIV0) Request(ctx context.Context, method, url string, body []byte)
 		}(req)
 	}
 
-	resp, err := util.ExecuteRequest(req)
+	resp, err := util.ExecuteRequestWithCatchFlag(httpClient, req, true)
 	if err != nil {
 		return resp, err
 	}
@@ -321,6 +324,68 @@ func (c *ESAPIV0) Index(indexName, docType string, id interface{}, data interfac
 	return esResp, nil
 }
 
+func (c *ESAPIV0) IndexWithOptions(indexName, docType string, id interface{}, data interface{}, options *elastic.IndexOptions) (*elastic.InsertResponse, error) {
+
+	if docType == "" {
+		docType = TypeName0
+		if c.GetMajorVersion() >= 7 {
+			docType = TypeName7
+		}
+	}
+	if options == nil {
+		options = &elastic.IndexOptions{}
+	}
+
+	indexName = util.UrlEncode(indexName)
+
+	args := url.Values{}
+	if options.OpType != "" {
+		args.Set("op_type", options.OpType)
+	}
+	if options.IfPrimaryTerm > 0 {
+		args.Set("if_seq_no", strconv.FormatInt(options.IfSeqNo, 10))
+		args.Set("if_primary_term", strconv.FormatInt(options.IfPrimaryTerm, 10))
+	} else if options.Version > 0 {
+		args.Set("version", strconv.FormatInt(options.Version, 10))
+		args.Set("version_type", "external")
+	}
+	if options.Refresh != "" {
+		args.Set("refresh", options.Refresh)
+	}
+
+	url := fmt.Sprintf("%s/%s/%s/%s", c.GetEndpoint(), indexName, docType, id)
+	if len(args) > 0 {
+		url = url + "?" + args.Encode()
+	}
+
+	js, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Request(nil, util.Verb_PUT, url, js)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusConflict {
+		return nil, elastic.ErrVersionConflict
+	}
+
+	esResp := &elastic.InsertResponse{}
+	esResp.StatusCode = resp.StatusCode
+	esResp.RawResult = resp
+	err = json.Unmarshal(resp.Body, esResp)
+	if err != nil {
+		return esResp, err
+	}
+	if !(esResp.Result == "created" || esResp.Result == "updated") {
+		return nil, errors.New(string(resp.Body))
+	}
+
+	return esResp, nil
+}
+
 func (c *ESAPIV0) Update(indexName, docType string, id interface{}, data interface{}, refresh string) (*elastic.InsertResponse, error) {
 
 	if docType == "" {
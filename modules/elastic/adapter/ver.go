@@ -81,14 +81,16 @@ func ClusterVersion(metadata *elastic.ElasticsearchMetadata) (*elastic.ClusterIn
 	}
 
 	req := util.Request{Method: fasthttp.MethodGet, Url: url}
-	if metadata.Config.BasicAuth != nil {
+	if auth := metadata.Config.GetAuthorizationHeader(); auth != "" {
+		req.AddHeader(fasthttp.HeaderAuthorization, auth)
+	} else if metadata.Config.BasicAuth != nil {
 		req.SetBasicAuth(metadata.Config.BasicAuth.Username, metadata.Config.BasicAuth.Password.Get())
 	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(metadata.Config.RequestTimeout)*time.Second)
 	req.Context = ctx
 	defer cancel()
 
-	res, err := util.ExecuteRequestWithCatchFlag(nil, &req, true)
+	res, err := util.ExecuteRequestWithCatchFlag(metadata.GetNetHttpClient(), &req, true)
 	if err != nil {
 		return nil, err
 	}
@@ -137,8 +139,12 @@ func RequestTimeout(ctx *elastic.APIContext, method, url string, body []byte, me
 		compressed = true
 	}
 
-	if metadata.Config != nil && metadata.Config.BasicAuth != nil {
-		ctx.Request.SetBasicAuth(metadata.Config.BasicAuth.Username, metadata.Config.BasicAuth.Password.Get())
+	if metadata.Config != nil {
+		if auth := metadata.Config.GetAuthorizationHeader(); auth != "" {
+			ctx.Request.Header.Set(fasthttp.HeaderAuthorization, auth)
+		} else if metadata.Config.BasicAuth != nil {
+			ctx.Request.SetBasicAuth(metadata.Config.BasicAuth.Username, metadata.Config.BasicAuth.Password.Get())
+		}
 	}
 
 	metadata.CheckNodeTrafficThrottle(util.UnsafeBytesToString(ctx.Request.Header.Host()), 1, ctx.Request.GetRequestLength(), 0)
@@ -28,16 +28,21 @@
 package elastic
 
 import (
+	"fmt"
 	"github.com/rubyniu105/framework/core/api"
 	httprouter "github.com/rubyniu105/framework/core/api/router"
+	"github.com/rubyniu105/framework/core/credential"
 	"github.com/rubyniu105/framework/core/elastic"
+	"github.com/rubyniu105/framework/core/orm"
 	"github.com/rubyniu105/framework/core/util"
 	"net/http"
 )
 
 func init() {
-	api.HandleAPIMethod(api.GET, "/elasticsearch/metadata", GetMetadata)
-	api.HandleAPIMethod(api.GET, "/elasticsearch/hosts", GetHosts)
+	api.HandleAPIMethod(api.GET, "/elasticsearch/metadata", GetMetadata, api.RequirePermission("elasticsearch:read"))
+	api.HandleAPIMethod(api.GET, "/elasticsearch/hosts", GetHosts, api.RequirePermission("elasticsearch:read"))
+	api.HandleAPIMethod(api.POST, "/credential/:id/_rotate", RotateCredential, api.RequirePermission("credential:write"))
+	api.HandleAPIMethod(api.POST, "/credential/_rotate_secret", RotateCredentialSecret, api.RequirePermission("credential:write"))
 }
 
 func GetMetadata(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
@@ -63,7 +68,7 @@ func GetMetadata(w http.ResponseWriter, req *http.Request, ps httprouter.Params)
 			//m["primary_shards"]=v.PrimaryShards
 			m["available"] = v.IsAvailable()
 			m["schema"] = v.GetSchema()
-			m["config"] = v.Config
+			m["config"] = v.Config.GetSanitizedConfig()
 			m["last_success"] = v.LastSuccess()
 			result[k] = m
 		}
@@ -100,3 +105,29 @@ func GetHosts(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	api.DefaultAPI.WriteJSON(w, result, http.StatusOK)
 
 }
+
+func RotateCredential(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	cred := credential.Credential{}
+	cred.ID = ps.MustGetParameter("id")
+	exists, err := orm.Get(&cred)
+	if !exists || err != nil {
+		api.DefaultAPI.WriteError(w, fmt.Sprintf("credential [%v] not found", cred.ID), http.StatusNotFound)
+		return
+	}
+	if err := credential.Rotate(&cred); err != nil {
+		api.DefaultAPI.WriteError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.DefaultAPI.WriteAckOKJSON(w)
+}
+
+// RotateCredentialSecret rotates the master secret in local keystore, refused when other nodes share the credentials,
+// pass `force=true` only if the new secret will be copied to the other nodes
+func RotateCredentialSecret(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	force := api.DefaultAPI.GetParameterOrDefault(req, "force", "false") == "true"
+	if err := credential.RotateSecret(force); err != nil {
+		api.DefaultAPI.WriteError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	api.DefaultAPI.WriteAckOKJSON(w)
+}
@@ -29,4 +29,9 @@ package elastic
 
 type Blob struct {
 	Content string `json:"content,omitempty" elastic_mapping:"content: { type: binary, doc_values:false }"`
+
+	//used by prefix scan and ttl, blobs written by older versions don't have them
+	Bucket   string `json:"bucket,omitempty" elastic_mapping:"bucket: { type: keyword }"`
+	Key      string `json:"key,omitempty" elastic_mapping:"key: { type: keyword }"`
+	ExpireAt int64  `json:"expire_at,omitempty" elastic_mapping:"expire_at: { type: long }"` //unix millis
 }
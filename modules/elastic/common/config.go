@@ -33,6 +33,7 @@ import (
 	elastic "github.com/rubyniu105/framework/core/elastic"
 	"github.com/rubyniu105/framework/core/orm"
 	"github.com/rubyniu105/framework/core/util"
+	"github.com/rubyniu105/framework/lib/go-ucfg"
 	"github.com/rubyniu105/framework/modules/elastic/adapter"
 	"github.com/rubyniu105/framework/modules/elastic/adapter/easysearch"
 	"github.com/rubyniu105/framework/modules/elastic/adapter/elasticsearch"
@@ -73,6 +74,7 @@ type ModuleConfig struct {
 	NodeAvailabilityCheckConfig CheckConfig `config:"availability_check"`
 	MetadataRefresh             CheckConfig `config:"metadata_refresh"`
 	ClusterSettingsCheckConfig  CheckConfig `config:"cluster_settings_check"`
+	CredentialRotation          CheckConfig `config:"credential_rotation"` //check the credentials to rotate, require orm enabled
 	ClientTimeout               string      `config:"client_timeout"`
 	SkipInitMetadataOnStart     bool        `config:"skip_init_metadata_on_start"`
 }
@@ -82,6 +84,11 @@ func InitClientWithConfig(esConfig elastic.ElasticsearchConfig) (client elastic.
 	var (
 		ver string
 	)
+
+	if err := ResolveCredential(&esConfig); err != nil {
+		return nil, err
+	}
+
 	if esConfig.Version == "" || esConfig.Version == "auto" {
 		verInfo, err := adapter.ClusterVersion(elastic.GetOrInitMetadata(&esConfig))
 		if err != nil {
@@ -195,6 +202,10 @@ func InitElasticInstanceWithoutMetadata(esConfig elastic.ElasticsearchConfig) (e
 		log.Warn("elasticsearch ", esConfig.Name, " is not enabled")
 		return nil, nil
 	}
+	if err := ResolveCredential(&esConfig); err != nil {
+		log.Error("elasticsearch ", esConfig.Name, err)
+		return nil, err
+	}
 	client, err := InitClientWithConfig(esConfig)
 	if err != nil {
 		log.Error("elasticsearch ", esConfig.Name, err)
@@ -210,6 +221,10 @@ func InitElasticInstance(esConfig elastic.ElasticsearchConfig) (elastic.API, err
 		log.Warn("elasticsearch ", esConfig.Name, " is not enabled")
 		return nil, nil
 	}
+	if err := ResolveCredential(&esConfig); err != nil {
+		log.Error("elasticsearch ", esConfig.Name, err)
+		return nil, err
+	}
 	client, err := InitClientWithConfig(esConfig)
 	if err != nil {
 		log.Error("elasticsearch ", esConfig.Name, err)
@@ -231,6 +246,40 @@ func InitElasticInstance(esConfig elastic.ElasticsearchConfig) (elastic.API, err
 	return client, err
 }
 
+// ResolveCredential loads the credential of cluster, skipped if the auth was configured directly
+func ResolveCredential(esConfig *elastic.ElasticsearchConfig) error {
+	if esConfig.CredentialID == "" || esConfig.HasTokenAuth() || esConfig.ClientCert != nil ||
+		(esConfig.BasicAuth != nil && esConfig.BasicAuth.Username != "") {
+		return nil
+	}
+	cred, err := GetCredential(esConfig.CredentialID)
+	if err != nil {
+		return err
+	}
+	return ApplyCredential(esConfig, cred)
+}
+
+// ApplyCredential sets the auth of cluster by the type of credential
+func ApplyCredential(esConfig *elastic.ElasticsearchConfig, cred *credential.Credential) error {
+	obj, err := cred.Decode()
+	if err != nil {
+		return err
+	}
+	switch v := obj.(type) {
+	case model.BasicAuth:
+		esConfig.BasicAuth = &v
+	case model.APIKey:
+		esConfig.APIKey = &v
+	case ucfg.SecretString:
+		esConfig.BearerToken = v
+	case model.ClientCert:
+		esConfig.ClientCert = &v
+	default:
+		return fmt.Errorf("unsupported credential type [%s]", cred.Type)
+	}
+	return nil
+}
+
 func GetBasicAuth(esConfig *elastic.ElasticsearchConfig) (basicAuth *model.BasicAuth, err error) {
 	if esConfig.BasicAuth != nil && esConfig.BasicAuth.Username != "" {
 		basicAuth = esConfig.BasicAuth
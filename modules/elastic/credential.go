@@ -0,0 +1,287 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package elastic
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/rubyniu105/framework/core/credential"
+	"github.com/rubyniu105/framework/core/elastic"
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/global"
+	"github.com/rubyniu105/framework/core/locker"
+	"github.com/rubyniu105/framework/core/orm"
+	"github.com/rubyniu105/framework/core/util"
+	"github.com/rubyniu105/framework/modules/elastic/common"
+)
+
+const credentialRotationLock = "credential_rotation"
+
+// how long the old api key stays valid after rotation, so that other nodes have time to pick up the new one
+const retiredAPIKeyGracePeriod = 10 * time.Minute
+
+func init() {
+	credential.RegisterChangeEvent(reloadClientsByCredential)
+	credential.RegisterRotator(credential.BasicAuth, rotateBasicAuth, rollbackBasicAuth)
+	credential.RegisterRotator(credential.APIKey, rotateAPIKey, rollbackAPIKey)
+}
+
+// reloadClientsByCredential re-initializes the clients of clusters using the changed credential
+func reloadClientsByCredential(cred *credential.Credential) {
+	elastic.WalkConfigs(func(key, value interface{}) bool {
+		cfg, ok := value.(*elastic.ElasticsearchConfig)
+		if !ok || cfg.CredentialID != cred.ID {
+			return true
+		}
+
+		newCfg := *cfg
+		newCfg.BasicAuth = nil
+		newCfg.APIKey = nil
+		newCfg.BearerToken = ""
+		newCfg.ClientCert = nil
+		if err := common.ApplyCredential(&newCfg, cred); err != nil {
+			log.Errorf("failed to apply credential [%v] to cluster [%v]: %v", cred.Name, cfg.Name, err)
+			return true
+		}
+
+		//reuse the detected version, the old auth may not work anymore
+		if old := elastic.GetClientNoPanic(cfg.ID); old != nil && newCfg.Version == "" {
+			ver := old.GetVersion()
+			newCfg.Version = ver.Number
+			newCfg.Distribution = ver.Distribution
+		}
+		client, err := common.InitClientWithConfig(newCfg)
+		if err != nil {
+			log.Errorf("failed to init client of cluster [%v]: %v", cfg.Name, err)
+			return true
+		}
+		elastic.ReloadInstance(newCfg, client)
+		log.Infof("client of cluster [%v] was reloaded with credential [%v]", cfg.Name, cred.Name)
+		return true
+	})
+}
+
+type rawRequester interface {
+	GetEndpoint() string
+	Request(ctx context.Context, method, url string, body []byte) (*util.Result, error)
+}
+
+// getClientByCredential returns the client of any cluster using the credential
+func getClientByCredential(cred *credential.Credential) (rawRequester, error) {
+	var client rawRequester
+	elastic.WalkConfigs(func(key, value interface{}) bool {
+		cfg, ok := value.(*elastic.ElasticsearchConfig)
+		if !ok || cfg.CredentialID != cred.ID {
+			return true
+		}
+		if c, ok := elastic.GetClientNoPanic(cfg.ID).(rawRequester); ok {
+			client = c
+			return false
+		}
+		return true
+	})
+	if client == nil {
+		return nil, errors.Errorf("credential [%v] is not used by any cluster", cred.Name)
+	}
+	return client, nil
+}
+
+func doSecurityRequest(client rawRequester, method, path string, body interface{}) (util.MapStr, error) {
+	var bodyBytes []byte
+	if body != nil {
+		bodyBytes = util.MustToJSONBytes(body)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	res, err := client.Request(ctx, method, client.GetEndpoint()+path, bodyBytes)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("%v %v, status: %v, %v", method, path, res.StatusCode, string(res.Body))
+	}
+	output := util.MapStr{}
+	if err := util.FromJSONBytes(res.Body, &output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+func rotateBasicAuth(cred *credential.Credential, params map[string]interface{}) error {
+	client, err := getClientByCredential(cred)
+	if err != nil {
+		return err
+	}
+	username, _ := params["username"].(string)
+	if username == "" {
+		return errors.New("username can not be empty")
+	}
+	b, err := util.RandomBytes(24)
+	if err != nil {
+		return err
+	}
+	password := base64.RawURLEncoding.EncodeToString(b)
+	_, err = doSecurityRequest(client, util.Verb_POST, fmt.Sprintf("/_security/user/%s/_password", username), util.MapStr{"password": password})
+	if err != nil {
+		return err
+	}
+	params["password"] = password
+	return nil
+}
+
+// rollbackBasicAuth sets the password back, the client still works with the new one until reloaded
+func rollbackBasicAuth(cred *credential.Credential, oldParams, newParams map[string]interface{}) error {
+	client, err := getClientByCredential(cred)
+	if err != nil {
+		return err
+	}
+	username, _ := oldParams["username"].(string)
+	password, _ := oldParams["password"].(string)
+	if username == "" || password == "" {
+		return errors.New("username and password are required for rollback")
+	}
+	_, err = doSecurityRequest(client, util.Verb_POST, fmt.Sprintf("/_security/user/%s/_password", username), util.MapStr{"password": password})
+	return err
+}
+
+func rotateAPIKey(cred *credential.Credential, params map[string]interface{}) error {
+	client, err := getClientByCredential(cred)
+	if err != nil {
+		return err
+	}
+	oldID, _ := params["id"].(string)
+	if oldID == "" {
+		return errors.New("api key id is required for rotation")
+	}
+
+	//keep the privileges of the old key
+	res, err := doSecurityRequest(client, util.Verb_GET, "/_security/api_key?id="+oldID, nil)
+	if err != nil {
+		return err
+	}
+	req := util.MapStr{"name": fmt.Sprintf("%v-%v", cred.Name, time.Now().Unix())}
+	if keys, ok := res["api_keys"].([]interface{}); ok && len(keys) > 0 {
+		if key, ok := keys[0].(map[string]interface{}); ok && key["role_descriptors"] != nil {
+			req["role_descriptors"] = key["role_descriptors"]
+		}
+	}
+
+	res, err = doSecurityRequest(client, util.Verb_POST, "/_security/api_key", req)
+	if err != nil {
+		return err
+	}
+	id, _ := res["id"].(string)
+	key, _ := res["api_key"].(string)
+	if id == "" || key == "" {
+		return errors.New("invalid api key response")
+	}
+	params["id"] = id
+	params["key"] = key
+	params["retired_id"] = oldID
+	params["retired_at"] = time.Now().Unix()
+	return nil
+}
+
+// rollbackAPIKey invalidates the new api key, the old one was not touched by the rotation
+func rollbackAPIKey(cred *credential.Credential, oldParams, newParams map[string]interface{}) error {
+	client, err := getClientByCredential(cred)
+	if err != nil {
+		return err
+	}
+	id, _ := newParams["id"].(string)
+	if id == "" {
+		return errors.New("api key id is required for rollback")
+	}
+	_, err = doSecurityRequest(client, util.Verb_DELETE, "/_security/api_key", util.MapStr{"ids": []string{id}})
+	return err
+}
+
+// invalidateRetiredAPIKeys invalidates the old api keys after the grace period
+func invalidateRetiredAPIKeys(creds []*credential.Credential) {
+	for _, cred := range creds {
+		if cred.Type != credential.APIKey {
+			continue
+		}
+		params, ok := cred.Payload[cred.Type].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		retiredID, _ := params["retired_id"].(string)
+		retiredAt, _ := util.ExtractInt(params["retired_at"])
+		if retiredID == "" || time.Since(time.Unix(retiredAt, 0)) < retiredAPIKeyGracePeriod {
+			continue
+		}
+		client, err := getClientByCredential(cred)
+		if err != nil {
+			log.Warn(err)
+			continue
+		}
+		_, err = doSecurityRequest(client, util.Verb_DELETE, "/_security/api_key", util.MapStr{"ids": []string{retiredID}})
+		if err != nil {
+			log.Errorf("failed to invalidate retired api key of credential [%v]: %v", cred.Name, err)
+			continue
+		}
+		delete(params, "retired_id")
+		delete(params, "retired_at")
+		if err := orm.Update(nil, cred); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// checkCredentials runs on every node, reloads the clients for the credentials changed by other nodes,
+// the rotation only happens on the node holding the lock
+func checkCredentials() {
+	creds, err := credential.GetCredentials()
+	if err != nil {
+		log.Error("failed to load credentials,", err)
+		return
+	}
+	credential.CheckChanges(creds)
+
+	nodeID := global.Env().SystemConfig.NodeConfig.ID
+	ok, err := locker.Hold(credentialRotationLock, "rotation", nodeID, 5*time.Minute, true)
+	if err != nil || !ok {
+		return
+	}
+	defer locker.Release(credentialRotationLock, "rotation", nodeID)
+
+	count, err := credential.RotateExpired(creds)
+	if err != nil {
+		log.Error(err)
+	}
+	if count > 0 {
+		log.Infof("[%v] credentials rotated", count)
+	}
+	invalidateRetiredAPIKeys(creds)
+}
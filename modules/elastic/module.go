@@ -30,7 +30,6 @@ package elastic
 import (
 	"context"
 	"fmt"
-	"github.com/rubyniu105/framework/core/model"
 	"math"
 	"runtime"
 	"sync"
@@ -83,6 +82,10 @@ var (
 			Enabled:  false,
 			Interval: "20s",
 		},
+		CredentialRotation: common.CheckConfig{
+			Enabled:  false,
+			Interval: "1h",
+		},
 		ClientTimeout: "60s",
 	}
 )
@@ -165,16 +168,9 @@ func loadESBasedElasticConfig() []elastic.ElasticsearchConfig {
 			for i, cfg := range configs {
 				if cfg.CredentialID != "" {
 					if v, ok := credentials[cfg.CredentialID]; ok {
-						if v.Type == credential.BasicAuth {
-							obj, err := v.Decode()
-							if err != nil {
-								log.Error(err)
-								continue
-							}
-							if basicAuth, ok := obj.(model.BasicAuth); ok {
-								configs[i].BasicAuth = &basicAuth
-							}
-
+						if err := common.ApplyCredential(&configs[i], v); err != nil {
+							log.Error(err)
+							continue
 						}
 					}
 				}
@@ -511,6 +507,17 @@ func (module *ElasticModule) Start() error {
 		nodeAvailabilityCheck()
 	}
 
+	if moduleConfig.CredentialRotation.Enabled && moduleConfig.ORMConfig.Enabled {
+		task.RegisterScheduleTask(task.ScheduleTask{
+			Description: "check credentials to rotate",
+			Type:        "interval",
+			Interval:    moduleConfig.CredentialRotation.Interval,
+			Task: func(ctx context.Context) {
+				checkCredentials()
+			},
+		})
+	}
+
 	log.Tracef("metadata refresh enabled:%v", moduleConfig.MetadataRefresh.Enabled)
 
 	if moduleConfig.MetadataRefresh.Enabled {
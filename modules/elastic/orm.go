@@ -191,38 +191,107 @@ func (handler *ElasticORM) Delete(ctx *api.Context, o interface{}) error {
 	return err
 }
 
+// getQueryBody accepts the raw dsl or the structured query, same as the other orm backends
+func getQueryBody(query interface{}) ([]byte, error) {
+	switch v := query.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return v, nil
+	case *api.Query:
+		if v == nil {
+			return nil, nil
+		}
+		if len(v.RawQuery) > 0 {
+			return v.RawQuery, nil
+		}
+		if v.TemplatedQuery != nil {
+			return nil, errors.New("templated query is not supported")
+		}
+		if len(v.Conds) == 0 {
+			return nil, nil
+		}
+		return util.MustToJSONBytes(util.MapStr{"query": buildQuery(v.Conds)}), nil
+	case []*api.Cond:
+		return getQueryBody(&api.Query{Conds: v})
+	}
+	return nil, errors.Errorf("unsupported query type: %T", query)
+}
+
+func buildQuery(conds []*api.Cond) *elastic.Query {
+	boolQuery := elastic.BoolQuery{}
+	for _, c1 := range conds {
+		q := getQuery(c1)
+		switch c1.BoolType {
+		case api.Must:
+			boolQuery.Must = append(boolQuery.Must, q)
+		case api.MustNot:
+			boolQuery.MustNot = append(boolQuery.MustNot, q)
+		case api.Should:
+			boolQuery.Should = append(boolQuery.Should, q)
+		}
+	}
+	return &elastic.Query{BoolQuery: &boolQuery}
+}
+
+// updateByScript merges the doc into the source, keys can be dotted paths
+const updateByScript = "for (e in params.doc.entrySet()) { def parts = e.getKey().splitOnToken('.'); def obj = ctx._source; " +
+	"for (int i = 0; i < parts.length - 1; i++) { if (!(obj[parts[i]] instanceof Map)) { obj[parts[i]] = new HashMap(); } obj = obj[parts[i]]; } " +
+	"obj[parts[parts.length - 1]] = e.getValue(); }"
+
 func (handler *ElasticORM) DeleteBy(o interface{}, query interface{}) error {
-	var (
-		queryBody []byte
-		ok        bool
-	)
-	if queryBody, ok = query.([]byte); !ok {
-		return errors.New("type of param query should be byte array")
+	queryBody, err := getQueryBody(query)
+	if err != nil {
+		return err
+	}
+	if queryBody == nil {
+		return errors.New("query is required")
 	}
-	_, err := handler.Client.DeleteByQuery(handler.GetIndexName(o), queryBody)
+	_, err = handler.Client.DeleteByQuery(handler.GetIndexName(o), queryBody)
 	return err
 }
 
+// UpdateBy accepts the raw update by query body or *orm.UpdateByRequest
 func (handler *ElasticORM) UpdateBy(o interface{}, query interface{}) error {
-	var (
-		queryBody []byte
-		ok        bool
-	)
-	if queryBody, ok = query.([]byte); !ok {
-		return errors.New("type of param query should be byte array")
+	var queryBody []byte
+	switch v := query.(type) {
+	case []byte:
+		queryBody = v
+	case *api.UpdateByRequest:
+		if v == nil {
+			return errors.New("update request is required")
+		}
+		body := util.MapStr{
+			"script": util.MapStr{
+				"source": updateByScript,
+				"lang":   "painless",
+				"params": util.MapStr{"doc": v.Doc},
+			},
+		}
+		if v.Query != nil && len(v.Query.Conds) > 0 && len(v.Query.RawQuery) == 0 {
+			body["query"] = buildQuery(v.Query.Conds)
+		} else if v.Query != nil && len(v.Query.RawQuery) > 0 {
+			raw := util.MapStr{}
+			err := util.FromJSONBytes(v.Query.RawQuery, &raw)
+			if err != nil {
+				return err
+			}
+			if q, ok := raw["query"]; ok {
+				body["query"] = q
+			}
+		}
+		queryBody = util.MustToJSONBytes(body)
+	default:
+		return errors.Errorf("unsupported query type: %T", query)
 	}
 	_, err := handler.Client.UpdateByQuery(handler.GetIndexName(o), queryBody)
 	return err
 }
 
 func (handler *ElasticORM) Count(o interface{}, query interface{}) (int64, error) {
-	var queryBody []byte
-
-	if query != nil {
-		var ok bool
-		if queryBody, ok = query.([]byte); !ok {
-			return 0, errors.New("type of param query should be byte array")
-		}
+	queryBody, err := getQueryBody(query)
+	if err != nil {
+		return 0, err
 	}
 	countResponse, err := handler.Client.Count(nil, handler.GetIndexName(o), queryBody)
 	if err != nil {
@@ -297,28 +366,7 @@ func (handler *ElasticORM) Search(t interface{}, q *api.Query) (error, api.Resul
 	} else {
 
 		if q.Conds != nil && len(q.Conds) > 0 {
-			request.Query = &elastic.Query{}
-
-			boolQuery := elastic.BoolQuery{}
-
-			for _, c1 := range q.Conds {
-				q := getQuery(c1)
-				switch c1.BoolType {
-				case api.Must:
-					boolQuery.Must = append(boolQuery.Must, q)
-					break
-				case api.MustNot:
-					boolQuery.MustNot = append(boolQuery.MustNot, q)
-					break
-				case api.Should:
-					boolQuery.Should = append(boolQuery.Should, q)
-					break
-				}
-
-			}
-
-			request.Query.BoolQuery = &boolQuery
-
+			request.Query = buildQuery(q.Conds)
 		}
 
 		if q.Sort != nil && len(*q.Sort) > 0 {
@@ -29,6 +29,7 @@ package elastic
 
 import (
 	"fmt"
+	"github.com/rubyniu105/framework/core/orm"
 	"github.com/rubyniu105/framework/core/util"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -102,3 +103,20 @@ func TestSchemaRegister(t *testing.T) {
 	//fmt.Println(indexName)
 
 }
+
+func TestGetQueryBody(t *testing.T) {
+	body, err := getQueryBody([]byte(`{"query":{"match_all":{}}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"query":{"match_all":{}}}`, string(body))
+
+	body, err = getQueryBody(&orm.Query{Conds: orm.And(orm.Eq("enabled", true))})
+	assert.Nil(t, err)
+	assert.Contains(t, string(body), `"must":[{"match":{"enabled":true}}]`)
+
+	body, err = getQueryBody(nil)
+	assert.Nil(t, err)
+	assert.Nil(t, body)
+
+	_, err = getQueryBody("invalid")
+	assert.NotNil(t, err)
+}
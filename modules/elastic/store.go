@@ -28,6 +28,7 @@
 package elastic
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
 	"github.com/bkaradzic/go-lz4"
@@ -38,6 +39,7 @@ import (
 	"github.com/rubyniu105/framework/core/util"
 	"github.com/rubyniu105/framework/modules/elastic/common"
 	"net/http"
+	"time"
 )
 
 type ElasticStore struct {
@@ -84,7 +86,7 @@ func (store *ElasticStore) ExistsKey(bucket string, key []byte) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	if response.Found {
+	if response.Found && !isExpired(response.Source) {
 		content := response.Source["content"]
 		if content != nil {
 			return true, nil
@@ -94,20 +96,30 @@ func (store *ElasticStore) ExistsKey(bucket string, key []byte) (bool, error) {
 }
 
 func (store *ElasticStore) GetValue(bucket string, key []byte) ([]byte, error) {
+	value, _, err := store.getValue(bucket, key)
+	return value, err
+}
+
+// getValue returns the value together with the raw response for conditional writes
+func (store *ElasticStore) getValue(bucket string, key []byte) ([]byte, *elastic.GetResponse, error) {
 	response, err := store.Client.Get(store.Config.IndexName, "_doc", getKey(bucket, string(key)))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if response.Found {
+		if isExpired(response.Source) {
+			return nil, response, nil
+		}
 		content := response.Source["content"]
 		if content != nil {
 			uDec, err := base64.URLEncoding.DecodeString(content.(string))
 
 			if err != nil {
-				return nil, err
+				return nil, response, err
 			}
-			return uDec, nil
+			return uDec, response, nil
 		}
+		return nil, response, nil
 	}
 	if response.StatusCode != http.StatusNotFound {
 		var (
@@ -117,9 +129,18 @@ func (store *ElasticStore) GetValue(bucket string, key []byte) ([]byte, error) {
 		if errStr, ok = response.ESError.(string); !ok {
 			errStr = util.MustToJSON(response.ESError)
 		}
-		return nil, fmt.Errorf("get value error: %s", errStr)
+		return nil, response, fmt.Errorf("get value error: %s", errStr)
+	}
+	return nil, response, nil
+}
+
+func isExpired(source map[string]interface{}) bool {
+	expireAt, ok := source["expire_at"]
+	if !ok {
+		return false
 	}
-	return nil, nil
+	v, ok := expireAt.(float64)
+	return ok && v > 0 && int64(v) <= time.Now().UnixMilli()
 }
 
 func (store *ElasticStore) AddValueCompress(bucket string, key []byte, value []byte) error {
@@ -135,13 +156,118 @@ func getKey(bucket, key string) string {
 	return util.MD5digest(fmt.Sprintf("%s_%s", bucket, key))
 }
 
-func (store *ElasticStore) AddValue(bucket string, key []byte, value []byte) error {
-	file := Blob{}
+func newBlob(bucket string, key []byte, value []byte, ttl time.Duration) Blob {
+	file := Blob{Bucket: bucket, Key: string(key)}
 	file.Content = base64.URLEncoding.EncodeToString(value)
-	_, err := store.Client.Index(store.Config.IndexName, "_doc", getKey(bucket, string(key)), file, "")
+	if ttl > 0 {
+		file.ExpireAt = time.Now().Add(ttl).UnixMilli()
+	}
+	return file
+}
+
+func (store *ElasticStore) AddValue(bucket string, key []byte, value []byte) error {
+	return store.AddValueWithTTL(bucket, key, value, 0)
+}
+
+func (store *ElasticStore) AddValueWithTTL(bucket string, key []byte, value []byte, ttl time.Duration) error {
+	_, err := store.Client.Index(store.Config.IndexName, "_doc", getKey(bucket, string(key)), newBlob(bucket, key, value, ttl), "")
 	return err
 }
 
+func (store *ElasticStore) PutIfAbsent(bucket string, key []byte, value []byte, ttl time.Duration) (bool, error) {
+	return store.CompareAndSwap(bucket, key, nil, value, ttl)
+}
+
+func (store *ElasticStore) CompareAndSwap(bucket string, key []byte, old, new []byte, ttl time.Duration) (bool, error) {
+	current, response, err := store.getValue(bucket, key)
+	if err != nil {
+		return false, err
+	}
+	if (current != nil) != (old != nil) || (current != nil && !bytes.Equal(current, old)) {
+		return false, nil
+	}
+
+	options := &elastic.IndexOptions{}
+	if response != nil && response.Found {
+		//guard against concurrent writers, expired documents are overwritten the same way
+		if response.PrimaryTerm > 0 {
+			options.IfSeqNo = response.SeqNo
+			options.IfPrimaryTerm = response.PrimaryTerm
+		} else if response.Version > 0 {
+			//elasticsearch before 6.7, only one writer can bump the version
+			options.Version = int64(response.Version) + 1
+		} else {
+			return false, errors.Errorf("no version info of key [%v] in bucket [%v], unable to swap", string(key), bucket)
+		}
+	} else {
+		options.OpType = "create"
+	}
+
+	var file Blob
+	if new == nil {
+		//a tombstone that is already expired, so the swap stays conditional
+		file = Blob{Bucket: bucket, Key: string(key), ExpireAt: time.Now().UnixMilli()}
+	} else {
+		file = newBlob(bucket, key, new, ttl)
+	}
+
+	_, err = store.Client.IndexWithOptions(store.Config.IndexName, "_doc", getKey(bucket, string(key)), file, options)
+	if err == elastic.ErrVersionConflict {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+const scanPageSize = 1000
+
+func (store *ElasticStore) ScanPrefix(bucket string, prefix []byte, fn func(key, value []byte) bool) error {
+	query := util.MapStr{
+		"size": scanPageSize,
+		//keys are unique per bucket, so search_after on the key never skips a document
+		"sort": []util.MapStr{{"key": util.MapStr{"order": "asc"}}},
+		"query": util.MapStr{
+			"bool": util.MapStr{
+				"filter": []util.MapStr{
+					{"term": util.MapStr{"bucket": bucket}},
+					{"prefix": util.MapStr{"key": string(prefix)}},
+				},
+			},
+		},
+	}
+	for {
+		response, err := store.Client.SearchWithRawQueryDSL(store.Config.IndexName, util.MustToJSONBytes(query))
+		if err != nil {
+			return err
+		}
+		hits := response.Hits.Hits
+		for _, hit := range hits {
+			if isExpired(hit.Source) {
+				continue
+			}
+			content, ok := hit.Source["content"].(string)
+			if !ok {
+				continue
+			}
+			value, err := base64.URLEncoding.DecodeString(content)
+			if err != nil {
+				return err
+			}
+			key, _ := hit.Source["key"].(string)
+			if !fn([]byte(key), value) {
+				return nil
+			}
+		}
+		if len(hits) < scanPageSize {
+			return nil
+		}
+		//continue after the last hit of this page
+		query["search_after"] = hits[len(hits)-1].Sort
+	}
+}
+
 func (store *ElasticStore) DeleteKey(bucket string, key []byte) error {
 	_, err := store.Client.Delete(store.Config.IndexName, "_doc", getKey(bucket, string(key)))
 	return err
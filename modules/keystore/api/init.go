@@ -31,5 +31,5 @@ import "github.com/rubyniu105/framework/core/api"
 
 func Init() {
 	handler := APIHandler{}
-	api.HandleAPIMethod(api.POST, "/keystore", handler.setKeystoreValue)
+	api.HandleAPIMethod(api.POST, "/keystore", handler.setKeystoreValue, api.RequirePermission("keystore:write"))
 }
@@ -28,10 +28,8 @@ import (
 
 	log "github.com/cihub/seelog"
 	httprouter "github.com/rubyniu105/framework/core/api/router"
-	"github.com/rubyniu105/framework/core/config"
 	"github.com/rubyniu105/framework/core/pipeline"
 	"github.com/rubyniu105/framework/core/util"
-	"github.com/rubyniu105/framework/lib/go-ucfg"
 )
 
 func (module *PipeModule) getPipelinesHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
@@ -88,6 +86,11 @@ func (module *PipeModule) getPipelineStatus(id string, config string, processor
 		EndTime:    c1.GetEndTime(),
 		Context:    c1.CloneData(),
 	}
+	if v1, ok := module.configs.Load(id); ok {
+		if cfg, ok := v1.(pipeline.PipelineConfigV2); ok && cfg.Singleton {
+			ret.Lease = getPipelineLease(id)
+		}
+	}
 	if config != "false" {
 		v1, ok := module.configs.Load(id)
 		if !ok {
@@ -129,15 +132,11 @@ func (module *PipeModule) createPipelineHandler(w http.ResponseWriter, req *http
 		log.Error("failed to parse pipeline config: ", err)
 		return
 	}
-	var processors []*config.Config
-	for _, processorDict := range obj.Processors {
-		processor, err := ucfg.NewFrom(processorDict)
-		if err != nil {
-			module.WriteError(w, err.Error(), http.StatusBadRequest)
-			log.Error("failed to parse processor config: ", err)
-			return
-		}
-		processors = append(processors, config.FromConfig(processor))
+	processors, err := parseProcessorConfigs(obj.Processors)
+	if err != nil {
+		module.WriteError(w, err.Error(), http.StatusBadRequest)
+		log.Error("failed to parse processor config: ", err)
+		return
 	}
 	pipelineConfig := obj.PipelineConfigV2
 	pipelineConfig.Processors = processors
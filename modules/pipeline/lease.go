@@ -0,0 +1,146 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pipeline
+
+import (
+	"sync/atomic"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/rubyniu105/framework/core/global"
+	"github.com/rubyniu105/framework/core/locker"
+	"github.com/rubyniu105/framework/core/pipeline"
+)
+
+const pipelineSingleton = "pipeline_singleton"
+
+// singletonLease makes sure a singleton pipeline only runs on one node at a time,
+// the lease is renewed in background while held, and taken over by another node once it expires
+type singletonLease struct {
+	name   string
+	nodeID string
+	ttl    time.Duration
+	token  uint64
+
+	lost atomic.Bool
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newSingletonLease(name string, ttl time.Duration) *singletonLease {
+	return &singletonLease{
+		name:   name,
+		nodeID: global.Env().SystemConfig.NodeConfig.ID,
+		ttl:    ttl,
+	}
+}
+
+// acquire tries to hold the lease, renewals will cancel the running task of ctx if the lease got lost
+func (l *singletonLease) acquire(ctx *pipeline.Context) (bool, error) {
+	ok, token, err := locker.HoldWithToken(pipelineSingleton, l.name, l.nodeID, l.ttl, true)
+	if err != nil || !ok {
+		return false, err
+	}
+	l.token = token
+	l.lost.Store(false)
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	go l.keepAlive(ctx, l.stop, l.done)
+	log.Debugf("pipeline [%v] lease acquired, node: %v, token: %v", l.name, l.nodeID, token)
+	return true, nil
+}
+
+func (l *singletonLease) keepAlive(ctx *pipeline.Context, stop, done chan struct{}) {
+	defer close(done)
+
+	interval := l.ttl / 3
+	if interval < time.Second {
+		//lock timestamps are in seconds
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			ok, token, err := locker.HoldWithToken(pipelineSingleton, l.name, l.nodeID, l.ttl, false)
+			if err != nil {
+				//keep trying until the lease expires
+				log.Warnf("failed to renew lease of pipeline [%v], %v", l.name, err)
+				continue
+			}
+			if !ok || token != l.token {
+				log.Warnf("pipeline [%v] lost its lease, stop running on this node", l.name)
+				l.lost.Store(true)
+				ctx.CancelTask()
+				return
+			}
+		}
+	}
+}
+
+// held returns true if the lease was acquired and not lost since
+func (l *singletonLease) held() bool {
+	return l.stop != nil && !l.lost.Load()
+}
+
+// release stops renewing and hands the lease over to other nodes
+func (l *singletonLease) release() {
+	if l.stop == nil {
+		return
+	}
+	close(l.stop)
+	<-l.done
+	l.stop = nil
+
+	if l.lost.Load() {
+		return
+	}
+	err := locker.ReleaseWithToken(pipelineSingleton, l.name, l.nodeID, l.token)
+	if err != nil {
+		log.Warnf("failed to release lease of pipeline [%v], %v", l.name, err)
+		return
+	}
+	log.Debugf("pipeline [%v] lease released, node: %v, token: %v", l.name, l.nodeID, l.token)
+}
+
+func getPipelineLease(name string) *PipelineLease {
+	ok, info, err := locker.GetAllocateInfo(pipelineSingleton, name)
+	if err != nil {
+		log.Warnf("failed to get lease of pipeline [%v], %v", name, err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	return &PipelineLease{
+		Node:      info.ClientID,
+		Token:     info.Token,
+		RenewedAt: info.Timestamp,
+		Local:     info.ClientID == global.Env().SystemConfig.NodeConfig.ID,
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rubyniu105/framework/core/kv"
+	"github.com/rubyniu105/framework/core/locker"
+	"github.com/rubyniu105/framework/core/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	kv.Register("memory", kv.NewMemoryStore())
+}
+
+func newTestLease(name, nodeID string, ttl time.Duration) (*singletonLease, *pipeline.Context) {
+	lease := newSingletonLease(name, ttl)
+	lease.nodeID = nodeID
+	ctx := pipeline.AcquireContext(pipeline.PipelineConfigV2{Name: name})
+	ctx.ResetContext()
+	return lease, ctx
+}
+
+func TestSingletonLeaseHandover(t *testing.T) {
+	a, ctxA := newTestLease("handover", "node_a", time.Minute)
+	b, ctxB := newTestLease("handover", "node_b", time.Minute)
+
+	ok, err := a.acquire(ctxA)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.True(t, a.held())
+
+	ok, _ = b.acquire(ctxB)
+	assert.False(t, ok)
+	assert.False(t, b.held())
+
+	lease := getPipelineLease("handover")
+	assert.NotNil(t, lease)
+	assert.Equal(t, "node_a", lease.Node)
+	assert.Equal(t, uint64(1), lease.Token)
+
+	a.release()
+	assert.False(t, a.held())
+	assert.Nil(t, getPipelineLease("handover"))
+
+	ok, _ = b.acquire(ctxB)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), b.token)
+	b.release()
+}
+
+func TestSingletonLeaseLost(t *testing.T) {
+	a, ctxA := newTestLease("lost", "node_a", 3*time.Second)
+	ok, _ := a.acquire(ctxA)
+	assert.True(t, ok)
+
+	// node_b takes over, like the lease of node_a expired
+	_, info, _ := locker.GetAllocateInfo(pipelineSingleton, "lost")
+	revoked, _ := locker.Revoke(info)
+	assert.True(t, revoked)
+	ok, token, _ := locker.HoldWithToken(pipelineSingleton, "lost", "node_b", time.Minute, true)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), token)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for !ctxA.IsCanceled() && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.True(t, ctxA.IsCanceled())
+	assert.False(t, a.held())
+
+	// must not release the lease of the new holder
+	a.release()
+	lease := getPipelineLease("lost")
+	assert.NotNil(t, lease)
+	assert.Equal(t, "node_b", lease.Node)
+}
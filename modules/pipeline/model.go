@@ -38,4 +38,13 @@ type PipelineStatus struct {
 	Context    util.MapStr                `json:"context"`
 	Config     *pipeline.PipelineConfigV2 `json:"config"`
 	Processors []map[string]interface{}   `json:"processor"`
+	Lease      *PipelineLease             `json:"lease,omitempty"`
+}
+
+// PipelineLease describes which node owns a singleton pipeline
+type PipelineLease struct {
+	Node      string    `json:"node"`
+	Token     uint64    `json:"token"`
+	RenewedAt time.Time `json:"renewed_at"`
+	Local     bool      `json:"local"`
 }
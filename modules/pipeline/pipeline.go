@@ -27,7 +27,6 @@ import (
 	"context"
 	"fmt"
 	"github.com/fsnotify/fsnotify"
-	"github.com/rubyniu105/framework/core/locker"
 	"github.com/rubyniu105/framework/core/task"
 	"runtime"
 	"sync"
@@ -79,13 +78,14 @@ func (module *PipeModule) Setup() {
 	pipeline.RegisterProcessorPlugin("dag", pipeline.NewDAGProcessor)
 	pipeline.RegisterProcessorPlugin("echo", NewEchoProcessor)
 
-	api.HandleAPIMethod(api.GET, "/pipeline/tasks/", module.getPipelinesHandler)
-	api.HandleAPIMethod(api.POST, "/pipeline/tasks/_search", module.searchPipelinesHandler)
-	api.HandleAPIMethod(api.POST, "/pipeline/tasks/", module.createPipelineHandler)
-	api.HandleAPIMethod(api.GET, "/pipeline/task/:id", module.getPipelineHandler)
-	api.HandleAPIMethod(api.DELETE, "/pipeline/task/:id", module.deletePipelineHandler)
-	api.HandleAPIMethod(api.POST, "/pipeline/task/:id/_start", module.startTaskHandler)
-	api.HandleAPIMethod(api.POST, "/pipeline/task/:id/_stop", module.stopTaskHandler)
+	api.HandleAPIMethod(api.GET, "/pipeline/tasks/", module.getPipelinesHandler, api.RequirePermission("pipeline:read"))
+	api.HandleAPIMethod(api.POST, "/pipeline/tasks/_search", module.searchPipelinesHandler, api.RequirePermission("pipeline:read"))
+	api.HandleAPIMethod(api.POST, "/pipeline/tasks/", module.createPipelineHandler, api.RequirePermission("pipeline:write"))
+	api.HandleAPIMethod(api.GET, "/pipeline/task/:id", module.getPipelineHandler, api.RequirePermission("pipeline:read"))
+	api.HandleAPIMethod(api.DELETE, "/pipeline/task/:id", module.deletePipelineHandler, api.RequirePermission("pipeline:delete"))
+	api.HandleAPIMethod(api.POST, "/pipeline/task/:id/_start", module.startTaskHandler, api.RequirePermission("pipeline:write"))
+	api.HandleAPIMethod(api.POST, "/pipeline/task/:id/_stop", module.stopTaskHandler, api.RequirePermission("pipeline:write"))
+	api.HandleAPIMethod(api.POST, "/pipeline/_simulate", module.simulatePipelineHandler, api.RequirePermission("pipeline:write"))
 
 }
 
@@ -387,8 +387,6 @@ func (module *PipeModule) stopAndWaitForRelease(taskIDs []string, timeout time.D
 	}
 }
 
-const pipelineSingleton = "pipeline_singleton"
-
 var creatingLocker = sync.Mutex{}
 
 func (module *PipeModule) createPipeline(v pipeline.PipelineConfigV2, transient bool) error {
@@ -471,6 +469,16 @@ func (module *PipeModule) createPipeline(v pipeline.PipelineConfigV2, transient
 
 		started := false
 
+		var lease *singletonLease
+		if cfg.Singleton {
+			ttl := 60000
+			if cfg.MaxRunningInMs > 0 {
+				ttl = cfg.MaxRunningInMs
+			}
+			lease = newSingletonLease(cfg.Name, time.Duration(ttl)*time.Millisecond)
+			defer lease.release()
+		}
+
 		for {
 			if global.ShuttingDown() {
 				log.Debugf("system is shutting down, pipeline [%v] will be stopped", cfg.Name)
@@ -497,14 +505,13 @@ func (module *PipeModule) createPipeline(v pipeline.PipelineConfigV2, transient
 			case pipeline.STARTING:
 
 				//check
-				if v.Singleton {
-					if v.MaxRunningInMs <= 0 {
-						v.MaxRunningInMs = 60000
-					}
-					ok, err := locker.Hold(pipelineSingleton, v.Name, global.Env().SystemConfig.NodeConfig.ID, time.Duration(v.MaxRunningInMs)*time.Millisecond, true)
+				if lease != nil && !lease.held() {
+					lease.release()
+					ok, err := lease.acquire(ctx)
 					if !ok {
+						// stay standby, take over once the lease of current holder expires
 						log.Debugf("pipeline [%v] is already running somewhere, %v", cfg.Name, err)
-						ctx.Finished()
+						time.Sleep(time.Duration(retryDelayInMs) * time.Millisecond)
 						continue
 					}
 				}
@@ -516,6 +523,12 @@ func (module *PipeModule) createPipeline(v pipeline.PipelineConfigV2, transient
 				started = true
 				ctx.Started()
 				ctx.ResetContext()
+				if lease != nil && !lease.held() {
+					// lost before the task context was renewed
+					ctx.Finished()
+					started = false
+					continue
+				}
 
 				err = processor.Process(ctx)
 
@@ -550,10 +563,16 @@ func (module *PipeModule) createPipeline(v pipeline.PipelineConfigV2, transient
 					ctx.Starting()
 				} else {
 					ctx.Stopped()
+					if lease != nil {
+						lease.release()
+					}
 					ctx.Pause()
 				}
 			case pipeline.STOPPED:
 				// Pipeline manually stopped, pause
+				if lease != nil {
+					lease.release()
+				}
 				ctx.Pause()
 			}
 		}
@@ -23,7 +23,10 @@
 
 package pipeline
 
-import "github.com/rubyniu105/framework/core/pipeline"
+import (
+	"github.com/rubyniu105/framework/core/pipeline"
+	"github.com/rubyniu105/framework/core/util"
+)
 
 type GetPipelinesResponse map[string]*PipelineStatus
 
@@ -35,3 +38,40 @@ type CreatePipelineRequest struct {
 type SearchPipelinesRequest struct {
 	Ids []string `json:"ids"`
 }
+
+// SimulatePipelineRequest runs the processors once, they are really executed, processors with side effects,
+// eg: indexing, producing to queues or calling external services, take their effects as usual
+type SimulatePipelineRequest struct {
+	pipeline.PipelineConfigV2
+	Processors []map[string]interface{} `json:"processor"`
+	Parameters util.MapStr              `json:"parameters,omitempty"`
+	//messages are placed into the context as []queue.Message, string data is used as it is, others are encoded as json
+	Messages     []interface{} `json:"messages,omitempty"`
+	MessageField string        `json:"message_field,omitempty"`
+	//the request returns when the timeout is reached, a blocking processor is left running in background
+	Timeout string `json:"timeout,omitempty"`
+}
+
+type SimulateProcessorResult struct {
+	Name      string            `json:"name"`
+	Took      string            `json:"took"`
+	TookInMs  int64             `json:"took_in_ms"`
+	Error     string            `json:"error,omitempty"`
+	Mutations *ContextMutations `json:"mutations,omitempty"`
+	Skipped   bool              `json:"skipped,omitempty"`
+}
+
+type ContextMutations struct {
+	Added   util.MapStr `json:"added,omitempty"`
+	Updated util.MapStr `json:"updated,omitempty"`
+	Removed []string    `json:"removed,omitempty"`
+}
+
+type SimulatePipelineResponse struct {
+	Took       string                     `json:"took"`
+	State      pipeline.RunningState      `json:"state"`
+	Processors []*SimulateProcessorResult `json:"processors"`
+	Errors     []string                   `json:"errors,omitempty"`
+	Flow       []string                   `json:"flow"`
+	Context    util.MapStr                `json:"context"`
+}
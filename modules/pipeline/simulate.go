@@ -0,0 +1,286 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"time"
+
+	log "github.com/cihub/seelog"
+	httprouter "github.com/rubyniu105/framework/core/api/router"
+	"github.com/rubyniu105/framework/core/config"
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/pipeline"
+	"github.com/rubyniu105/framework/core/queue"
+	"github.com/rubyniu105/framework/core/util"
+	"github.com/rubyniu105/framework/lib/go-ucfg"
+)
+
+const defaultSimulateTimeout = 30 * time.Second
+
+var errSimulateTimeout = errors.New("simulation timed out, processors may still be running")
+
+func parseProcessorConfigs(processorDicts []map[string]interface{}) ([]*config.Config, error) {
+	var processors []*config.Config
+	for _, processorDict := range processorDicts {
+		processor, err := ucfg.NewFrom(processorDict)
+		if err != nil {
+			return nil, err
+		}
+		processors = append(processors, config.FromConfig(processor))
+	}
+	return processors, nil
+}
+
+// simulatePipelineHandler runs the processors once without registering the pipeline,
+// be aware that processors still take their side effects, eg: indexing documents
+func (module *PipeModule) simulatePipelineHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	var obj = SimulatePipelineRequest{}
+	err := module.DecodeJSON(req, &obj)
+	if err != nil {
+		module.WriteError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := simulatePipeline(&obj)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == errSimulateTimeout {
+			status = http.StatusGatewayTimeout
+		}
+		module.WriteError(w, err.Error(), status)
+		return
+	}
+	module.WriteJSON(w, resp, 200)
+}
+
+func simulatePipeline(obj *SimulatePipelineRequest) (*SimulatePipelineResponse, error) {
+	timeout := defaultSimulateTimeout
+	if obj.Timeout != "" {
+		var err error
+		timeout, err = time.ParseDuration(obj.Timeout)
+		if err != nil {
+			return nil, errors.Errorf("invalid timeout: %v", obj.Timeout)
+		}
+	}
+
+	processorConfigs, err := parseProcessorConfigs(obj.Processors)
+	if err != nil {
+		return nil, err
+	}
+	processors, err := pipeline.NewPipeline(processorConfigs)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := obj.PipelineConfigV2
+	cfg.Processors = processorConfigs
+	cfg.Transient = true
+
+	ctx := pipeline.AcquireContext(cfg)
+	ctx.Starting()
+	ctx.Started()
+	ctx.ResetContext()
+
+	var cancel context.CancelFunc
+	ctx.Context, cancel = context.WithTimeout(ctx.Context, timeout)
+	release := func() {
+		cancel()
+		pipeline.ReleaseContext(ctx)
+		processors.Release()
+	}
+
+	err = prepareSimulateContext(ctx, obj)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	//processors are not aware of the deadline, run them aside so that a blocking one can't hang the request
+	done := make(chan *SimulatePipelineResponse, 1)
+	deadline := ctx.Context.Done()
+	go func() {
+		defer release()
+		done <- runSimulation(ctx, processors)
+	}()
+
+	select {
+	case resp := <-done:
+		return resp, nil
+	case <-deadline:
+		log.Warnf("simulating pipeline timed out after %v, processors may still be running", timeout)
+		return nil, errSimulateTimeout
+	}
+}
+
+func prepareSimulateContext(ctx *pipeline.Context, obj *SimulatePipelineRequest) error {
+	for k, v := range obj.Parameters {
+		_, err := ctx.PutValue(k, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(obj.Messages) > 0 {
+		field := obj.MessageField
+		if field == "" {
+			field = "messages"
+		}
+		_, err := ctx.PutValue(field, buildSimulateMessages(obj.Messages))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runSimulation(ctx *pipeline.Context, processors *pipeline.Processors) *SimulatePipelineResponse {
+	start := time.Now()
+	resp := &SimulatePipelineResponse{Processors: []*SimulateProcessorResult{}}
+	for _, p := range processors.List {
+		if !ctx.ShouldContinue() || ctx.IsCanceled() {
+			resp.Processors = append(resp.Processors, &SimulateProcessorResult{Name: p.Name(), Skipped: true})
+			continue
+		}
+		result := simulateProcessor(ctx, p)
+		resp.Processors = append(resp.Processors, result)
+		if result.Error != "" {
+			ctx.Failed(errors.New(result.Error))
+			break
+		}
+	}
+
+	if !ctx.IsFailed() {
+		ctx.Finished()
+	}
+
+	resp.Took = time.Since(start).String()
+	resp.State = ctx.GetRunningState()
+	for _, e := range ctx.Errors() {
+		resp.Errors = append(resp.Errors, e.Error())
+	}
+	resp.Flow = ctx.GetFlowProcess()
+	resp.Context = ctx.CloneData()
+	return resp
+}
+
+func buildSimulateMessages(msgs []interface{}) []queue.Message {
+	messages := make([]queue.Message, 0, len(msgs))
+	now := time.Now().Unix()
+	for i, v := range msgs {
+		var data []byte
+		if str, ok := v.(string); ok {
+			data = []byte(str)
+		} else {
+			data = util.MustToJSONBytes(v)
+		}
+		messages = append(messages, queue.Message{
+			Timestamp:  now,
+			Offset:     queue.NewOffset(0, int64(i)),
+			NextOffset: queue.NewOffset(0, int64(i+1)),
+			Size:       len(data),
+			Data:       data,
+		})
+	}
+	return messages
+}
+
+func simulateProcessor(ctx *pipeline.Context, p pipeline.Processor) (result *SimulateProcessorResult) {
+	result = &SimulateProcessorResult{Name: p.Name()}
+	before := ctx.CloneData()
+	start := time.Now()
+
+	defer func() {
+		if r := recover(); r != nil {
+			var v string
+			switch r.(type) {
+			case error:
+				v = r.(error).Error()
+			case runtime.Error:
+				v = r.(runtime.Error).Error()
+			case string:
+				v = r.(string)
+			default:
+				v = fmt.Sprint(r)
+			}
+			log.Errorf("error on simulating processor [%v], %v", p.Name(), v)
+			result.Error = v
+		}
+		took := time.Since(start)
+		result.Took = took.String()
+		result.TookInMs = took.Milliseconds()
+		result.Mutations = diffContext(before, ctx.CloneData())
+	}()
+
+	ctx.AddFlowProcess(p.Name())
+	err := p.Process(ctx)
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func contextValueEquals(a, b interface{}) bool {
+	x, err1 := util.ToJSONBytes(a)
+	y, err2 := util.ToJSONBytes(b)
+	if err1 != nil || err2 != nil {
+		return fmt.Sprint(a) == fmt.Sprint(b)
+	}
+	return string(x) == string(y)
+}
+
+// diffContext compares flattened context data, returns nil if nothing changed
+func diffContext(before, after util.MapStr) *ContextMutations {
+	x := before.Flatten()
+	y := after.Flatten()
+	mutations := ContextMutations{}
+	for k, v := range y {
+		old, ok := x[k]
+		if !ok {
+			if mutations.Added == nil {
+				mutations.Added = util.MapStr{}
+			}
+			mutations.Added[k] = v
+		} else if !contextValueEquals(old, v) {
+			if mutations.Updated == nil {
+				mutations.Updated = util.MapStr{}
+			}
+			mutations.Updated[k] = util.MapStr{"from": old, "to": v}
+		}
+	}
+	for k := range x {
+		if _, ok := y[k]; !ok {
+			mutations.Removed = append(mutations.Removed, k)
+		}
+	}
+	if mutations.Added == nil && mutations.Updated == nil && mutations.Removed == nil {
+		return nil
+	}
+	sort.Strings(mutations.Removed)
+	return &mutations
+}
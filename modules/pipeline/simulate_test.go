@@ -0,0 +1,98 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rubyniu105/framework/core/config"
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/pipeline"
+	"github.com/rubyniu105/framework/core/queue"
+	"github.com/stretchr/testify/assert"
+)
+
+type simulateTestProcessor struct {
+	fail  bool
+	Block bool `config:"block"`
+}
+
+func (p *simulateTestProcessor) Name() string {
+	return "simulate_test"
+}
+
+func (p *simulateTestProcessor) Process(ctx *pipeline.Context) error {
+	if p.fail {
+		return errors.New("failed on purpose")
+	}
+	if p.Block {
+		time.Sleep(time.Second)
+	}
+	v, _ := ctx.GetValue("messages")
+	ctx.PutValue("count", len(v.([]queue.Message)))
+	ctx.PutValue("user.name", "test")
+	return nil
+}
+
+func init() {
+	pipeline.RegisterProcessorPlugin("simulate_test", func(c *config.Config) (pipeline.Processor, error) {
+		p := simulateTestProcessor{}
+		err := c.Unpack(&p)
+		return &p, err
+	})
+}
+
+func TestSimulatePipeline(t *testing.T) {
+	req := &SimulatePipelineRequest{
+		Processors: []map[string]interface{}{
+			{"simulate_test": map[string]interface{}{}},
+		},
+		Parameters: map[string]interface{}{"user": map[string]interface{}{"name": "medcl"}},
+		Messages:   []interface{}{"hello", map[string]interface{}{"a": 1}},
+	}
+	resp, err := simulatePipeline(req)
+	assert.Nil(t, err)
+	assert.Equal(t, pipeline.FINISHED, resp.State)
+	assert.Equal(t, []string{"simulate_test"}, resp.Flow)
+	assert.Equal(t, 1, len(resp.Processors))
+	assert.Equal(t, 2, resp.Processors[0].Mutations.Added["count"])
+	assert.NotNil(t, resp.Processors[0].Mutations.Updated["user.name"])
+
+	_, err = simulatePipeline(&SimulatePipelineRequest{Processors: []map[string]interface{}{{"not_exists": nil}}})
+	assert.NotNil(t, err)
+}
+
+func TestSimulatePipelineTimeout(t *testing.T) {
+	req := &SimulatePipelineRequest{
+		Processors: []map[string]interface{}{
+			{"simulate_test": map[string]interface{}{"block": true}},
+		},
+		Timeout: "50ms",
+	}
+	start := time.Now()
+	_, err := simulatePipeline(req)
+	assert.Equal(t, errSimulateTimeout, err)
+	assert.True(t, time.Since(start) < time.Second)
+}
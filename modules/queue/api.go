@@ -48,25 +48,26 @@ type API struct {
 
 func init() {
 	module := API{}
-	api.HandleAPIMethod(api.GET, "/queue/stats", module.QueueStatsAction)
-	api.HandleAPIMethod(api.GET, "/queue/:id/stats", module.SingleQueueStatsAction)
-	api.HandleAPIMethod(api.GET, "/queue/:id/_scroll", module.QueueExplore)
+	api.HandleAPIMethod(api.GET, "/queue/stats", module.QueueStatsAction, api.RequirePermission("queue:read"))
+	api.HandleAPIMethod(api.GET, "/queue/:id/stats", module.SingleQueueStatsAction, api.RequirePermission("queue:read"))
+	api.HandleAPIMethod(api.GET, "/queue/:id/_scroll", module.QueueExplore, api.RequirePermission("queue:read"))
+	api.HandleAPIMethod(api.GET, "/queue/:id/_explore", module.QueueExplore, api.RequirePermission("queue:read"))
 
-	api.HandleAPIMethod(api.DELETE, "/queue/:id", module.DeleteQueue)
-	api.HandleAPIMethod(api.DELETE, "/queue/_search", module.DeleteQueuesByQuery)
+	api.HandleAPIMethod(api.DELETE, "/queue/:id", module.DeleteQueue, api.RequirePermission("queue:delete"))
+	api.HandleAPIMethod(api.DELETE, "/queue/_search", module.DeleteQueuesByQuery, api.RequirePermission("queue:delete"))
 
 	//create consumer
 	//api.HandleAPIMethod(api.POST,"/queue/:id/consumer/:consumer_id", module.QueueResetConsumerOffset)
 
 	//reset consumer offset
-	api.HandleAPIMethod(api.PUT, "/queue/:id/consumer/:consumer_id/offset", module.QueueResetConsumerOffset)
+	api.HandleAPIMethod(api.PUT, "/queue/:id/consumer/:consumer_id/offset", module.QueueResetConsumerOffset, api.RequirePermission("queue:write"))
 	//get consumer offset
-	api.HandleAPIMethod(api.GET, "/queue/:id/consumer/:consumer_id/offset", module.QueueGetConsumerOffset)
+	api.HandleAPIMethod(api.GET, "/queue/:id/consumer/:consumer_id/offset", module.QueueGetConsumerOffset, api.RequirePermission("queue:read"))
 
 	// delete consumer and it's offset
-	api.HandleAPIMethod(api.DELETE, "/queue/:id/consumer/:consumer_id", module.QueueDeleteConsumerByID)
+	api.HandleAPIMethod(api.DELETE, "/queue/:id/consumer/:consumer_id", module.QueueDeleteConsumerByID, api.RequirePermission("queue:delete"))
 	// delete all consumers of queues specified by query
-	api.HandleAPIMethod(api.DELETE, "/queue/consumer/_search", module.DeleteConsumersByQuery)
+	api.HandleAPIMethod(api.DELETE, "/queue/consumer/_search", module.DeleteConsumersByQuery, api.RequirePermission("queue:delete"))
 }
 
 func (module *API) SingleQueueStatsAction(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
@@ -278,6 +279,12 @@ func (module *API) QueueExplore(w http.ResponseWriter, req *http.Request, ps htt
 					msg["message"] = string(v.Data)
 					msg["offset"] = v.Offset.String()
 					msg["size"] = v.Size
+					if len(v.Key) > 0 {
+						msg["key"] = string(v.Key)
+					}
+					if len(v.Headers) > 0 {
+						msg["headers"] = v.Headers
+					}
 					msgs = append(msgs, msg)
 				}
 				result["messages"] = msgs
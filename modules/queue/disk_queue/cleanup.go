@@ -29,10 +29,16 @@ package queue
 
 import (
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	log "github.com/cihub/seelog"
 	"github.com/rubyniu105/framework/core/global"
 	"github.com/rubyniu105/framework/core/queue"
+	"github.com/rubyniu105/framework/core/stats"
 	"github.com/rubyniu105/framework/core/util"
 )
 
@@ -84,6 +90,9 @@ func (module *DiskQueue) GetLatestOffsetByQueueID(queueID string) (int, int64) {
 
 func (module *DiskQueue) deleteUnusedFiles(queueID string, fileNum int64) {
 
+	//apply retention by age/size/s3 first, slow consumers should not pin the files
+	module.applyRetention(queueID)
+
 	//no consumers or consumer/s3 already ahead of this file
 	//TODO add config to configure none-consumers queue, to enable upload to s3 or not
 
@@ -173,3 +182,125 @@ func (module *DiskQueue) deleteUnusedFiles(queueID string, fileNum int64) {
 	}
 
 }
+
+type segmentFile struct {
+	num     int64
+	size    int64
+	modTime time.Time
+	files   []string
+}
+
+// listSegmentFiles returns the local segment files (flat and compressed) older than the write segment, ordered by segment num
+func listSegmentFiles(queueID string, writeSegmentNum int64) ([]*segmentFile, int64) {
+	entries, err := os.ReadDir(GetDataPath(queueID))
+	if err != nil {
+		log.Debugf("failed to list files for queue: %v, %v", queueID, err)
+		return nil, 0
+	}
+
+	var totalBytes int64
+	segments := map[int64]*segmentFile{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), compressFileSuffix)
+		if !strings.HasSuffix(name, ".dat") {
+			continue
+		}
+		num, err := strconv.ParseInt(strings.TrimSuffix(name, ".dat"), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		totalBytes += info.Size()
+		if num >= writeSegmentNum {
+			continue
+		}
+		seg, ok := segments[num]
+		if !ok {
+			seg = &segmentFile{num: num, modTime: info.ModTime()}
+			segments[num] = seg
+		}
+		seg.size += info.Size()
+		if info.ModTime().After(seg.modTime) {
+			seg.modTime = info.ModTime()
+		}
+		seg.files = append(seg.files, filepath.Join(GetDataPath(queueID), entry.Name()))
+	}
+
+	result := make([]*segmentFile, 0, len(segments))
+	for _, v := range segments {
+		result = append(result, v)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].num < result[j].num
+	})
+	return result, totalBytes
+}
+
+// applyRetention deletes local segments by max_age and max_bytes, even if some consumers are still behind,
+// and the segments already uploaded to s3 and consumed by all consumers if delete_after_save_to_s3 enabled.
+// segments not uploaded yet are always kept when upload_to_s3 enabled
+func (module *DiskQueue) applyRetention(queueID string) {
+	retention := module.cfg.Retention
+	if retention.maxAge <= 0 && retention.maxBytes <= 0 && !retention.DeleteAfterSaveToS3 {
+		return
+	}
+
+	q, ok := module.queues.Load(queueID)
+	if !ok {
+		return
+	}
+	writeSegmentNum := q.(*DiskBasedQueue).ReadContext().WriteFileNum
+
+	segments, totalBytes := listSegmentFiles(queueID, writeSegmentNum)
+	if len(segments) == 0 {
+		return
+	}
+
+	var lastSavedFileNum int64 = -1
+	if module.cfg.UploadToS3 {
+		lastSavedFileNum = GetLastS3UploadFileNum(queueID)
+	}
+	consumers, eSegmentNum := module.GetEarlierOffsetByQueueID(queueID)
+
+	now := time.Now()
+	for _, seg := range segments {
+		uploaded := module.cfg.UploadToS3 && seg.num <= lastSavedFileNum
+		if module.cfg.UploadToS3 && !uploaded {
+			//keep the rest of files until they are uploaded
+			break
+		}
+
+		var reason string
+		if retention.DeleteAfterSaveToS3 && uploaded && (consumers <= 0 || seg.num < eSegmentNum) {
+			reason = "uploaded_to_s3"
+		} else if retention.maxAge > 0 && now.Sub(seg.modTime) > retention.maxAge {
+			reason = "max_age"
+		} else if retention.maxBytes > 0 && uint64(totalBytes) > retention.maxBytes {
+			reason = "max_bytes"
+		} else {
+			continue
+		}
+
+		if consumers > 0 && seg.num >= eSegmentNum {
+			log.Warnf("queue: %v, segment: %v hit retention [%v], but consumer is still on segment: %v, messages will be skipped", queueID, seg.num, reason, eSegmentNum)
+			stats.Increment("queue", queueID, "retention_skipped_segments")
+		}
+
+		for _, file := range seg.files {
+			log.Debugf("queue: %v, delete file by retention [%v]: %v", queueID, reason, file)
+			err := os.Remove(file)
+			if err != nil && !os.IsNotExist(err) {
+				log.Error(err)
+				return
+			}
+		}
+		totalBytes -= seg.size
+		stats.Increment("queue", queueID, "retention_deleted_segments")
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package queue
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rubyniu105/framework/core/env"
+	"github.com/rubyniu105/framework/core/global"
+	"github.com/rubyniu105/framework/core/kv"
+	"github.com/rubyniu105/framework/core/queue"
+	"github.com/rubyniu105/framework/core/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	kv.Register("memory", kv.NewMemoryStore())
+	env1 := env.EmptyEnv()
+	env1.SystemConfig.PathConfig.Data = "/tmp/disk_queue_" + util.PickRandomName()
+	global.RegisterEnv(env1)
+	code := m.Run()
+	os.RemoveAll(env1.SystemConfig.PathConfig.Data)
+	os.Exit(code)
+}
+
+func TestApplyRetention(t *testing.T) {
+	queueID := "retention_test"
+	queue.RegisterConfig(&queue.QueueConfig{ID: queueID, Name: queueID})
+	os.MkdirAll(GetDataPath(queueID), 0755)
+	for i := int64(0); i <= 5; i++ {
+		file := GetFileName(queueID, i)
+		if i == 1 {
+			file += compressFileSuffix
+		}
+		util.FilePutContentWithByte(file, make([]byte, 100))
+		mtime := time.Now().Add(time.Duration(i-10) * time.Hour)
+		os.Chtimes(file, mtime, mtime)
+	}
+
+	module := DiskQueue{cfg: &DiskQueueConfig{}}
+	module.queues.Store(queueID, &DiskBasedQueue{writeSegmentNum: 5})
+
+	segments, total := listSegmentFiles(queueID, 5)
+	assert.Equal(t, 5, len(segments))
+	assert.Equal(t, int64(600), total)
+
+	//nothing configured
+	module.applyRetention(queueID)
+	segments, _ = listSegmentFiles(queueID, 5)
+	assert.Equal(t, 5, len(segments))
+
+	//segment 0 and 1 older than 8.5h
+	module.cfg.Retention = RetentionConfig{MaxAge: "510m"}
+	assert.Nil(t, module.cfg.Retention.parse())
+	module.applyRetention(queueID)
+	segments, _ = listSegmentFiles(queueID, 5)
+	assert.Equal(t, 3, len(segments))
+	assert.Equal(t, int64(2), segments[0].num)
+	assert.False(t, util.FileExists(GetFileName(queueID, 1)+compressFileSuffix))
+
+	//keep 300 bytes at most, the write segment is never deleted
+	module.cfg.Retention = RetentionConfig{MaxBytes: "300b"}
+	assert.Nil(t, module.cfg.Retention.parse())
+	module.applyRetention(queueID)
+	segments, total = listSegmentFiles(queueID, 5)
+	assert.Equal(t, 2, len(segments))
+	assert.Equal(t, int64(3), segments[0].num)
+	assert.Equal(t, int64(300), total)
+	assert.True(t, util.FileExists(GetFileName(queueID, 5)))
+
+	assert.NotNil(t, (&RetentionConfig{MaxAge: "abc"}).parse())
+	assert.NotNil(t, (&RetentionConfig{MaxBytes: "abc"}).parse())
+}
@@ -231,6 +231,8 @@ READ_MSG:
 		return messages, false, err
 	}
 
+	msgSize, versioned := parseRecordSize(msgSize)
+
 	if int32(msgSize) < d.mCfg.MinMsgSize || int32(msgSize) > d.mCfg.MaxMsgSize {
 
 		//current have changes, reload file with new position
@@ -343,6 +345,15 @@ READ_MSG:
 			NextOffset: queue.NewOffsetWithVersion(d.segment, nextReadPos, d.version),
 		}
 
+		if versioned {
+			message.Key, message.Headers, message.Data, err = decodeRecord(readBuf)
+			if err != nil {
+				log.Errorf("queue:%v, offset:%v,%v, %v", d.queue, d.segment, previousPos, err)
+				ctx.UpdateNextOffset(d.segment, nextReadPos)
+				return messages, false, err
+			}
+		}
+
 		ctx.UpdateNextOffset(d.segment, nextReadPos)
 
 		messages = append(messages, message)
@@ -512,7 +523,7 @@ func (d *Consumer) ResetOffset(segment, readPos int64) error {
 				return errors.New(fileName + " not found and auto_skip_corrupt_file not enabled.")
 			}
 		}
-		return errors.Errorf("current file: " + fileName + " not found, and next_file_exists not exists.")
+		return errors.Errorf("current file: %v not found, and next_file_exists not exists.", fileName)
 	}
 
 FIND_NEXT_FILE:
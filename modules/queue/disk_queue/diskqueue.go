@@ -103,7 +103,7 @@ type DiskBasedQueue struct {
 
 	// internal channels
 	depthChan         chan int64
-	writeChan         chan []byte
+	writeChan         chan writeRequest
 	writeResponseChan chan WriteResponse
 	emptyChan         chan int
 	emptyResponseChan chan error
@@ -124,7 +124,7 @@ func NewDiskQueueByConfig(name, dataPath string, cfg *DiskQueueConfig) *DiskBase
 		cfg:                cfg,
 		readChan:           make(chan []byte, cfg.ReadChanBuffer),
 		depthChan:          make(chan int64),
-		writeChan:          make(chan []byte, cfg.WriteChanBuffer),
+		writeChan:          make(chan writeRequest, cfg.WriteChanBuffer),
 		writeResponseChan:  make(chan WriteResponse),
 		emptyChan:          make(chan int),
 		emptyResponseChan:  make(chan error),
@@ -177,6 +177,24 @@ func (d *DiskBasedQueue) ReadChan() <-chan []byte {
 
 // Put writes a []byte to the queue
 func (d *DiskBasedQueue) Put(data []byte) WriteResponse {
+	return d.put(writeRequest{data: data})
+}
+
+// PutRecord writes the message with key and headers, falls back to the legacy record format if both are empty
+func (d *DiskBasedQueue) PutRecord(key []byte, headers map[string]string, data []byte) WriteResponse {
+	if !isVersionedRecord(key, headers) {
+		return d.put(writeRequest{data: data})
+	}
+	return d.put(writeRequest{data: encodeRecord(key, headers, data), versioned: true})
+}
+
+type writeRequest struct {
+	data      []byte
+	versioned bool
+}
+
+func (d *DiskBasedQueue) put(req writeRequest) WriteResponse {
+	data := req.data
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(d.cfg.WriteTimeoutInMS)*time.Millisecond)
 	defer cancel()
 
@@ -222,7 +240,7 @@ func (d *DiskBasedQueue) Put(data []byte) WriteResponse {
 	}
 
 	select {
-	case d.writeChan <- data:
+	case d.writeChan <- req:
 		return <-d.writeResponseChan
 	case <-ctx.Done():
 		// Handle timeout
@@ -459,6 +477,7 @@ func (d *DiskBasedQueue) readOne() ([]byte, error) {
 		d.readFile = nil
 		return nil, err
 	}
+	msgSize, versioned := parseRecordSize(msgSize)
 
 	if msgSize < d.cfg.MinMsgSize || msgSize > d.cfg.MaxMsgSize {
 		// this file is corrupt and we have no reasonable guarantee on
@@ -506,7 +525,12 @@ func (d *DiskBasedQueue) readOne() ([]byte, error) {
 		if err != nil {
 			return nil, err
 		}
-		return newData, nil
+		readBuf = newData
+	}
+
+	if versioned {
+		_, _, data, err := decodeRecord(readBuf)
+		return data, err
 	}
 
 	return readBuf, nil
@@ -520,7 +544,8 @@ type WriteResponse struct {
 
 // writeOne performs a low level filesystem write for a single []byte
 // while advancing write positions and rolling files, if necessary
-func (d *DiskBasedQueue) writeOne(data []byte) WriteResponse {
+func (d *DiskBasedQueue) writeOne(req writeRequest) WriteResponse {
+	data := req.data
 	var err error
 	var res WriteResponse
 
@@ -566,7 +591,7 @@ func (d *DiskBasedQueue) writeOne(data []byte) WriteResponse {
 	}
 
 	d.writeBuf.Reset()
-	err = binary.Write(&d.writeBuf, binary.BigEndian, dataLen)
+	err = binary.Write(&d.writeBuf, binary.BigEndian, encodeRecordSize(dataLen, req.versioned))
 	if err != nil {
 		res.Error = err
 		return res
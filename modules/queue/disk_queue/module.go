@@ -62,9 +62,30 @@ func (module *DiskQueue) Name() string {
 }
 
 type RetentionConfig struct {
-	MaxNumOfLocalFiles int64 `config:"max_num_of_local_files"`
-	//DeleteAfterSaveToS3 bool `config:"delete_after_save_to_s3"`
-	//MaxAge int  	   `config:"max_age"`
+	MaxNumOfLocalFiles  int64  `config:"max_num_of_local_files"`
+	DeleteAfterSaveToS3 bool   `config:"delete_after_save_to_s3"` //delete local files once uploaded to s3 and consumed by all consumers
+	MaxAge              string `config:"max_age"`                 //eg: 72h, 7d, segments older than this will be deleted, even not consumed yet
+	MaxBytes            string `config:"max_bytes"`               //eg: 50gb, max total size of local files per queue
+
+	maxAge   time.Duration
+	maxBytes uint64
+}
+
+func (cfg *RetentionConfig) parse() error {
+	var err error
+	if cfg.MaxAge != "" {
+		cfg.maxAge, err = util.ParseDuration(cfg.MaxAge)
+		if err != nil {
+			return errors.Errorf("invalid retention.max_age: %v, %v", cfg.MaxAge, err)
+		}
+	}
+	if cfg.MaxBytes != "" {
+		cfg.maxBytes, err = util.ToBytes(cfg.MaxBytes)
+		if err != nil {
+			return errors.Errorf("invalid retention.max_bytes: %v, %v", cfg.MaxBytes, err)
+		}
+	}
+	return nil
 }
 
 //#  disk.max_used_bytes:  100GB #trigger warning message
@@ -254,6 +275,11 @@ func (module *DiskQueue) Setup() {
 		return
 	}
 
+	err = module.cfg.Retention.parse()
+	if err != nil {
+		panic(err)
+	}
+
 	//load configs from local metadata
 	if util.FileExists(common.GetLocalQueueConfigPath()) {
 		data, err := util.FileGetContent(common.GetLocalQueueConfigPath())
@@ -55,7 +55,7 @@ func (p *Producer) Produce(reqs *[]queue.ProduceRequest) (*[]queue.ProduceRespon
 			panic(errors.Errorf("invalid topic: %v vs %v", req.Topic, p.cfg.ID))
 		}
 
-		res := p.q.Put(req.Data)
+		res := p.q.PutRecord(req.Key, req.Headers, req.Data)
 		if res.Error != nil {
 			return &results, res.Error
 		}
@@ -0,0 +1,143 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package queue
+
+import (
+	"encoding/binary"
+	"sort"
+
+	"github.com/rubyniu105/framework/core/errors"
+)
+
+// records written by early versions are plain `[size][data]`, the highest bit of the size marks a versioned record
+// `[size|recordVersionFlag][version][key][headers][data]`, only used when the message has key or headers
+const recordVersionFlag = uint32(1) << 31
+
+const recordFormatV2 byte = 2
+
+// parseRecordSize returns the real size of the record, and whether the record is versioned
+func parseRecordSize(size int32) (int32, bool) {
+	v := uint32(size)
+	if v&recordVersionFlag != 0 {
+		return int32(v &^ recordVersionFlag), true
+	}
+	return size, false
+}
+
+func encodeRecordSize(size int32, versioned bool) int32 {
+	if versioned {
+		return int32(uint32(size) | recordVersionFlag)
+	}
+	return size
+}
+
+func isVersionedRecord(key []byte, headers map[string]string) bool {
+	return len(key) > 0 || len(headers) > 0
+}
+
+// encodeRecord encodes the body of a versioned record, all lengths are uvarint encoded
+func encodeRecord(key []byte, headers map[string]string, data []byte) []byte {
+	size := 1 + binary.MaxVarintLen64*(2+len(headers)*2) + len(key) + len(data)
+	for k, v := range headers {
+		size += len(k) + len(v)
+	}
+	buf := make([]byte, 0, size)
+	buf = append(buf, recordFormatV2)
+	buf = binary.AppendUvarint(buf, uint64(len(key)))
+	buf = append(buf, key...)
+
+	//keep the order stable
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	buf = binary.AppendUvarint(buf, uint64(len(keys)))
+	for _, k := range keys {
+		buf = binary.AppendUvarint(buf, uint64(len(k)))
+		buf = append(buf, k...)
+		v := headers[k]
+		buf = binary.AppendUvarint(buf, uint64(len(v)))
+		buf = append(buf, v...)
+	}
+	return append(buf, data...)
+}
+
+func readRecordBytes(buf []byte, pos int) ([]byte, int, error) {
+	l, n := binary.Uvarint(buf[pos:])
+	if n <= 0 {
+		return nil, pos, errors.New("invalid record, failed to read length")
+	}
+	pos += n
+	if uint64(len(buf)-pos) < l {
+		return nil, pos, errors.New("invalid record, length exceeded")
+	}
+	end := pos + int(l)
+	return buf[pos:end], end, nil
+}
+
+// decodeRecord decodes the body of a versioned record
+func decodeRecord(buf []byte) (key []byte, headers map[string]string, data []byte, err error) {
+	if len(buf) == 0 {
+		return nil, nil, nil, errors.New("invalid record, empty body")
+	}
+	if buf[0] != recordFormatV2 {
+		return nil, nil, nil, errors.Errorf("unsupported record version: %v", buf[0])
+	}
+
+	pos := 1
+	key, pos, err = readRecordBytes(buf, pos)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(key) == 0 {
+		key = nil
+	}
+
+	num, n := binary.Uvarint(buf[pos:])
+	if n <= 0 {
+		return nil, nil, nil, errors.New("invalid record, failed to read headers")
+	}
+	pos += n
+	if num > 0 {
+		headers = make(map[string]string, num)
+	}
+	for i := uint64(0); i < num; i++ {
+		var k, v []byte
+		k, pos, err = readRecordBytes(buf, pos)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		v, pos, err = readRecordBytes(buf, pos)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		headers[string(k)] = string(v)
+	}
+	return key, headers, buf[pos:], nil
+}
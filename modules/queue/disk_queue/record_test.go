@@ -0,0 +1,109 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package queue
+
+import (
+	"os"
+	"testing"
+
+	"github.com/rubyniu105/framework/core/queue"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordCodec(t *testing.T) {
+	size, versioned := parseRecordSize(encodeRecordSize(1024, true))
+	assert.Equal(t, int32(1024), size)
+	assert.True(t, versioned)
+
+	size, versioned = parseRecordSize(encodeRecordSize(1024, false))
+	assert.Equal(t, int32(1024), size)
+	assert.False(t, versioned)
+
+	headers := map[string]string{"trace_id": "abc", "content_type": "application/json", "empty": ""}
+	buf := encodeRecord([]byte("user-1"), headers, []byte(`{"a":1}`))
+	key, h, data, err := decodeRecord(buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "user-1", string(key))
+	assert.Equal(t, headers, h)
+	assert.Equal(t, `{"a":1}`, string(data))
+
+	key, h, data, err = decodeRecord(encodeRecord(nil, nil, []byte("hello")))
+	assert.Nil(t, err)
+	assert.Nil(t, key)
+	assert.Nil(t, h)
+	assert.Equal(t, "hello", string(data))
+
+	_, _, _, err = decodeRecord(buf[:10])
+	assert.NotNil(t, err)
+	_, _, _, err = decodeRecord([]byte{9, 0, 0})
+	assert.NotNil(t, err)
+}
+
+func TestReadMixedRecords(t *testing.T) {
+	queueID := "record_test"
+	qCfg := &queue.QueueConfig{ID: queueID, Name: queueID}
+	queue.RegisterConfig(qCfg)
+	os.MkdirAll(GetDataPath(queueID), 0755)
+
+	cfg := &DiskQueueConfig{
+		MinMsgSize:       1,
+		MaxMsgSize:       1024 * 1024,
+		MaxBytesPerFile:  1024 * 1024,
+		SyncEveryRecords: 1,
+		SyncTimeoutInMS:  1000,
+		WriteTimeoutInMS: 1000,
+	}
+	cfg.Compress.Message.Enabled = true
+	cfg.Compress.Message.Level = 3
+
+	q := NewDiskQueueByConfig(queueID, GetDataPath(queueID), cfg)
+	defer q.Close()
+
+	//legacy record, then versioned record
+	assert.Nil(t, q.Put([]byte("legacy")).Error)
+	assert.Nil(t, q.PutRecord([]byte("k1"), map[string]string{"trace_id": "t1"}, []byte("v2")).Error)
+	assert.Nil(t, q.PutRecord(nil, nil, []byte("plain")).Error)
+
+	cCfg := queue.NewConsumerConfig(queueID, "group", "name")
+	cCfg.FetchMaxMessages = 10
+	consumer, err := q.AcquireConsumer(qCfg, cCfg, queue.NewOffset(0, 0))
+	assert.Nil(t, err)
+	defer consumer.Close()
+
+	messages, _, err := consumer.FetchMessages(&queue.Context{}, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(messages))
+	assert.Equal(t, "legacy", string(messages[0].Data))
+	assert.Nil(t, messages[0].Headers)
+	assert.Equal(t, "v2", string(messages[1].Data))
+	assert.Equal(t, "k1", string(messages[1].Key))
+	assert.Equal(t, "t1", messages[1].Headers["trace_id"])
+	assert.Equal(t, messages[1].NextOffset, messages[2].Offset)
+	assert.Equal(t, "plain", string(messages[2].Data))
+	assert.Nil(t, messages[2].Key)
+}
@@ -0,0 +1,210 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package redis
+
+import (
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/go-redis/redis/v8"
+	"github.com/rubyniu105/framework/core/global"
+	"github.com/rubyniu105/framework/core/locker"
+	"github.com/rubyniu105/framework/core/queue"
+	"github.com/rubyniu105/framework/core/util"
+)
+
+const defaultFetchCount = 1000
+
+type Consumer struct {
+	qCfg *queue.QueueConfig
+	cCfg *queue.ConsumerConfig
+
+	handler *RedisQueue
+	stream  string
+	name    string
+
+	//messages delivered to this consumer but not acknowledged are replayed first, empty when done
+	pendingCursor string
+}
+
+func (this *Consumer) Close() error {
+	return nil
+}
+
+func (this *Consumer) ResetOffset(segment, readPos int64) error {
+	if global.Env().IsDebug {
+		log.Debugf("reset %v offset to %v,%v", this.qCfg.ID, segment, readPos)
+	}
+
+	//drop the messages in flight of this consumer, the group restarts from the new position
+	err := this.handler.ackPendingRange(this.stream, this.cCfg.Group, this.name, "+")
+	if err != nil {
+		return err
+	}
+	err = this.handler.client.XGroupSetID(ctx, this.stream, this.cCfg.Group, prevStreamID(queue.NewOffset(segment, readPos))).Err()
+	if err != nil {
+		return err
+	}
+	this.pendingCursor = ""
+	return nil
+}
+
+func (this *Consumer) CommitOffset(off queue.Offset) error {
+	err := this.handler.ackPendingBefore(this.stream, this.cCfg.Group, this.name, off)
+	if global.Env().IsDebug {
+		log.Infof("commit %v[%v] offset: %v, %v", this.qCfg.Name, this.qCfg.ID, off.String(), err)
+	}
+	return err
+}
+
+func (this *Consumer) read(id string, count int64, block time.Duration) ([]redis.XMessage, error) {
+	res, err := this.handler.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    this.cCfg.Group,
+		Consumer: this.name,
+		Streams:  []string{this.stream, id},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	msgs := []redis.XMessage{}
+	for _, s := range res {
+		msgs = append(msgs, s.Messages...)
+	}
+	return msgs, nil
+}
+
+func (this *Consumer) FetchMessages(ctx *queue.Context, numOfMessages int) (messages []queue.Message, isTimeout bool, err error) {
+
+	k := getGroupKey(this.cCfg.Group, this.qCfg.ID)
+	ok, err := locker.Hold(queue.BucketWhoOwnsThisTopic, k, global.Env().SystemConfig.NodeConfig.ID, time.Duration(this.cCfg.ClientExpiredInSeconds)*time.Second, true)
+	if !ok || err != nil {
+		panic("failed to hold lock for topic: " + this.qCfg.ID)
+	}
+
+	count := int64(numOfMessages)
+	if count <= 0 {
+		count = int64(this.cCfg.FetchMaxMessages)
+	}
+	if count <= 0 {
+		count = defaultFetchCount
+	}
+
+	var msgs []redis.XMessage
+	if this.pendingCursor != "" {
+		msgs, err = this.read(this.pendingCursor, count, -1)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(msgs) > 0 {
+			this.pendingCursor = msgs[len(msgs)-1].ID
+		} else {
+			this.pendingCursor = ""
+		}
+	}
+
+	if len(msgs) == 0 {
+		block := time.Duration(-1)
+		if this.cCfg.FetchMaxWaitMs > 0 {
+			block = time.Duration(this.cCfg.FetchMaxWaitMs) * time.Millisecond
+		}
+		msgs, err = this.read(">", count, block)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	ctx.MessageCount = 0
+	for _, msg := range msgs {
+		//acknowledged or trimmed entries come back without fields while replaying
+		if msg.Values == nil {
+			continue
+		}
+		offset, err := offsetOfStreamID(msg.ID)
+		if err != nil {
+			return nil, false, err
+		}
+		nextOffset := queue.NewOffset(offset.Segment, offset.Position+1)
+		data := getMessageData(msg.Values, dataField)
+		messages = append(messages, queue.Message{
+			Offset:     offset,
+			NextOffset: nextOffset,
+			Data:       data,
+			Size:       len(data),
+			Timestamp:  offset.Segment / 1000,
+			Key:        getMessageData(msg.Values, keyField),
+			Headers:    getMessageHeaders(msg.Values),
+		})
+		if ctx.MessageCount == 0 {
+			ctx.InitOffset = offset
+		}
+		ctx.NextOffset = nextOffset
+		ctx.MessageCount++
+	}
+
+	if ctx.MessageCount == 0 {
+		return nil, true, nil
+	}
+
+	if global.Env().IsDebug {
+		log.Debug(this.qCfg.Name, "[", this.qCfg.ID, "],", this.cCfg.ID, ",msg:", len(messages), ",first:", ctx.InitOffset, ",last:", ctx.NextOffset)
+	}
+	return messages, false, nil
+}
+
+func getMessageHeaders(values map[string]interface{}) map[string]string {
+	data := getMessageData(values, headersField)
+	if len(data) == 0 {
+		return nil
+	}
+	headers := map[string]string{}
+	err := util.FromJSONBytes(data, &headers)
+	if err != nil {
+		log.Warnf("invalid message headers: %v, %v", string(data), err)
+		return nil
+	}
+	return headers
+}
+
+func getMessageData(values map[string]interface{}, field string) []byte {
+	v, ok := values[field]
+	if !ok || v == nil {
+		return nil
+	}
+	switch x := v.(type) {
+	case string:
+		return []byte(x)
+	case []byte:
+		return x
+	}
+	return []byte(util.ToString(v))
+}
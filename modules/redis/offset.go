@@ -0,0 +1,92 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package redis
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/queue"
+)
+
+// stream ID `<ms>-<seq>` maps to queue.Offset{Segment: ms, Position: seq}
+
+func parseStreamID(id string) (int64, int64, error) {
+	arr := strings.Split(id, "-")
+	if len(arr) != 2 {
+		return 0, 0, errors.Errorf("invalid stream id: %v", id)
+	}
+	ms, err := strconv.ParseInt(arr[0], 10, 64)
+	if err != nil {
+		return 0, 0, errors.Errorf("invalid stream id: %v", id)
+	}
+	seq, err := strconv.ParseInt(arr[1], 10, 64)
+	if err != nil {
+		return 0, 0, errors.Errorf("invalid stream id: %v", id)
+	}
+	return ms, seq, nil
+}
+
+func offsetOfStreamID(id string) (queue.Offset, error) {
+	ms, seq, err := parseStreamID(id)
+	if err != nil {
+		return queue.Offset{}, err
+	}
+	return queue.NewOffset(ms, seq), nil
+}
+
+// nextOffsetOfStreamID returns the smallest offset after the stream ID
+func nextOffsetOfStreamID(id string) (queue.Offset, error) {
+	ms, seq, err := parseStreamID(id)
+	if err != nil {
+		return queue.Offset{}, err
+	}
+	return queue.NewOffset(ms, seq+1), nil
+}
+
+// offsetOfDeliveredID converts a group's last delivered ID to the offset to resume from
+func offsetOfDeliveredID(id string) (queue.Offset, error) {
+	if id == "" || id == "0-0" || id == "0" {
+		return queue.NewOffset(0, 0), nil
+	}
+	return nextOffsetOfStreamID(id)
+}
+
+// prevStreamID returns the largest stream ID before the offset, used as group's last delivered ID
+func prevStreamID(offset queue.Offset) string {
+	if offset.Position > 0 {
+		return fmt.Sprintf("%d-%d", offset.Segment, offset.Position-1)
+	}
+	if offset.Segment > 0 {
+		return fmt.Sprintf("%d-%d", offset.Segment-1, uint64(math.MaxUint64))
+	}
+	return "0"
+}
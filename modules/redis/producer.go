@@ -0,0 +1,81 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package redis
+
+import (
+	"github.com/go-redis/redis/v8"
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/queue"
+)
+
+type Producer struct {
+	cfg     *queue.QueueConfig
+	handler *RedisQueue
+}
+
+func (p *Producer) Produce(reqs *[]queue.ProduceRequest) (*[]queue.ProduceResponse, error) {
+
+	if p.handler == nil || reqs == nil {
+		panic(errors.New("invalid request"))
+	}
+
+	topics := make([]string, 0, len(*reqs))
+	cmds := make([]*redis.StringCmd, 0, len(*reqs))
+	pipe := p.handler.client.Pipeline()
+	for _, req := range *reqs {
+		topic := req.Topic
+		if topic == "" {
+			topic = p.cfg.ID
+		}
+		topics = append(topics, topic)
+		cmds = append(cmds, pipe.XAdd(ctx, p.handler.newAddArgs(p.handler.getStreamName(topic), req.Key, req.Headers, req.Data)))
+	}
+	_, err := pipe.Exec(ctx)
+
+	results := []queue.ProduceResponse{}
+	for i, cmd := range cmds {
+		id, err := cmd.Result()
+		if err != nil {
+			continue
+		}
+		offset, err := offsetOfStreamID(id)
+		if err != nil {
+			continue
+		}
+		results = append(results, queue.ProduceResponse{
+			Topic:     topics[i],
+			Offset:    offset,
+			Timestamp: offset.Segment / 1000,
+		})
+	}
+	return &results, err
+}
+
+func (p *Producer) Close() error {
+	return nil
+}
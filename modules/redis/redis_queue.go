@@ -29,13 +29,18 @@ package redis
 import (
 	"context"
 	"fmt"
-	"github.com/rubyniu105/framework/core/global"
+	"strings"
 	"sync"
 	"time"
 
+	log "github.com/cihub/seelog"
 	"github.com/go-redis/redis/v8"
 	"github.com/rubyniu105/framework/core/env"
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/global"
+	"github.com/rubyniu105/framework/core/locker"
 	"github.com/rubyniu105/framework/core/queue"
+	"github.com/rubyniu105/framework/core/util"
 )
 
 type RedisModule struct {
@@ -44,13 +49,21 @@ type RedisModule struct {
 }
 
 type RedisConfig struct {
-	Enabled  bool   `config:"enabled"`
-	Host     string `config:"host"`
-	Port     int    `config:"port"`
-	Username string `config:"username"`
-	Password string `config:"password"`
-	PoolSize int    `config:"pool_size"`
-	Db       int    `config:"db"`
+	Enabled  bool             `config:"enabled"`
+	Host     string           `config:"host"`
+	Port     int              `config:"port"`
+	Username string           `config:"username"`
+	Password string           `config:"password"`
+	PoolSize int              `config:"pool_size"`
+	Db       int              `config:"db"`
+	Queue    RedisQueueConfig `config:"queue"`
+}
+
+type RedisQueueConfig struct {
+	Enabled bool   `config:"enabled"`
+	Default bool   `config:"default"`
+	Prefix  string `config:"prefix"`  //namespace of the streams, must not be empty, the list based queues of old versions use the bare name
+	MaxLen  int64  `config:"max_len"` //approximate max entries per stream, 0 means unlimited
 }
 
 func (module *RedisModule) Name() string {
@@ -70,86 +83,457 @@ func (module *RedisModule) Setup() {
 
 var ctx = context.Background()
 
+const defaultStreamPrefix = "queue_stream:"
+
+const dataField = "data"
+const keyField = "key"
+const headersField = "headers"
+
+// RedisQueue stores each queue as a redis stream, consumer groups of the stream map to queue consumer groups
 type RedisQueue struct {
-	client *redis.Client
-	pubsub map[string]int
+	client    *redis.Client
+	cfg       *RedisQueueConfig
+	q         sync.Map
+	consumers sync.Map //group+queue=instance
+	producers sync.Map //queue=instance
+}
+
+func NewRedisQueue(client *redis.Client, cfg *RedisQueueConfig) *RedisQueue {
+	if cfg == nil {
+		cfg = &RedisQueueConfig{}
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = defaultStreamPrefix
+	}
+	return &RedisQueue{client: client, cfg: cfg}
 }
 
 func (module *RedisQueue) Name() string {
 	return "redis_queue"
 }
 
-func (module *RedisQueue) Push(k string, v []byte) error {
-	module.Init(k)
-	_, err := module.client.LPush(ctx, k, v).Result()
-	return err
+func (module *RedisQueue) getStreamName(k string) string {
+	return module.cfg.Prefix + k
+}
+
+func getGroupKey(group, q string) string {
+	return group + "_" + q
 }
 
 func (module *RedisQueue) Init(k string) error {
-	_, ok := module.pubsub[k]
-	if ok {
+	if _, ok := module.q.Load(k); ok {
 		return nil
 	}
-	module.pubsub[k] = 1
+	stream := module.getStreamName(k)
+	err := module.migrateLegacyList(k, stream)
+	if err != nil {
+		return err
+	}
+	module.q.Store(k, stream)
 	return nil
 }
 
-var lock sync.RWMutex
+// migrateLegacyList moves the messages left in the list based queue of old versions to the stream, oldest first
+func (module *RedisQueue) migrateLegacyList(k, stream string) error {
+	t, err := module.client.Type(ctx, k).Result()
+	if err != nil {
+		return err
+	}
+	if t != "list" {
+		return nil
+	}
+	count := 0
+	for {
+		v, err := module.client.RPop(ctx, k).Bytes()
+		if err == redis.Nil {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		err = module.client.XAdd(ctx, module.newAddArgs(stream, nil, nil, v)).Err()
+		if err != nil {
+			//put it back, the migration continues on next init
+			module.client.RPush(ctx, k, v)
+			return err
+		}
+		count++
+	}
+	log.Infof("migrated %v messages of queue [%v] from legacy list to stream [%v]", count, k, stream)
+	return nil
+}
 
-func (module *RedisQueue) Consume(q *queue.QueueConfig, consumer *queue.ConsumerConfig, offsetStr string) (ctx *queue.Context, messages []queue.Message, timeout bool, err error) {
-	//TODO
-	panic("consume is not supported in redis queue")
+func (module *RedisQueue) newAddArgs(stream string, key []byte, headers map[string]string, data []byte) *redis.XAddArgs {
+	values := map[string]interface{}{dataField: data}
+	if len(key) > 0 {
+		values[keyField] = key
+	}
+	if len(headers) > 0 {
+		values[headersField] = util.MustToJSONBytes(headers)
+	}
+	args := &redis.XAddArgs{Stream: stream, Values: values}
+	if module.cfg != nil && module.cfg.MaxLen > 0 {
+		args.MaxLen = module.cfg.MaxLen
+		args.Approx = true
+	}
+	return args
 }
 
-func (module *RedisQueue) Pop(k string, timeoutDuration time.Duration) (data []byte, timeout bool) {
+func (module *RedisQueue) Push(k string, v []byte) error {
+	if len(v) == 0 {
+		panic(errors.New("invalid data"))
+	}
+	err := module.Init(k)
+	if err != nil {
+		return err
+	}
+	_, err = module.client.XAdd(ctx, module.newAddArgs(module.getStreamName(k), nil, nil, v)).Result()
+	return err
+}
 
-	lock.Lock()
-	defer lock.Unlock()
+// Pop reads one message through a dedicated group without acknowledgement
+func (module *RedisQueue) Pop(k string, timeoutDuration time.Duration) (data []byte, timeout bool) {
+	err := module.Init(k)
+	if err != nil {
+		log.Error(err)
+		return nil, true
+	}
+	stream := module.getStreamName(k)
+	err = module.createGroup(stream, "default", "0")
+	if err != nil {
+		log.Error(err)
+		return nil, true
+	}
 
+	block := time.Duration(-1)
 	if timeoutDuration > 0 {
-		v, err := module.client.BLPop(ctx, timeoutDuration, k).Result()
-		if err != nil {
-			return nil, true
-		}
-
-		if len(v) == 1 {
-			return []byte(v[0]), false
+		block = timeoutDuration
+	}
+	res, err := module.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "default",
+		Consumer: "default",
+		Streams:  []string{stream, ">"},
+		Count:    1,
+		Block:    block,
+		NoAck:    true,
+	}).Result()
+	if err != nil {
+		if err != redis.Nil {
+			log.Error(err)
 		}
+		return nil, true
+	}
 
-	} else {
-		v, err := module.client.LPop(ctx, k).Result()
-		if err != nil {
-			return nil, true
+	for _, s := range res {
+		for _, msg := range s.Messages {
+			return getMessageData(msg.Values, dataField), false
 		}
-		return []byte(v), false
 	}
 	return nil, true
 }
 
 func (module *RedisQueue) Close(k string) error {
-	return module.client.Subscribe(ctx, k).Close()
-}
-
-func (module *RedisQueue) LatestOffset(string) string {
-	panic("consume is not supported in redis queue")
+	return nil
 }
 
 func (module *RedisQueue) Depth(k string) int64 {
-	c, err := module.client.LLen(ctx, k).Result()
+	c, err := module.client.XLen(ctx, module.getStreamName(k)).Result()
 	if err != nil {
 		return -1
 	}
 	return c
 }
 
+func (module *RedisQueue) GetStorageSize(k string) uint64 {
+	size, err := module.client.MemoryUsage(ctx, module.getStreamName(k)).Result()
+	if err != nil {
+		if err != redis.Nil {
+			log.Errorf("get storage size for %v, error:%v", k, err)
+		}
+		return 0
+	}
+	return uint64(size)
+}
+
+func (module *RedisQueue) Destroy(k string) error {
+	_, err := module.client.Del(ctx, module.getStreamName(k)).Result()
+	if err != nil {
+		return err
+	}
+	module.q.Delete(k)
+	return nil
+}
+
 func (module *RedisQueue) GetQueues() []string {
 	result := []string{}
-	for k, _ := range module.pubsub {
+	seen := map[string]bool{}
+	module.q.Range(func(key, value interface{}) bool {
+		k := util.ToString(key)
+		seen[k] = true
 		result = append(result, k)
+		return true
+	})
+
+	prefix := module.cfg.Prefix
+	var cursor uint64
+	for {
+		keys, next, err := module.client.ScanType(ctx, cursor, prefix+"*", 1000, "stream").Result()
+		if err != nil {
+			log.Debugf("failed to scan streams from redis: %v", err)
+			break
+		}
+		for _, v := range keys {
+			k := strings.TrimPrefix(v, prefix)
+			if !seen[k] {
+				seen[k] = true
+				result = append(result, k)
+			}
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
 	}
 	return result
 }
 
+func (module *RedisQueue) LatestOffset(k *queue.QueueConfig) queue.Offset {
+	msgs, err := module.client.XRevRangeN(ctx, module.getStreamName(k.ID), "+", "-", 1).Result()
+	if err != nil {
+		log.Error(k.Name, ", error on get offset:", err)
+		return queue.NewOffset(-1, -1)
+	}
+	if len(msgs) == 0 {
+		return queue.NewOffset(0, 0)
+	}
+	offset, err := nextOffsetOfStreamID(msgs[0].ID)
+	if err != nil {
+		log.Error(k.Name, ", invalid stream id:", msgs[0].ID, ", ", err)
+		return queue.NewOffset(-1, -1)
+	}
+	return offset
+}
+
+func isMissingGroupError(err error) bool {
+	return err != nil && util.ContainsAnyInArray(err.Error(), []string{"NOGROUP", "no such key"})
+}
+
+func (module *RedisQueue) GetOffset(k *queue.QueueConfig, consumer *queue.ConsumerConfig) (queue.Offset, error) {
+	stream := module.getStreamName(k.ID)
+
+	//the oldest unacknowledged message is where the group resumes
+	pending, err := module.client.XPending(ctx, stream, consumer.Group).Result()
+	if err != nil {
+		if isMissingGroupError(err) {
+			return queue.NewOffset(0, 0), nil
+		}
+		return queue.Offset{}, err
+	}
+	if pending.Count > 0 {
+		return offsetOfStreamID(pending.Lower)
+	}
+
+	info, err := module.getGroupInfo(stream, consumer.Group)
+	if err != nil {
+		if isMissingGroupError(err) {
+			return queue.NewOffset(0, 0), nil
+		}
+		return queue.Offset{}, err
+	}
+	if info == nil {
+		return queue.NewOffset(0, 0), nil
+	}
+	return offsetOfDeliveredID(util.ToString(info["last-delivered-id"]))
+}
+
+// getGroupInfo parses XINFO GROUPS manually, reply fields differ between redis versions
+func (module *RedisQueue) getGroupInfo(stream, group string) (map[string]interface{}, error) {
+	res, err := module.client.Do(ctx, "XINFO", "GROUPS", stream).Result()
+	if err != nil {
+		return nil, err
+	}
+	groups, ok := res.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("invalid reply for group info: %v", res)
+	}
+	for _, g := range groups {
+		fields, ok := g.([]interface{})
+		if !ok {
+			continue
+		}
+		info := map[string]interface{}{}
+		for i := 0; i+1 < len(fields); i += 2 {
+			info[util.ToString(fields[i])] = fields[i+1]
+		}
+		if util.ToString(info["name"]) == group {
+			return info, nil
+		}
+	}
+	return nil, nil
+}
+
+func (module *RedisQueue) createGroup(stream, group, start string) error {
+	err := module.client.XGroupCreateMkStream(ctx, stream, group, start).Err()
+	if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+func (module *RedisQueue) DeleteOffset(k *queue.QueueConfig, consumer *queue.ConsumerConfig) error {
+	err := module.client.XGroupDestroy(ctx, module.getStreamName(k.ID), consumer.Group).Err()
+	if isMissingGroupError(err) {
+		return nil
+	}
+	return err
+}
+
+func (module *RedisQueue) CommitOffset(k *queue.QueueConfig, consumer *queue.ConsumerConfig, offset queue.Offset) (bool, error) {
+
+	cor, ok := module.consumers.Load(getGroupKey(consumer.Group, k.ID))
+	if ok {
+		ins, ok := cor.(*Consumer)
+		if ok {
+			err := ins.CommitOffset(offset)
+			if err != nil {
+				return false, err
+			}
+			return true, nil
+		}
+	}
+
+	//no live consumer, move the group itself
+	stream := module.getStreamName(k.ID)
+	err := module.createGroup(stream, consumer.Group, "0")
+	if err != nil {
+		return false, err
+	}
+	err = module.ackPendingBefore(stream, consumer.Group, "", offset)
+	if err != nil {
+		return false, err
+	}
+	err = module.client.XGroupSetID(ctx, stream, consumer.Group, prevStreamID(offset)).Err()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ackPendingBefore acknowledges the pending messages older than the offset,
+// only the ones delivered to the consumer if it is not empty, otherwise all of the group
+func (module *RedisQueue) ackPendingBefore(stream, group, consumer string, offset queue.Offset) error {
+	end := prevStreamID(offset)
+	if end == "0" {
+		return nil
+	}
+	return module.ackPendingRange(stream, group, consumer, end)
+}
+
+func (module *RedisQueue) ackPendingRange(stream, group, consumer, end string) error {
+	for {
+		pending, err := module.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+			Stream:   stream,
+			Group:    group,
+			Start:    "-",
+			End:      end,
+			Count:    1000,
+			Consumer: consumer,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		ids := make([]string, 0, len(pending))
+		for _, v := range pending {
+			ids = append(ids, v.ID)
+		}
+		err = module.client.XAck(ctx, stream, group, ids...).Err()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (module *RedisQueue) AcquireConsumer(qconfig *queue.QueueConfig, consumer *queue.ConsumerConfig) (queue.ConsumerAPI, error) {
+	err := module.Init(qconfig.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	k := getGroupKey(consumer.Group, qconfig.ID)
+	ok, err := locker.Hold(queue.BucketWhoOwnsThisTopic, k, global.Env().SystemConfig.NodeConfig.ID, 60*time.Second, true)
+	if !ok || err != nil {
+		return nil, errors.Errorf("allocate consumer failed, consumer is already acquired by another node, key:%v err: %v", k, err)
+	}
+
+	start := "0"
+	if consumer.AutoResetOffset == "latest" {
+		start = "$"
+	}
+	stream := module.getStreamName(qconfig.ID)
+	err = module.createGroup(stream, consumer.Group, start)
+	if err != nil {
+		return nil, err
+	}
+
+	name := consumer.Name
+	if name == "" {
+		name = global.Env().SystemConfig.NodeConfig.ID
+	}
+
+	output := &Consumer{
+		qCfg:          qconfig,
+		cCfg:          consumer,
+		handler:       module,
+		stream:        stream,
+		name:          name,
+		pendingCursor: "0",
+	}
+	module.consumers.Store(k, output)
+
+	if global.Env().IsDebug {
+		log.Infof("acquired consumer:%v, %v, %v", qconfig.Name, consumer.Key(), consumer.ID)
+	}
+	return output, nil
+}
+
+func (module *RedisQueue) ReleaseConsumer(qconfig *queue.QueueConfig, consumer *queue.ConsumerConfig, instance queue.ConsumerAPI) error {
+	k := getGroupKey(consumer.Group, qconfig.ID)
+	module.consumers.Delete(k)
+	err := locker.Release(queue.BucketWhoOwnsThisTopic, k, global.Env().SystemConfig.NodeConfig.ID)
+	if err != nil {
+		return err
+	}
+	if instance != nil {
+		return instance.Close()
+	}
+	return nil
+}
+
+func (module *RedisQueue) AcquireProducer(cfg *queue.QueueConfig) (queue.ProducerAPI, error) {
+	v, ok := module.producers.Load(cfg.ID)
+	if ok {
+		f, ok := v.(queue.ProducerAPI)
+		if ok {
+			return f, nil
+		}
+	}
+
+	err := module.Init(cfg.ID)
+	if err != nil {
+		return nil, err
+	}
+	producer := &Producer{cfg: cfg, handler: module}
+	module.producers.Store(cfg.ID, producer)
+	return producer, nil
+}
+
+func (module *RedisQueue) ReleaseProducer(k *queue.QueueConfig, producer queue.ProducerAPI) error {
+	return nil
+}
+
 func (module *RedisModule) Start() error {
 	if !module.config.Enabled {
 		return nil
@@ -168,8 +552,13 @@ func (module *RedisModule) Start() error {
 		panic(err)
 	}
 
-	//handler:=&RedisQueue{client: module.client,pubsub: map[string]int{}}
-	//queue.Register("redis",handler)
+	if module.config.Queue.Enabled {
+		handler := NewRedisQueue(module.client, &module.config.Queue)
+		queue.Register("redis", handler)
+		if module.config.Queue.Default {
+			queue.RegisterDefaultHandler(handler)
+		}
+	}
 
 	return nil
 }
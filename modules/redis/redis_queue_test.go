@@ -0,0 +1,189 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package redis
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/rubyniu105/framework/core/kv"
+	"github.com/rubyniu105/framework/core/queue"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestQueue(t *testing.T) *RedisQueue {
+	s := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	return NewRedisQueue(client, &RedisQueueConfig{Prefix: "queue:"})
+}
+
+func TestStreamIDOffset(t *testing.T) {
+	offset, err := offsetOfStreamID("1700000000000-3")
+	assert.Nil(t, err)
+	assert.Equal(t, queue.NewOffset(1700000000000, 3), offset)
+	assert.Equal(t, "1700000000000-2", prevStreamID(offset))
+	assert.Equal(t, "1699999999999-18446744073709551615", prevStreamID(queue.NewOffset(1700000000000, 0)))
+	assert.Equal(t, "0", prevStreamID(queue.NewOffset(0, 0)))
+
+	offset, err = offsetOfDeliveredID("0-0")
+	assert.Nil(t, err)
+	assert.Equal(t, queue.NewOffset(0, 0), offset)
+
+	_, err = offsetOfStreamID("invalid")
+	assert.NotNil(t, err)
+}
+
+func init() {
+	kv.Register("memory", kv.NewMemoryStore())
+}
+
+func TestConsumerGroup(t *testing.T) {
+	handler := newTestQueue(t)
+	qCfg := &queue.QueueConfig{}
+	qCfg.ID = "test"
+	qCfg.Name = "test"
+	cCfg := queue.NewConsumerConfig(qCfg.ID, "group1", "consumer1")
+	cCfg.FetchMaxWaitMs = 100
+
+	assert.Equal(t, queue.NewOffset(0, 0), handler.LatestOffset(qCfg))
+
+	producer, err := handler.AcquireProducer(qCfg)
+	assert.Nil(t, err)
+	reqs := []queue.ProduceRequest{{Data: []byte("1")}, {Data: []byte("2"), Key: []byte("k2"), Headers: map[string]string{"trace_id": "t2"}}, {Data: []byte("3")}}
+	res, err := producer.Produce(&reqs)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(*res))
+	assert.Equal(t, int64(3), handler.Depth(qCfg.ID))
+	assert.Contains(t, handler.GetQueues(), "test")
+
+	latest := handler.LatestOffset(qCfg)
+	last := (*res)[2].Offset
+	assert.Equal(t, queue.NewOffset(last.Segment, last.Position+1), latest)
+
+	consumer, err := handler.AcquireConsumer(qCfg, cCfg)
+	assert.Nil(t, err)
+
+	ctx := &queue.Context{}
+	msgs, timeout, err := consumer.FetchMessages(ctx, 2)
+	assert.Nil(t, err)
+	assert.False(t, timeout)
+	assert.Equal(t, 2, len(msgs))
+	assert.Equal(t, "1", string(msgs[0].Data))
+	assert.Equal(t, "k2", string(msgs[1].Key))
+	assert.Equal(t, "t2", msgs[1].Headers["trace_id"])
+	assert.Equal(t, (*res)[0].Offset, ctx.InitOffset)
+	assert.Equal(t, msgs[1].NextOffset, ctx.NextOffset)
+
+	//nothing acknowledged yet, the group still starts from the first message
+	offset, err := handler.GetOffset(qCfg, cCfg)
+	assert.Nil(t, err)
+	assert.Equal(t, (*res)[0].Offset, offset)
+
+	ok, err := handler.CommitOffset(qCfg, cCfg, msgs[0].NextOffset)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	offset, err = handler.GetOffset(qCfg, cCfg)
+	assert.Nil(t, err)
+	assert.Equal(t, msgs[1].Offset, offset)
+
+	//a new consumer instance replays unacknowledged messages first
+	err = handler.ReleaseConsumer(qCfg, cCfg, consumer)
+	assert.Nil(t, err)
+	consumer, err = handler.AcquireConsumer(qCfg, cCfg)
+	assert.Nil(t, err)
+	msgs, _, err = consumer.FetchMessages(ctx, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(msgs))
+	assert.Equal(t, "2", string(msgs[0].Data))
+
+	msgs, _, err = consumer.FetchMessages(ctx, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(msgs))
+	assert.Equal(t, "3", string(msgs[0].Data))
+
+	err = consumer.CommitOffset(ctx.NextOffset)
+	assert.Nil(t, err)
+	offset, err = handler.GetOffset(qCfg, cCfg)
+	assert.Nil(t, err)
+	assert.Equal(t, latest, offset)
+
+	msgs, timeout, err = consumer.FetchMessages(ctx, 10)
+	assert.Nil(t, err)
+	assert.True(t, timeout)
+	assert.Equal(t, 0, len(msgs))
+
+	err = handler.DeleteOffset(qCfg, cCfg)
+	assert.Nil(t, err)
+	offset, err = handler.GetOffset(qCfg, cCfg)
+	assert.Nil(t, err)
+	assert.Equal(t, queue.NewOffset(0, 0), offset)
+}
+
+func TestMigrateLegacyList(t *testing.T) {
+	handler := newTestQueue(t)
+	assert.Nil(t, handler.client.LPush(ctx, "legacy", "1", "2").Err())
+
+	assert.Nil(t, handler.Push("legacy", []byte("3")))
+	assert.Equal(t, int64(0), handler.client.Exists(ctx, "legacy").Val())
+	assert.Equal(t, int64(3), handler.Depth("legacy"))
+	for _, v := range []string{"1", "2", "3"} {
+		data, timeout := handler.Pop("legacy", 0)
+		assert.False(t, timeout)
+		assert.Equal(t, v, string(data))
+	}
+}
+
+func TestAckPendingOfConsumer(t *testing.T) {
+	handler := newTestQueue(t)
+	qCfg := &queue.QueueConfig{}
+	qCfg.ID = "reset"
+	qCfg.Name = "reset"
+	cCfg := queue.NewConsumerConfig(qCfg.ID, "group1", "consumer1")
+	cCfg.FetchMaxWaitMs = 100
+	for _, v := range []string{"1", "2", "3"} {
+		assert.Nil(t, handler.Push(qCfg.ID, []byte(v)))
+	}
+
+	consumer, err := handler.AcquireConsumer(qCfg, cCfg)
+	assert.Nil(t, err)
+	msgs, _, err := consumer.FetchMessages(&queue.Context{}, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(msgs))
+
+	stream := handler.getStreamName(qCfg.ID)
+	_, err = handler.client.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "group1", Consumer: "other", Streams: []string{stream, ">"}, Count: 1}).Result()
+	assert.Nil(t, err)
+
+	//as done by ResetOffset before moving the group
+	assert.Nil(t, handler.ackPendingRange(stream, "group1", consumer.(*Consumer).name, "+"))
+	pending, err := handler.client.XPending(ctx, stream, "group1").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), pending.Count)
+	assert.Equal(t, int64(1), pending.Consumers["other"])
+}
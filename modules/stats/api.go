@@ -28,10 +28,12 @@
 package stats
 
 import (
-	"fmt"
+	"math"
 	"net/http"
 	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -89,20 +91,35 @@ func (handler SimpleStatsModule) PrometheusStatsAction(w http.ResponseWriter, re
 		return
 	}
 
+	globalLabels := stats.Labels{
+		"type": global.Env().GetAppLowercaseName(),
+		"ip":   global.Env().SystemConfig.NodeConfig.IP,
+		"name": global.Env().SystemConfig.NodeConfig.Name,
+		"id":   global.Env().SystemConfig.NodeConfig.ID,
+	}
+
 	kv := util.Flatten(metrics, false)
+	keys := make([]string, 0, len(kv))
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
 	buffer := bytebufferpool.Get("stats")
 	defer bytebufferpool.Put("stats", buffer)
-	for k, v := range kv {
-		buffer.Write(util.UnsafeStringToBytes(util.PrometheusMetricReplacer.Replace(k)))
-		buffer.Write(util.UnsafeStringToBytes(fmt.Sprintf("{type=\"%v\", ip=\"%v\", name=\"%v\", id=\"%v\"}",
-			global.Env().GetAppLowercaseName(),
-			global.Env().SystemConfig.NodeConfig.IP,
-			global.Env().SystemConfig.NodeConfig.Name,
-			global.Env().SystemConfig.NodeConfig.ID,
-		)))
-		buffer.Write(space)
-		buffer.Write(util.UnsafeStringToBytes(util.ToString(v)))
-		buffer.Write(newline)
+	seen := map[string]bool{}
+	for _, k := range keys {
+		name := util.PrometheusMetricReplacer.Replace(k)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		writeTypeLine(buffer, name, "untyped")
+		writeSample(buffer, name, globalLabels, nil, util.ToString(kv[k]))
+	}
+
+	for _, family := range stats.Collect() {
+		writeMetricFamily(buffer, family, globalLabels)
 	}
 	handler.WriteTextHeader(w)
 	handler.Write(w, buffer.Bytes())
@@ -110,6 +127,94 @@ func (handler SimpleStatsModule) PrometheusStatsAction(w http.ResponseWriter, re
 	handler.WriteHeader(w, 200)
 }
 
+func writeTypeLine(buffer *bytebufferpool.ByteBuffer, name string, typ string) {
+	buffer.WriteString("# TYPE ")
+	buffer.WriteString(name)
+	buffer.WriteString(" ")
+	buffer.WriteString(typ)
+	buffer.Write(newline)
+}
+
+var labelValueReplacer = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+// writeSample writes one sample line, the extra label (eg: le, quantile) always comes last
+func writeSample(buffer *bytebufferpool.ByteBuffer, name string, globalLabels, labels stats.Labels, value string, extra ...string) {
+	names := make([]string, 0, len(globalLabels)+len(labels))
+	for k := range globalLabels {
+		if _, ok := labels[k]; !ok {
+			names = append(names, k)
+		}
+	}
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names)+len(extra)/2)
+	for _, k := range names {
+		v, ok := labels[k]
+		if !ok {
+			v = globalLabels[k]
+		}
+		pairs = append(pairs, stats.MetricName(k, "")+"=\""+labelValueReplacer.Replace(v)+"\"")
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+"=\""+extra[i+1]+"\"")
+	}
+
+	buffer.WriteString(name)
+	if len(pairs) > 0 {
+		buffer.WriteString("{")
+		buffer.WriteString(strings.Join(pairs, ","))
+		buffer.WriteString("}")
+	}
+	buffer.WriteString(" ")
+	buffer.WriteString(value)
+	buffer.Write(newline)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func writeMetricFamily(buffer *bytebufferpool.ByteBuffer, family *stats.MetricFamily, globalLabels stats.Labels) {
+	if family.Help != "" {
+		buffer.WriteString("# HELP ")
+		buffer.WriteString(family.Name)
+		buffer.WriteString(" ")
+		buffer.WriteString(strings.ReplaceAll(strings.ReplaceAll(family.Help, "\\", "\\\\"), "\n", "\\n"))
+		buffer.Write(newline)
+	}
+	writeTypeLine(buffer, family.Name, string(family.Type))
+	for _, m := range family.Metrics {
+		switch family.Type {
+		case stats.HistogramType:
+			for _, b := range m.Buckets {
+				writeSample(buffer, family.Name+"_bucket", globalLabels, m.Labels, strconv.FormatUint(b.Count, 10), "le", formatFloat(b.UpperBound))
+			}
+			writeSample(buffer, family.Name+"_bucket", globalLabels, m.Labels, strconv.FormatUint(m.Count, 10), "le", "+Inf")
+			writeSample(buffer, family.Name+"_sum", globalLabels, m.Labels, formatFloat(m.Sum))
+			writeSample(buffer, family.Name+"_count", globalLabels, m.Labels, strconv.FormatUint(m.Count, 10))
+		case stats.SummaryType:
+			for _, q := range m.Quantiles {
+				writeSample(buffer, family.Name, globalLabels, m.Labels, formatFloat(q.Value), "quantile", formatFloat(q.Quantile))
+			}
+			writeSample(buffer, family.Name+"_sum", globalLabels, m.Labels, formatFloat(m.Sum))
+			writeSample(buffer, family.Name+"_count", globalLabels, m.Labels, strconv.FormatUint(m.Count, 10))
+		default:
+			writeSample(buffer, family.Name, globalLabels, m.Labels, formatFloat(m.Value))
+		}
+	}
+}
+
 func (handler SimpleStatsModule) GoroutinesAction(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	buf := make([]byte, 2<<20)
 	n := runtime.Stack(buf, true)
@@ -0,0 +1,87 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package stats
+
+import (
+	"testing"
+
+	"github.com/rubyniu105/framework/core/stats"
+	"github.com/rubyniu105/framework/lib/bytebufferpool"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWritePrometheusHistogram(t *testing.T) {
+	buffer := bytebufferpool.Get("stats_test")
+	defer bytebufferpool.Put("stats_test", buffer)
+
+	family := &stats.MetricFamily{
+		Name: "elasticsearch_bulk_latency_ms",
+		Help: "Latency of the bulk requests",
+		Type: stats.HistogramType,
+		Metrics: []*stats.Metric{{
+			Labels:  stats.Labels{"host": "es1:9200"},
+			Count:   3,
+			Sum:     12.5,
+			Buckets: []stats.Bucket{{UpperBound: 5, Count: 1}, {UpperBound: 10, Count: 2}},
+		}},
+	}
+	writeMetricFamily(buffer, family, stats.Labels{"id": "node\"1"})
+
+	expected := `# HELP elasticsearch_bulk_latency_ms Latency of the bulk requests
+# TYPE elasticsearch_bulk_latency_ms histogram
+elasticsearch_bulk_latency_ms_bucket{host="es1:9200",id="node\"1",le="5"} 1
+elasticsearch_bulk_latency_ms_bucket{host="es1:9200",id="node\"1",le="10"} 2
+elasticsearch_bulk_latency_ms_bucket{host="es1:9200",id="node\"1",le="+Inf"} 3
+elasticsearch_bulk_latency_ms_sum{host="es1:9200",id="node\"1"} 12.5
+elasticsearch_bulk_latency_ms_count{host="es1:9200",id="node\"1"} 3
+`
+	assert.Equal(t, expected, buffer.String())
+}
+
+func TestWritePrometheusSummary(t *testing.T) {
+	buffer := bytebufferpool.Get("stats_test")
+	defer bytebufferpool.Put("stats_test", buffer)
+
+	family := &stats.MetricFamily{
+		Name: "task_duration_ms",
+		Type: stats.SummaryType,
+		Metrics: []*stats.Metric{{
+			Count:     2,
+			Sum:       3,
+			Quantiles: []stats.Quantile{{Quantile: 0.99, Value: 2}},
+		}},
+	}
+	writeMetricFamily(buffer, family, nil)
+
+	expected := `# TYPE task_duration_ms summary
+task_duration_ms{quantile="0.99"} 2
+task_duration_ms_sum 3
+task_duration_ms_count 2
+`
+	assert.Equal(t, expected, buffer.String())
+}
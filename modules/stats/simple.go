@@ -86,8 +86,9 @@ func (module *SimpleStatsModule) Setup() {
 	}
 
 	module.data = &Stats{
-		raw: module.config.NoBuffer,
-		cfg: module.config,
+		Registry: stats.NewRegistry(),
+		raw:      module.config.NoBuffer,
+		cfg:      module.config,
 	}
 	module.initStats("simple")
 
@@ -98,17 +99,17 @@ func (module *SimpleStatsModule) Setup() {
 	stats.Register(module.data)
 
 	//register api
-	api.HandleAPIMethod(api.GET, "/stats", module.StatsAction)
-	api.HandleAPIMethod(api.GET, "/stats/prometheus", module.PrometheusStatsAction)
-	api.HandleAPIMethod(api.GET, "/debug/goroutines", module.GoroutinesAction)
+	api.HandleAPIMethod(api.GET, "/stats", module.StatsAction, api.RequirePermission("stats:read"))
+	api.HandleAPIMethod(api.GET, "/stats/prometheus", module.PrometheusStatsAction, api.RequirePermission("stats:read"))
+	api.HandleAPIMethod(api.GET, "/debug/goroutines", module.GoroutinesAction, api.RequirePermission("debug:read"))
 
 	//if global.Env().IsDebug{
-	api.HandleAPIMethod(api.GET, "/debug/pool/bytes", module.BufferItemStatsAction)
+	api.HandleAPIMethod(api.GET, "/debug/pool/bytes", module.BufferItemStatsAction, api.RequirePermission("debug:read"))
 	//}
 
-	api.HandleAPIMethod(api.GET, "/_local/files/_list", module.ListDirFs)
-	api.HandleAPIMethod(api.GET, "/_local/files/:file/_list", module.ListDirFs)
-	api.HandleAPIMethod(api.DELETE, "/_local/files/:file", module.DeleteDataFile)
+	api.HandleAPIMethod(api.GET, "/_local/files/_list", module.ListDirFs, api.RequirePermission("file:read"))
+	api.HandleAPIMethod(api.GET, "/_local/files/:file/_list", module.ListDirFs, api.RequirePermission("file:read"))
+	api.HandleAPIMethod(api.DELETE, "/_local/files/:file", module.DeleteDataFile, api.RequirePermission("file:delete"))
 }
 
 func (module *SimpleStatsModule) Start() error {
@@ -215,6 +216,8 @@ type Stats struct {
 	raw       bool
 	q         *queue.EsQueue
 	cfg       *SimpleStatsConfig
+
+	*stats.Registry `json:"-"` //labeled metrics, histograms and summaries, not persisted
 }
 
 func (s *Stats) initData(category, key string) {
@@ -282,7 +285,10 @@ func (s *Stats) DecrementBy(category, key string, value int64) {
 }
 
 func (s *Stats) Timing(category, key string, v int64) {
-
+	if s.closed {
+		return
+	}
+	s.Observe(category, key, nil, float64(v))
 }
 
 func (s *Stats) GetTimestamp(category, key string) (time.Time, error) {
@@ -24,10 +24,12 @@
 package task
 
 import (
+	log "github.com/cihub/seelog"
 	"github.com/rubyniu105/framework/core/api"
 	httprouter "github.com/rubyniu105/framework/core/api/router"
 	"github.com/rubyniu105/framework/core/env"
 	"github.com/rubyniu105/framework/core/global"
+	"github.com/rubyniu105/framework/core/orm"
 	"github.com/rubyniu105/framework/core/pipeline"
 	"github.com/rubyniu105/framework/core/task"
 	"github.com/rubyniu105/framework/core/util"
@@ -40,6 +42,10 @@ type TaskModule struct {
 	pool                    *pipeline.Pool
 	TimeZone                string `config:"time_zone" json:"time_zone,omitempty"`
 	MaxConcurrentNumOfTasks int    `config:"max_concurrent_tasks" json:"max_concurrent_tasks,omitempty"`
+	//record every execution of tasks through ORM, off by default as frequent tasks produce lots of records
+	HistoryEnabled bool `config:"history_enabled" json:"history_enabled,omitempty"`
+	//execution records older than this are deleted, default 7d
+	HistoryRetention string `config:"history_retention" json:"history_retention,omitempty"`
 }
 
 func (module *TaskModule) Name() string {
@@ -50,6 +56,7 @@ func (module *TaskModule) Setup() {
 
 	module.TimeZone = "UTC"
 	module.MaxConcurrentNumOfTasks = 100
+	module.HistoryRetention = "7d"
 	ok, err := env.ParseConfig("task", &module)
 	if ok && err != nil && global.Env().SystemConfig.Configs.PanicOnConfigError {
 		panic(err)
@@ -60,14 +67,31 @@ func (module *TaskModule) Setup() {
 		tz = time.UTC
 	}
 	module.pool, _ = pipeline.NewPoolWithTag("tasks", module.MaxConcurrentNumOfTasks)
+
+	task.EnableHistory(module.HistoryEnabled)
+	if module.HistoryEnabled {
+		orm.MustRegisterSchemaWithIndexName(task.Task{}, "task")
+		retention := util.GetDurationOrDefault(module.HistoryRetention, 7*24*time.Hour)
+		global.RegisterBackgroundCallback(&global.BackgroundTask{
+			Tag:      "cleanup task history",
+			Interval: time.Hour,
+			Func: func() {
+				if err := task.CleanupHistory(retention); err != nil {
+					log.Warn("failed to cleanup task history,", err)
+				}
+			},
+		})
+	}
 	global.RegisterShutdownCallback(func() {
 		pipeline.Release()
 	})
 
-	api.HandleAPIMethod(api.GET, "/tasks/", module.GetTaskList)
-	api.HandleAPIMethod(api.POST, "/task/:id/_start", module.StartTask)
-	api.HandleAPIMethod(api.POST, "/task/:id/_stop", module.StopTask)
-	api.HandleAPIMethod(api.DELETE, "/task/:id", module.DeleteTask)
+	api.HandleAPIMethod(api.GET, "/tasks/", module.GetTaskList, api.RequirePermission("task:read"))
+	api.HandleAPIMethod(api.POST, "/task/:id/_start", module.StartTask, api.RequirePermission("task:write"))
+	api.HandleAPIMethod(api.POST, "/task/:id/_stop", module.StopTask, api.RequirePermission("task:write"))
+	api.HandleAPIMethod(api.DELETE, "/task/:id", module.DeleteTask, api.RequirePermission("task:delete"))
+	api.HandleAPIMethod(api.GET, "/task/:id/_history", module.GetTaskHistory, api.RequirePermission("task:read"))
+	api.HandleAPIMethod(api.POST, "/task/:id/_cancel", module.CancelTask, api.RequirePermission("task:write"))
 
 }
 
@@ -103,3 +127,32 @@ func (module *TaskModule) DeleteTask(w http.ResponseWriter, req *http.Request, p
 	task.DeleteTask(ps.ByName("id"))
 	module.WriteAckOKJSON(w)
 }
+
+func (module *TaskModule) GetTaskHistory(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	id := ps.ByName("id")
+	from := module.GetIntOrDefault(req, "from", 0)
+	size := module.GetIntOrDefault(req, "size", 20)
+	history, total, err := task.GetHistory(id, from, size)
+	if err != nil {
+		module.WriteError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	module.WriteJSON(w, util.MapStr{
+		"running": task.GetRunningExecutions(id),
+		"total":   total,
+		"history": history,
+	}, 200)
+}
+
+func (module *TaskModule) CancelTask(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	canceled := task.CancelTask(ps.ByName("id"))
+	if canceled == 0 {
+		module.WriteAckJSON(w, false, 404, util.MapStr{
+			"error": "no running execution found",
+		})
+		return
+	}
+	module.WriteAckJSON(w, true, 200, util.MapStr{
+		"canceled": canceled,
+	})
+}
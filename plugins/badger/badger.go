@@ -28,6 +28,7 @@
 package badger
 
 import (
+	"bytes"
 	"errors"
 	"github.com/rubyniu105/framework/core/stats"
 	"path"
@@ -151,6 +152,8 @@ func (filter *Module) Close() error {
 				panic(err)
 			}
 		}
+		//closed db can't be reused by the next open
+		buckets.Delete(key)
 		return true
 	})
 	return nil
@@ -305,3 +308,120 @@ func (filter *Module) ExistsKey(bucket string, key []byte) (bool, error) {
 func (filter *Module) DeleteKey(bucket string, key []byte) error {
 	return filter.Delete(bucket, key)
 }
+
+func (filter *Module) AddValueWithTTL(bucket string, key []byte, value []byte, ttl time.Duration) error {
+	if filter.closed {
+		return errors.New("module closed")
+	}
+
+	stats.Increment("badger", bucket+"::add_ttl")
+
+	if filter.cfg.SingleBucketMode {
+		key = joinKey(bucket, key)
+	}
+
+	return filter.mustGetBucket(bucket).Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(newEntry(key, value, ttl))
+	})
+}
+
+func (filter *Module) PutIfAbsent(bucket string, key []byte, value []byte, ttl time.Duration) (bool, error) {
+	return filter.CompareAndSwap(bucket, key, nil, value, ttl)
+}
+
+func (filter *Module) CompareAndSwap(bucket string, key []byte, old, new []byte, ttl time.Duration) (bool, error) {
+	if filter.closed {
+		return false, errors.New("module closed")
+	}
+
+	stats.Increment("badger", bucket+"::cas")
+
+	if filter.cfg.SingleBucketMode {
+		key = joinKey(bucket, key)
+	}
+
+	var swapped bool
+	err := filter.mustGetBucket(bucket).Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil && err != badger.ErrKeyNotFound {
+			return err
+		}
+		if item == nil || err == badger.ErrKeyNotFound {
+			if old != nil {
+				return nil
+			}
+		} else {
+			if old == nil {
+				return nil
+			}
+			current, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(current, old) {
+				return nil
+			}
+		}
+
+		if new == nil {
+			err = txn.Delete(key)
+		} else {
+			err = txn.SetEntry(newEntry(key, new, ttl))
+		}
+		if err != nil {
+			return err
+		}
+		swapped = true
+		return nil
+	})
+
+	//lost the race to a concurrent writer
+	if err == badger.ErrConflict {
+		stats.Increment("badger", bucket+"::cas_conflict")
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return swapped, nil
+}
+
+func (filter *Module) ScanPrefix(bucket string, prefix []byte, fn func(key, value []byte) bool) error {
+	if filter.closed {
+		return errors.New("module closed")
+	}
+
+	stats.Increment("badger", bucket+"::scan")
+
+	var trim int
+	if filter.cfg.SingleBucketMode {
+		prefix = joinKey(bucket, prefix)
+		trim = len(bucket) + 1
+	}
+
+	return filter.mustGetBucket(bucket).View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if !fn(item.KeyCopy(nil)[trim:], value) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+func newEntry(key, value []byte, ttl time.Duration) *badger.Entry {
+	e := badger.NewEntry(key, value)
+	if ttl > 0 {
+		e = e.WithTTL(ttl)
+	}
+	return e
+}
@@ -0,0 +1,113 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package badger
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rubyniu105/framework/core/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestModule(t *testing.T) *Module {
+	dir := "/tmp/badger_kv_" + util.PickRandomName()
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	m := &Module{cfg: &Config{
+		Path:                    dir,
+		MemTableSize:            10 * 1024 * 1024,
+		ValueLogFileSize:        1<<30 - 1,
+		ValueThreshold:          1048576,
+		ValueLogMaxEntries:      1000000,
+		NumMemtables:            1,
+		NumLevelZeroTables:      1,
+		NumLevelZeroTablesStall: 2,
+		SingleBucketMode:        true,
+	}}
+	assert.Nil(t, m.Open())
+	t.Cleanup(func() {
+		m.Close()
+	})
+	return m
+}
+
+func TestBadgerCompareAndSwap(t *testing.T) {
+	m := newTestModule(t)
+	key := []byte("lock")
+
+	ok, err := m.PutIfAbsent("cas", key, []byte("a"), 0)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, _ = m.PutIfAbsent("cas", key, []byte("b"), 0)
+	assert.False(t, ok)
+
+	ok, _ = m.CompareAndSwap("cas", key, []byte("b"), []byte("c"), 0)
+	assert.False(t, ok)
+
+	ok, _ = m.CompareAndSwap("cas", key, []byte("a"), []byte("c"), 0)
+	assert.True(t, ok)
+	v, _ := m.GetValue("cas", key)
+	assert.Equal(t, "c", string(v))
+
+	ok, _ = m.CompareAndSwap("cas", key, []byte("c"), nil, 0)
+	assert.True(t, ok)
+	exists, _ := m.ExistsKey("cas", key)
+	assert.False(t, exists)
+}
+
+func TestBadgerTTLAndScan(t *testing.T) {
+	m := newTestModule(t)
+
+	assert.Nil(t, m.AddValueWithTTL("ttl", []byte("k"), []byte("v"), time.Second))
+	v, _ := m.GetValue("ttl", []byte("k"))
+	assert.Equal(t, "v", string(v))
+	time.Sleep(1100 * time.Millisecond)
+	v, _ = m.GetValue("ttl", []byte("k"))
+	assert.Nil(t, v)
+
+	ok, _ := m.PutIfAbsent("ttl", []byte("k"), []byte("v2"), 0)
+	assert.True(t, ok)
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, m.AddValue("scan", []byte(fmt.Sprintf("a/%v", i)), []byte("x")))
+	}
+	assert.Nil(t, m.AddValue("scan", []byte("b/0"), []byte("x")))
+	assert.Nil(t, m.AddValue("scan_other", []byte("a/9"), []byte("x")))
+
+	var keys []string
+	assert.Nil(t, m.ScanPrefix("scan", []byte("a/"), func(key, value []byte) bool {
+		keys = append(keys, string(key))
+		return true
+	}))
+	assert.Equal(t, []string{"a/0", "a/1", "a/2"}, keys)
+}
@@ -34,6 +34,7 @@ import (
 	"github.com/rubyniu105/framework/core/global"
 	"github.com/rubyniu105/framework/core/kv"
 	"github.com/rubyniu105/framework/core/module"
+	"github.com/rubyniu105/framework/core/orm"
 	"path"
 )
 
@@ -56,6 +57,8 @@ type Config struct {
 	ValueLogGCEnabled           bool    `config:"value_log_gc_enabled"`
 	ValueLogDiscardRatio        float64 `config:"value_log_gc_discard_ratio"`
 	ValueLogGCIntervalInSeconds int     `config:"value_log_gc_interval_in_seconds"`
+
+	ORM ORMConfig `config:"orm"`
 }
 
 type Module struct {
@@ -95,6 +98,10 @@ func (module *Module) Setup() {
 	if module.cfg.Enabled {
 		filter.Register("badger", module)
 		kv.Register("badger", module)
+
+		if module.cfg.ORM.Enabled {
+			orm.Register("badger", NewBadgerORM(module, &module.cfg.ORM))
+		}
 	}
 
 }
@@ -106,7 +113,13 @@ func (module *Module) Start() error {
 
 	if module.cfg.Enabled {
 		module.closed = false
-		return module.Open()
+		err := module.Open()
+		if err != nil {
+			return err
+		}
+		if module.cfg.ORM.Enabled {
+			return orm.InitSchema()
+		}
 	}
 
 	return nil
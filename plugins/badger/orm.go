@@ -0,0 +1,455 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package badger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/orm"
+	"github.com/rubyniu105/framework/core/stats"
+	"github.com/rubyniu105/framework/core/util"
+)
+
+var ErrNotFound = errors.New("record not found")
+
+const ormBucket = "orm"
+
+type ORMConfig struct {
+	Enabled     bool   `config:"enabled"`
+	IndexPrefix string `config:"index_prefix"`
+}
+
+// BadgerORM stores objects as json documents in badger, queries are evaluated in memory
+type BadgerORM struct {
+	module     *Module
+	cfg        *ORMConfig
+	indexNames sync.Map
+}
+
+func NewBadgerORM(module *Module, cfg *ORMConfig) *BadgerORM {
+	return &BadgerORM{module: module, cfg: cfg}
+}
+
+func (handler *BadgerORM) RegisterSchemaWithIndexName(t interface{}, indexName string) error {
+	pkg, name := util.GetTypeAndPackageName(t, true)
+	handler.indexNames.Store(fmt.Sprintf("%s-%s", pkg, name), indexName)
+	return nil
+}
+
+func (handler *BadgerORM) GetIndexName(o interface{}) string {
+	pkg, name := util.GetTypeAndPackageName(o, true)
+	indexName := name
+	v, ok := handler.indexNames.Load(fmt.Sprintf("%s-%s", pkg, name))
+	if ok {
+		indexName = v.(string)
+	}
+	return handler.cfg.IndexPrefix + indexName
+}
+
+func (handler *BadgerORM) GetWildcardIndexName(o interface{}) string {
+	return fmt.Sprintf("%v*", handler.GetIndexName(o))
+}
+
+func getObjectID(o interface{}) string {
+	return util.GetFieldValueByTagName(o, "elastic_meta", "_id")
+}
+
+func (handler *BadgerORM) getKey(indexName, id string) []byte {
+	key := []byte(indexName + "/" + id)
+	if handler.module.cfg.SingleBucketMode {
+		key = joinKey(ormBucket, key)
+	}
+	return key
+}
+
+// getScanPrefix returns the key prefix for an index name, trailing `*` matches all indices with the same prefix
+func (handler *BadgerORM) getScanPrefix(indexName string) []byte {
+	if strings.HasSuffix(indexName, "*") {
+		indexName = strings.TrimSuffix(indexName, "*")
+	} else {
+		indexName = indexName + "/"
+	}
+	key := []byte(indexName)
+	if handler.module.cfg.SingleBucketMode {
+		key = joinKey(ormBucket, key)
+	}
+	return key
+}
+
+func (handler *BadgerORM) Save(ctx *orm.Context, o interface{}) error {
+	id := getObjectID(o)
+	if id == "" {
+		return errors.Errorf("id was not found in object: %v", o)
+	}
+	stats.Increment("badger", ormBucket+"::save")
+	return handler.module.mustGetBucket(ormBucket).Update(func(txn *badger.Txn) error {
+		return txn.Set(handler.getKey(handler.GetIndexName(o), id), util.MustToJSONBytes(o))
+	})
+}
+
+// Update merges the object into the stored document
+func (handler *BadgerORM) Update(ctx *orm.Context, o interface{}) error {
+	id := getObjectID(o)
+	if id == "" {
+		return errors.Errorf("id was not found in object: %v", o)
+	}
+	stats.Increment("badger", ormBucket+"::update")
+	key := handler.getKey(handler.GetIndexName(o), id)
+	return handler.module.mustGetBucket(ormBucket).Update(func(txn *badger.Txn) error {
+		doc := util.MapStr{}
+		item, err := txn.Get(key)
+		if err == nil {
+			err = item.Value(func(val []byte) error {
+				return util.FromJSONBytes(val, &doc)
+			})
+			if err != nil {
+				return err
+			}
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+		patch := util.MapStr{}
+		err = util.FromJSONBytes(util.MustToJSONBytes(o), &patch)
+		if err != nil {
+			return err
+		}
+		doc.DeepUpdate(patch)
+		return txn.Set(key, util.MustToJSONBytes(doc))
+	})
+}
+
+func (handler *BadgerORM) Delete(ctx *orm.Context, o interface{}) error {
+	id := getObjectID(o)
+	if id == "" {
+		return errors.Errorf("id was not found in object: %v", o)
+	}
+	stats.Increment("badger", ormBucket+"::delete")
+	return handler.module.mustGetBucket(ormBucket).Update(func(txn *badger.Txn) error {
+		return txn.Delete(handler.getKey(handler.GetIndexName(o), id))
+	})
+}
+
+func (handler *BadgerORM) Get(o interface{}) (bool, error) {
+	id := getObjectID(o)
+	if id == "" {
+		return false, errors.Errorf("id was not found in object: %v", o)
+	}
+	stats.Increment("badger", ormBucket+"::get")
+
+	var data []byte
+	err := handler.module.mustGetBucket(ormBucket).View(func(txn *badger.Txn) error {
+		item, err := txn.Get(handler.getKey(handler.GetIndexName(o), id))
+		if err != nil {
+			return err
+		}
+		data, err = item.ValueCopy(nil)
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		return false, ErrNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	err = util.FromJSONBytes(data, o)
+	return true, err
+}
+
+func (handler *BadgerORM) GetBy(field string, value interface{}, t interface{}) (error, orm.Result) {
+	query := orm.Query{}
+	query.Conds = orm.And(orm.Eq(field, value))
+	return handler.Search(t, &query)
+}
+
+type document struct {
+	key    []byte
+	id     string
+	source util.MapStr
+}
+
+// scan walks through all documents of the index, stop walking when the callback returns false
+func (handler *BadgerORM) scan(indexName string, fn func(doc *document) bool) error {
+	prefix := handler.getScanPrefix(indexName)
+	return handler.module.mustGetBucket(ormBucket).View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			doc := &document{key: item.KeyCopy(nil), source: util.MapStr{}}
+			err := item.Value(func(val []byte) error {
+				return util.FromJSONBytes(val, &doc.source)
+			})
+			if err != nil {
+				return err
+			}
+			k := string(doc.key)
+			doc.id = k[strings.LastIndex(k, "/")+1:]
+			if !fn(doc) {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+func (handler *BadgerORM) getQueryIndexName(o interface{}, q *orm.Query) string {
+	if q != nil && q.IndexName != "" {
+		return q.IndexName
+	}
+	if q != nil && q.WildcardIndex {
+		return handler.GetWildcardIndexName(o)
+	}
+	return handler.GetIndexName(o)
+}
+
+// parseQuery accepts *orm.Query, orm.Query or []*orm.Cond, raw query dsl is not supported
+func parseQuery(query interface{}) (*orm.Query, error) {
+	switch v := query.(type) {
+	case nil:
+		return &orm.Query{}, nil
+	case *orm.Query:
+		if v == nil {
+			return &orm.Query{}, nil
+		}
+		return v, nil
+	case orm.Query:
+		return &v, nil
+	case []*orm.Cond:
+		return &orm.Query{Conds: v}, nil
+	case []byte:
+		return nil, errors.New("raw query is not supported by badger orm, use *orm.Query instead")
+	}
+	return nil, errors.Errorf("unsupported query type: %T", query)
+}
+
+// parseRequiredQuery is used by DeleteBy and UpdateBy, an empty query matches all the documents
+func parseRequiredQuery(query interface{}) (*orm.Query, error) {
+	q, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(q.Conds) == 0 && len(q.RawQuery) == 0 && q.TemplatedQuery == nil {
+		return nil, errors.New("query is required")
+	}
+	return q, nil
+}
+
+func (handler *BadgerORM) match(o interface{}, q *orm.Query) ([]*document, error) {
+	if len(q.RawQuery) > 0 || q.TemplatedQuery != nil {
+		return nil, errors.New("raw and templated query are not supported by badger orm")
+	}
+
+	docs := []*document{}
+	collapsed := map[string]bool{}
+	err := handler.scan(handler.getQueryIndexName(o, q), func(doc *document) bool {
+		if !matchConds(doc.source, q.Conds) {
+			return true
+		}
+		docs = append(docs, doc)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if q.Sort != nil && len(*q.Sort) > 0 {
+		sorts := *q.Sort
+		sort.SliceStable(docs, func(i, j int) bool {
+			for _, s := range sorts {
+				c := compareField(docs[i].source, docs[j].source, s.Field, s.SortType == orm.DESC)
+				if c == 0 {
+					continue
+				}
+				return c < 0
+			}
+			return false
+		})
+	}
+
+	if q.CollapseField != "" {
+		result := []*document{}
+		for _, doc := range docs {
+			v, ok := getFieldValue(doc.source, q.CollapseField)
+			k := fmt.Sprint(v)
+			if ok && collapsed[k] {
+				continue
+			}
+			collapsed[k] = true
+			result = append(result, doc)
+		}
+		docs = result
+	}
+	return docs, nil
+}
+
+func (handler *BadgerORM) Search(o interface{}, q *orm.Query) (error, orm.Result) {
+	result := orm.Result{}
+	if q == nil {
+		q = &orm.Query{}
+	}
+	stats.Increment("badger", ormBucket+"::search")
+
+	docs, err := handler.match(o, q)
+	if err != nil {
+		return err, result
+	}
+
+	result.Total = int64(len(docs))
+	from := q.From
+	if from > len(docs) {
+		from = len(docs)
+	}
+	end := from + q.Size
+	if end > len(docs) {
+		end = len(docs)
+	}
+
+	//keep the same response layout as elasticsearch, raw result is parsed by some callers
+	hits := []util.MapStr{}
+	for _, doc := range docs[from:end] {
+		if _, ok := doc.source["id"]; !ok {
+			doc.source["id"] = doc.id
+		}
+		result.Result = append(result.Result, map[string]interface{}(doc.source))
+		hits = append(hits, util.MapStr{"_id": doc.id, "_source": doc.source})
+	}
+	result.Raw = util.MustToJSONBytes(util.MapStr{
+		"hits": util.MapStr{
+			"total": util.MapStr{"value": result.Total, "relation": "eq"},
+			"hits":  hits,
+		},
+	})
+	return nil, result
+}
+
+func (handler *BadgerORM) Count(o interface{}, query interface{}) (int64, error) {
+	q, err := parseQuery(query)
+	if err != nil {
+		return 0, err
+	}
+	docs, err := handler.match(o, q)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(docs)), nil
+}
+
+func (handler *BadgerORM) DeleteBy(o interface{}, query interface{}) error {
+	q, err := parseRequiredQuery(query)
+	if err != nil {
+		return err
+	}
+	docs, err := handler.match(o, q)
+	if err != nil {
+		return err
+	}
+	stats.IncrementBy("badger", ormBucket+"::delete_by", int64(len(docs)))
+	return handler.module.mustGetBucket(ormBucket).Update(func(txn *badger.Txn) error {
+		for _, doc := range docs {
+			err := txn.Delete(doc.key)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UpdateBy only accepts *orm.UpdateByRequest, fields in the doc can be dotted paths
+func (handler *BadgerORM) UpdateBy(o interface{}, query interface{}) error {
+	req, ok := query.(*orm.UpdateByRequest)
+	if !ok || req == nil {
+		return errors.Errorf("unsupported query type: %T, use *orm.UpdateByRequest instead", query)
+	}
+	q, err := parseRequiredQuery(req.Query)
+	if err != nil {
+		return err
+	}
+	docs, err := handler.match(o, q)
+	if err != nil {
+		return err
+	}
+	stats.IncrementBy("badger", ormBucket+"::update_by", int64(len(docs)))
+	return handler.module.mustGetBucket(ormBucket).Update(func(txn *badger.Txn) error {
+		for _, doc := range docs {
+			for k, v := range req.Doc {
+				_, err := doc.source.Put(k, v)
+				if err != nil {
+					return err
+				}
+			}
+			err := txn.Set(doc.key, util.MustToJSONBytes(doc.source))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GroupBy counts documents having selectField for each value of groupField,
+// only documents with haveQuery equals to haveValue are counted when haveQuery is set
+func (handler *BadgerORM) GroupBy(o interface{}, selectField, groupField string, haveQuery string, haveValue interface{}) (error, map[string]interface{}) {
+	q := &orm.Query{}
+	if haveQuery != "" {
+		q.Conds = orm.And(orm.Eq(haveQuery, haveValue))
+	}
+	docs, err := handler.match(o, q)
+	if err != nil {
+		return err, nil
+	}
+
+	counts := map[string]int64{}
+	for _, doc := range docs {
+		if selectField != "" {
+			if v, ok := getFieldValue(doc.source, selectField); !ok || v == nil {
+				continue
+			}
+		}
+		v, ok := getFieldValue(doc.source, groupField)
+		if !ok {
+			continue
+		}
+		for _, k := range toValueArray(v) {
+			counts[fmt.Sprint(k)]++
+		}
+	}
+
+	result := map[string]interface{}{}
+	for k, v := range counts {
+		result[k] = v
+	}
+	return nil, result
+}
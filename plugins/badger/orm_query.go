@@ -0,0 +1,265 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package badger
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rubyniu105/framework/core/orm"
+	"github.com/rubyniu105/framework/core/util"
+)
+
+// matchConds follows the bool query of elasticsearch,
+// should clauses are only required when there is no must clause
+func matchConds(doc util.MapStr, conds []*orm.Cond) bool {
+	hasMust := false
+	hasShould := false
+	shouldMatched := false
+	for _, c := range conds {
+		switch c.BoolType {
+		case orm.MustNot:
+			if matchCond(doc, c) {
+				return false
+			}
+		case orm.Should:
+			hasShould = true
+			if !shouldMatched && matchCond(doc, c) {
+				shouldMatched = true
+			}
+		default:
+			hasMust = true
+			if !matchCond(doc, c) {
+				return false
+			}
+		}
+	}
+	if hasShould && !hasMust {
+		return shouldMatched
+	}
+	return true
+}
+
+func matchCond(doc util.MapStr, c *orm.Cond) bool {
+	v, ok := getFieldValue(doc, c.Field)
+	if !ok {
+		return false
+	}
+	values := toValueArray(v)
+
+	switch c.QueryType {
+	case orm.Terms, orm.StringTerms:
+		for _, expected := range toValueArray(normalize(c.Value)) {
+			if containsValue(values, expected) {
+				return true
+			}
+		}
+		return false
+	case orm.RangeGt, orm.RangeGte, orm.RangeLt, orm.RangeLte:
+		expected := normalize(c.Value)
+		for _, x := range values {
+			r, ok := compareValue(x, expected)
+			if !ok {
+				continue
+			}
+			switch c.QueryType {
+			case orm.RangeGt:
+				ok = r > 0
+			case orm.RangeGte:
+				ok = r >= 0
+			case orm.RangeLt:
+				ok = r < 0
+			case orm.RangeLte:
+				ok = r <= 0
+			}
+			if ok {
+				return true
+			}
+		}
+		return false
+	case orm.Prefix:
+		prefix := util.ToString(c.Value)
+		for _, x := range values {
+			if strings.HasPrefix(util.ToString(x), prefix) {
+				return true
+			}
+		}
+		return false
+	case orm.Wildcard, orm.Regexp:
+		pattern := util.ToString(c.Value)
+		if c.QueryType == orm.Wildcard {
+			pattern = strings.ReplaceAll(strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*"), `\?`, ".")
+		}
+		re, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return false
+		}
+		for _, x := range values {
+			if re.MatchString(util.ToString(x)) {
+				return true
+			}
+		}
+		return false
+	}
+	return containsValue(values, normalize(c.Value))
+}
+
+// getFieldValue supports dotted path, and `.keyword` sub fields map to the field itself
+func getFieldValue(doc util.MapStr, field string) (interface{}, bool) {
+	v, err := doc.GetValue(field)
+	if err == nil {
+		return v, true
+	}
+	if strings.HasSuffix(field, ".keyword") {
+		return getFieldValue(doc, strings.TrimSuffix(field, ".keyword"))
+	}
+	return nil, false
+}
+
+func toValueArray(v interface{}) []interface{} {
+	switch x := v.(type) {
+	case []interface{}:
+		return x
+	case []string:
+		arr := make([]interface{}, 0, len(x))
+		for _, s := range x {
+			arr = append(arr, s)
+		}
+		return arr
+	}
+	return []interface{}{v}
+}
+
+// normalize converts query values to the same types as decoded json documents
+func normalize(v interface{}) interface{} {
+	switch x := v.(type) {
+	case nil, string, float64, bool:
+		return x
+	case []string, []interface{}:
+		arr := []interface{}{}
+		for _, i := range toValueArray(x) {
+			arr = append(arr, normalize(i))
+		}
+		return arr
+	}
+	var out interface{}
+	err := util.FromJSONBytes(util.MustToJSONBytes(v), &out)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return out
+}
+
+func containsValue(values []interface{}, expected interface{}) bool {
+	for _, x := range values {
+		if r, ok := compareValue(x, expected); ok && r == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case string:
+		f, err := strconv.ParseFloat(x, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toTime(v interface{}) (time.Time, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	return t, err == nil
+}
+
+// compareValue compares numbers, dates and strings, returns false if they are not comparable
+func compareValue(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+
+	_, aNum := a.(float64)
+	_, bNum := b.(float64)
+	if aNum || bNum {
+		x, ok1 := toFloat(a)
+		y, ok2 := toFloat(b)
+		if ok1 && ok2 {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+
+	if x, ok := toTime(a); ok {
+		if y, ok := toTime(b); ok {
+			switch {
+			case x.Before(y):
+				return -1, true
+			case x.After(y):
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+
+	return strings.Compare(util.ToString(a), util.ToString(b)), true
+}
+
+// compareField orders documents by field, documents missing the field are always the last
+func compareField(a, b util.MapStr, field string, desc bool) int {
+	x, ok1 := getFieldValue(a, field)
+	y, ok2 := getFieldValue(b, field)
+	if !ok1 || x == nil {
+		if !ok2 || y == nil {
+			return 0
+		}
+		return 1
+	}
+	if !ok2 || y == nil {
+		return -1
+	}
+	r, _ := compareValue(x, y)
+	if desc {
+		return -r
+	}
+	return r
+}
@@ -0,0 +1,160 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package badger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rubyniu105/framework/core/orm"
+	"github.com/rubyniu105/framework/core/util"
+	"github.com/stretchr/testify/assert"
+)
+
+type testObject struct {
+	orm.ORMObjectBase
+	Name   string      `json:"name"`
+	Age    int         `json:"age"`
+	Tags   []string    `json:"tags,omitempty"`
+	Labels util.MapStr `json:"labels,omitempty"`
+}
+
+func newTestORM(t *testing.T) *BadgerORM {
+	m := newTestModule(t)
+	handler := NewBadgerORM(m, &ORMConfig{IndexPrefix: ".test-"})
+	assert.Nil(t, handler.RegisterSchemaWithIndexName(testObject{}, "object"))
+	return handler
+}
+
+func TestBadgerORM(t *testing.T) {
+	handler := newTestORM(t)
+	assert.Equal(t, ".test-object", handler.GetIndexName(&testObject{}))
+
+	now := time.Now()
+	for i, name := range []string{"a", "b", "c", "d"} {
+		o := &testObject{Name: name, Age: 10 * (i + 1), Labels: util.MapStr{"env": "prod"}}
+		o.ID = name
+		o.Created = &now
+		if i%2 == 0 {
+			o.Tags = []string{"even"}
+			o.Labels["env"] = "dev"
+		}
+		assert.Nil(t, handler.Save(nil, o))
+	}
+
+	o := &testObject{}
+	o.ID = "b"
+	exists, err := handler.Get(o)
+	assert.True(t, exists)
+	assert.Nil(t, err)
+	assert.Equal(t, 20, o.Age)
+
+	o.ID = "x"
+	exists, err = handler.Get(o)
+	assert.False(t, exists)
+	assert.Equal(t, ErrNotFound, err)
+
+	err, res := handler.GetBy("name", "c", testObject{})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), res.Total)
+
+	q := &orm.Query{Size: 10}
+	q.Conds = orm.Combine(orm.And(orm.Gt("age", 10), orm.Le("age", 40)), []*orm.Cond{orm.NotEq("labels.env", "dev")})
+	q.AddSort("age", orm.DESC)
+	err, res = handler.Search(&testObject{}, q)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), res.Total)
+	assert.Equal(t, "d", res.Result[0].(map[string]interface{})["name"])
+	assert.Equal(t, "b", res.Result[1].(map[string]interface{})["name"])
+
+	q = &orm.Query{From: 1, Size: 1}
+	q.Conds = orm.Or(orm.Eq("tags", "even"), orm.In("name", []interface{}{"d"}))
+	q.AddSort("name", orm.ASC)
+	err, res = handler.Search(&testObject{}, q)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), res.Total)
+	assert.Equal(t, 1, len(res.Result))
+	assert.Equal(t, "c", res.Result[0].(map[string]interface{})["name"])
+
+	count, err := handler.Count(&testObject{}, orm.And(orm.InStringArray("labels.env.keyword", []string{"dev"})))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+
+	err, groups := handler.GroupBy(&testObject{}, "tags", "labels.env", "", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"dev": int64(2)}, groups)
+
+	err = handler.UpdateBy(&testObject{}, &orm.UpdateByRequest{
+		Query: &orm.Query{Conds: orm.And(orm.Eq("labels.env", "prod"))},
+		Doc:   util.MapStr{"labels.env": "staging"},
+	})
+	assert.Nil(t, err)
+	count, err = handler.Count(&testObject{}, orm.And(orm.Eq("labels.env", "staging")))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+
+	//update merges into the stored document
+	o = &testObject{Name: "b2"}
+	o.ID = "b"
+	assert.Nil(t, handler.Update(nil, o))
+	o = &testObject{}
+	o.ID = "b"
+	_, err = handler.Get(o)
+	assert.Nil(t, err)
+	assert.Equal(t, "b2", o.Name)
+	assert.Equal(t, "staging", o.Labels["env"])
+
+	err = handler.DeleteBy(&testObject{}, orm.And(orm.Lt("age", 30)))
+	assert.Nil(t, err)
+	count, err = handler.Count(&testObject{}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+
+	_, err = handler.Count(&testObject{}, []byte(`{"query":{"match_all":{}}}`))
+	assert.NotNil(t, err)
+}
+
+func TestBulkWriteRequiresQuery(t *testing.T) {
+	handler := newTestORM(t)
+	for _, name := range []string{"a", "b"} {
+		o := &testObject{Name: name}
+		o.ID = name
+		assert.Nil(t, handler.Save(nil, o))
+	}
+
+	for _, query := range []interface{}{nil, (*orm.Query)(nil), &orm.Query{}, orm.Query{Size: 10}, []*orm.Cond{}} {
+		assert.NotNil(t, handler.DeleteBy(&testObject{}, query))
+	}
+	for _, query := range []*orm.Query{nil, {}, {Size: 10}} {
+		assert.NotNil(t, handler.UpdateBy(&testObject{}, &orm.UpdateByRequest{Query: query, Doc: util.MapStr{"age": 1}}))
+	}
+
+	count, err := handler.Count(&testObject{}, orm.And(orm.Eq("age", 0)))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+}
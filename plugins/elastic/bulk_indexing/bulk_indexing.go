@@ -610,6 +610,8 @@ func (processor *BulkIndexingProcessor) NewSlicedBulkWorker(ctx *pipeline.Contex
 	defer queue.ReleaseConsumer(qConfig, consumerConfig, consumerInstance)
 
 	var skipFinalDocsProcess bool
+	//someone else took over the consumer, the buffered messages are left to the new owner
+	var fenced bool
 
 	defer func() {
 		if !global.Env().IsDebug {
@@ -634,6 +636,11 @@ func (processor *BulkIndexingProcessor) NewSlicedBulkWorker(ctx *pipeline.Contex
 			return
 		}
 
+		if fenced {
+			mainBuf.ResetData()
+			return
+		}
+
 		//cleanup buffer before exit worker
 		//log.Info("start final submit:",qConfig.ID,",",esClusterID,",msg count:",mainBuf.GetMessageCount(),", ",committedOffset," vs ",offset )
 		if mainBuf.GetMessageCount() > 0 {
@@ -794,6 +801,15 @@ READ_DOCS:
 		}
 
 		consumerConfig.KeepActive()
+		//extend the lease, stop consuming if someone else took over
+		if err := queue.RenewConsumer(qConfig, consumerConfig); err != nil {
+			if err == queue.ErrConsumerFenced {
+				log.Warnf("slice_worker, consumer of queue:[%v], slice_id:%v was taken over by others, quit", qConfig.Name, sliceID)
+				fenced = true
+				return
+			}
+			panic(err)
+		}
 		messages, timeout, err := consumerInstance.FetchMessages(ctx1, consumerConfig.FetchMaxMessages)
 		stats.IncrementBy("queue", qConfig.ID+".msg_fetched_from_queue", int64(len(messages)))
 
@@ -989,6 +1005,17 @@ CLEAN_BUFFER:
 	lastCommit = time.Now()
 	// check bulk result, if ok, then commit offset, or retry non-200 requests, or save failure offset
 	if mainBuf.GetMessageCount() > 0 {
+		//the bulk requests may be retried here for a long time, keep the lease alive
+		consumerConfig.KeepActive()
+		if err := queue.RenewConsumer(qConfig, consumerConfig); err != nil {
+			if err == queue.ErrConsumerFenced {
+				log.Warnf("slice_worker, consumer of queue:[%v], slice_id:%v was taken over by others, quit", qConfig.Name, sliceID)
+				fenced = true
+				return
+			}
+			panic(err)
+		}
+
 		continueNext, err := processor.submitBulkRequest(ctx, qConfig, tag, esClusterID, meta, host, bulkProcessor, mainBuf)
 		if global.Env().IsDebug {
 			log.Tracef("slice_worker, [%v][%v][%v][%v] submit request:%v,continue:%v,err:%v", qConfig.Name, consumerConfig.Group, consumerConfig.Name, sliceID, mainBuf.GetMessageCount(), continueNext, err)
@@ -85,10 +85,16 @@ type Config struct {
 	WaitingAfter           []string `config:"waiting_after"`
 	RetryDelayIntervalInMs int      `config:"retry_delay_interval"`
 	AutoCommitOffset       bool     `config:"auto_commit_offset"`
+
+	MaxRetryTimes             int    `config:"max_retry_times"` //-1 means retry forever
+	MaxRetryDelayIntervalInMs int    `config:"max_retry_delay_interval"`
+	DeadLetterQueue           string `config:"dead_letter_queue"` //messages still failed after retries will be moved to this queue
 }
 
 const name = "consumer"
 
+const defaultMaxRetryTimesWithDeadLetter = 3
+
 func init() {
 	pipeline.RegisterProcessorPlugin(name, New)
 }
@@ -125,6 +131,9 @@ func New(c *config.Config) (pipeline.Processor, error) {
 		SkipEmptyQueue:         false,
 		QuitOnEOFQueue:         true,
 		RetryDelayIntervalInMs: 5000,
+
+		MaxRetryTimes:             -1,
+		MaxRetryDelayIntervalInMs: 60000,
 	}
 
 	if err := c.Unpack(&cfg); err != nil {
@@ -132,6 +141,11 @@ func New(c *config.Config) (pipeline.Processor, error) {
 		return nil, fmt.Errorf("failed to unpack the configuration of flow_runner processor: %s", err)
 	}
 
+	//retry forever by default, unless the failed messages can be moved to the dead letter queue
+	if cfg.DeadLetterQueue != "" && !c.HasField("max_retry_times") {
+		cfg.MaxRetryTimes = defaultMaxRetryTimesWithDeadLetter
+	}
+
 	if len(cfg.QueueLabels) > 0 {
 		for k, v := range cfg.QueueLabels {
 			cfg.Selector.Labels[k] = v
@@ -472,6 +486,9 @@ func (processor *QueueConsumerProcessor) NewSlicedWorker(ctx *pipeline.Context,
 	xxHash := xxHashPool.Get().(*xxhash.XXHash32)
 	defer xxHashPool.Put(xxHash)
 
+	//someone else took over the consumer, the offset must not be committed
+	var fenced bool
+
 	defer func() {
 		defer log.Debugf("exit worker[%v], queue:[%v], slice_id:%v", workerID, qConfig.ID, sliceID)
 		if !global.Env().IsDebug {
@@ -493,7 +510,7 @@ func (processor *QueueConsumerProcessor) NewSlicedWorker(ctx *pipeline.Context,
 			}
 		}
 
-		if parentContext != nil && (parentContext.IsFailed()) || ctx.IsFailed() {
+		if fenced || parentContext != nil && (parentContext.IsFailed()) || ctx.IsFailed() {
 			return
 		}
 
@@ -582,6 +599,15 @@ READ_DOCS:
 			log.Tracef("slice_worker, worker:[%v] start consume queue:[%v][%v] offset:%v", workerID, qConfig.ID, sliceID, offset)
 		}
 		consumerConfig.KeepActive()
+		//extend the lease, stop consuming if someone else took over
+		if err := queue.RenewConsumer(qConfig, consumerConfig); err != nil {
+			if err == queue.ErrConsumerFenced {
+				log.Warnf("slice_worker, consumer of queue:[%v], slice_id:%v was taken over by others, quit", qConfig.Name, sliceID)
+				fenced = true
+				return
+			}
+			panic(err)
+		}
 		messages, timeout, err := consumerInstance.FetchMessages(ctx1, consumerConfig.FetchMaxMessages)
 		if global.Env().IsDebug {
 			log.Infof("[%v] slice_worker, [%v][%v] consume message:%v,ctx:%v,timeout:%v,err:%v", qConfig.Name, consumerConfig.Name, sliceID, len(messages), ctx1.String(), timeout, err)
@@ -625,37 +651,20 @@ READ_DOCS:
 
 		if len(messages) > 0 {
 
-			newCtx := pipeline.Context{}
-			newCtx.ParentContext = ctx
-			newCtx.Context = ctx.Context
-			newCtx.Data = ctx.CloneData()
-
-			_, err := newCtx.PutValue(processor.config.QueueField, qConfig.Name)
-			if err != nil {
-				panic(err)
-			}
-
-			_, err = newCtx.PutValue("QUEUE_CONFIG", qConfig)
-			if err != nil {
-				panic(err)
-			}
-
-			_, err = newCtx.PutValue("CONSUMER_CONFIG", consumerConfig)
-			if err != nil {
-				panic(err)
-			}
-
-			_, err = newCtx.PutValue(processor.config.MessageField, messages)
-			if err != nil {
-				panic(err)
-			}
-
 			//log.Error("start processing message:",len(messages),",",qConfig.Name)
-			err = processor.processors.Process(&newCtx)
+			ok, err := processor.processMessages(ctx, qConfig, consumerConfig, messages)
 			//log.Error("end processing message:",len(messages),",",qConfig.Name,",",err)
+			if err == queue.ErrConsumerFenced {
+				log.Warnf("slice_worker, consumer of queue:[%v], slice_id:%v was taken over by others while processing, quit", qConfig.Name, sliceID)
+				fenced = true
+				return
+			}
 			if err != nil {
 				panic(err)
 			}
+			if !ok {
+				goto CLEAN_BUFFER
+			}
 			offset = ctx1.NextOffset //TODO
 			messages = nil
 		} else {
@@ -690,6 +699,11 @@ CLEAN_BUFFER:
 	if processor.config.AutoCommitOffset {
 		if !offset.Equals(initOffset) {
 			ok, err := queue.CommitOffset(qConfig, consumerConfig, offset)
+			if err == queue.ErrConsumerFenced {
+				log.Warnf("slice_worker, consumer of queue:[%v], slice_id:%v was taken over by others, skip commit offset [%v]", qConfig.Name, sliceID, offset)
+				fenced = true
+				return
+			}
 			if !ok || err != nil {
 				panic(err)
 			}
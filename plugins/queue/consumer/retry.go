@@ -0,0 +1,253 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package consumer
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	log "github.com/cihub/seelog"
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/pipeline"
+	"github.com/rubyniu105/framework/core/queue"
+	"github.com/rubyniu105/framework/core/stats"
+	"github.com/rubyniu105/framework/core/util"
+)
+
+// failure details are added to the headers of the dead letter message, key and data are kept as they are, so it can be replayed unchanged
+const (
+	HeaderOriginalQueue  = "x-original-queue"
+	HeaderOriginalOffset = "x-original-offset"
+	HeaderConsumerGroup  = "x-consumer-group"
+	HeaderRetryCount     = "x-retry-count"
+	HeaderErrorReason    = "x-error-reason"
+	HeaderFailedAt       = "x-failed-at"
+)
+
+// retryBackoff returns the delay before the given retry attempt, starts from retry_delay_interval and doubles each time
+func (processor *QueueConsumerProcessor) retryBackoff(attempt int) time.Duration {
+	delay := time.Duration(processor.config.RetryDelayIntervalInMs) * time.Millisecond
+	maxDelay := time.Duration(processor.config.MaxRetryDelayIntervalInMs) * time.Millisecond
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if maxDelay > 0 && delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	if maxDelay > 0 && delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
+
+// the lease is renewed while waiting for the retries, RenewConsumer throttles the actual writes
+const leaseRenewInterval = 5 * time.Second
+
+// renewConsumer extends the lease, only returns queue.ErrConsumerFenced, other errors are logged
+// as the lease may still be valid and is renewed again later
+func renewConsumer(qConfig *queue.QueueConfig, consumerConfig *queue.ConsumerConfig) error {
+	consumerConfig.KeepActive()
+	err := queue.RenewConsumer(qConfig, consumerConfig)
+	if err != nil && err != queue.ErrConsumerFenced {
+		log.Warnf("queue:[%v], failed to renew the lease of consumer [%v]: %v", qConfig.Name, consumerConfig.Key(), err)
+		return nil
+	}
+	return err
+}
+
+// waitForRetry waits for the backoff and keeps the lease alive, the retries may last longer than consume_timeout,
+// returns false if canceled, or queue.ErrConsumerFenced if someone else took over the consumer
+func (processor *QueueConsumerProcessor) waitForRetry(ctx *pipeline.Context, qConfig *queue.QueueConfig, consumerConfig *queue.ConsumerConfig, attempt int) (bool, error) {
+	timer := time.NewTimer(processor.retryBackoff(attempt))
+	defer timer.Stop()
+	ticker := time.NewTicker(leaseRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false, nil
+		case <-ticker.C:
+			if err := renewConsumer(qConfig, consumerConfig); err != nil {
+				return false, err
+			}
+		case <-timer.C:
+			if err := renewConsumer(qConfig, consumerConfig); err != nil {
+				return false, err
+			}
+			return true, nil
+		}
+	}
+}
+
+// processMessages runs the message processors with retries, the messages failed after max_retry_times will
+// be processed one by one, and pushed to the dead_letter_queue if they still fail.
+// returns false if the context was canceled before the messages get handled, or with queue.ErrConsumerFenced
+// if someone else took over the consumer, the messages are left to the new owner
+func (processor *QueueConsumerProcessor) processMessages(ctx *pipeline.Context, qConfig *queue.QueueConfig, consumerConfig *queue.ConsumerConfig, messages []queue.Message) (bool, error) {
+	attempts, err := processor.processWithRetry(ctx, qConfig, consumerConfig, messages, processor.config.MaxRetryTimes)
+	if err == nil {
+		return true, nil
+	}
+	if err == queue.ErrConsumerFenced {
+		return false, err
+	}
+	if attempts < 0 {
+		return false, nil
+	}
+
+	if processor.config.DeadLetterQueue == "" {
+		return false, errors.Errorf("queue:[%v], failed to process %v messages after %v attempts: %v", qConfig.Name, len(messages), attempts, err)
+	}
+
+	//isolate the bad messages
+	if len(messages) == 1 {
+		return true, processor.pushToDeadLetterQueue(qConfig, consumerConfig, messages[0], attempts, err)
+	}
+
+	for _, msg := range messages {
+		n, err := processor.processWithRetry(ctx, qConfig, consumerConfig, []queue.Message{msg}, 0)
+		if err == nil {
+			continue
+		}
+		if err == queue.ErrConsumerFenced {
+			return false, err
+		}
+		if n < 0 {
+			return false, nil
+		}
+		err = processor.pushToDeadLetterQueue(qConfig, consumerConfig, msg, attempts+n, err)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// processWithRetry returns the number of attempts and the last error, attempts is -1 if canceled,
+// the error is queue.ErrConsumerFenced if the consumer was taken over between the attempts
+func (processor *QueueConsumerProcessor) processWithRetry(ctx *pipeline.Context, qConfig *queue.QueueConfig, consumerConfig *queue.ConsumerConfig, messages []queue.Message, maxRetryTimes int) (int, error) {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			stats.Increment("consumer", qConfig.ID, consumerConfig.Group, "retried")
+			log.Warnf("queue:[%v], retry processing %v messages from offset [%v], attempt: %v, error: %v", qConfig.Name, len(messages), messages[0].Offset, attempt, err)
+			ok, fenced := processor.waitForRetry(ctx, qConfig, consumerConfig, attempt)
+			if fenced != nil {
+				return attempt, fenced
+			}
+			if !ok {
+				return -1, err
+			}
+		}
+		if ctx.IsCanceled() {
+			return -1, errors.New("context canceled")
+		}
+		err = processor.runProcessors(ctx, qConfig, consumerConfig, messages)
+		if err == nil {
+			return attempt + 1, nil
+		}
+		if maxRetryTimes >= 0 && attempt >= maxRetryTimes {
+			return attempt + 1, err
+		}
+	}
+}
+
+func (processor *QueueConsumerProcessor) runProcessors(ctx *pipeline.Context, qConfig *queue.QueueConfig, consumerConfig *queue.ConsumerConfig, messages []queue.Message) (err error) {
+	newCtx := pipeline.Context{}
+	newCtx.ParentContext = ctx
+	newCtx.Context = ctx.Context
+	newCtx.Data = ctx.CloneData()
+
+	defer func() {
+		if r := recover(); r != nil {
+			var v string
+			switch r.(type) {
+			case error:
+				v = r.(error).Error()
+			case runtime.Error:
+				v = r.(runtime.Error).Error()
+			case string:
+				v = r.(string)
+			default:
+				v = fmt.Sprint(r)
+			}
+			err = errors.New(v)
+		}
+	}()
+
+	_, err = newCtx.PutValue(processor.config.QueueField, qConfig.Name)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = newCtx.PutValue("QUEUE_CONFIG", qConfig)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = newCtx.PutValue("CONSUMER_CONFIG", consumerConfig)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = newCtx.PutValue(processor.config.MessageField, messages)
+	if err != nil {
+		panic(err)
+	}
+
+	err = processor.processors.Process(&newCtx)
+	if err != nil {
+		return err
+	}
+	if newCtx.IsFailed() {
+		return errors.Errorf("message processors failed: %v", newCtx.Errors())
+	}
+	return nil
+}
+
+func (processor *QueueConsumerProcessor) pushToDeadLetterQueue(qConfig *queue.QueueConfig, consumerConfig *queue.ConsumerConfig, msg queue.Message, retries int, reason error) error {
+	headers := make(map[string]string, len(msg.Headers)+6)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[HeaderOriginalQueue] = qConfig.Name
+	headers[HeaderOriginalOffset] = msg.Offset.String()
+	headers[HeaderConsumerGroup] = consumerConfig.Group
+	headers[HeaderRetryCount] = util.IntToString(retries)
+	headers[HeaderErrorReason] = reason.Error()
+	headers[HeaderFailedAt] = time.Now().Format(time.RFC3339)
+
+	log.Errorf("queue:[%v], message at offset [%v] failed after %v attempts, moved to dead letter queue [%v]: %v", qConfig.Name, msg.Offset, retries, processor.config.DeadLetterQueue, reason)
+	dlqConfig := queue.GetOrInitConfig(processor.config.DeadLetterQueue)
+	producer, err := queue.AcquireProducer(dlqConfig)
+	if err == nil {
+		_, err = producer.Produce(&[]queue.ProduceRequest{{Topic: dlqConfig.ID, Key: msg.Key, Headers: headers, Data: msg.Data}})
+	}
+	if err != nil {
+		return errors.Errorf("failed to push message to dead letter queue [%v]: %v", processor.config.DeadLetterQueue, err)
+	}
+	stats.Increment("consumer", qConfig.ID, consumerConfig.Group, "dead_lettered")
+	return nil
+}
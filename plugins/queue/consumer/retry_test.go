@@ -0,0 +1,259 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+package consumer
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rubyniu105/framework/core/config"
+	"github.com/rubyniu105/framework/core/errors"
+	"github.com/rubyniu105/framework/core/kv"
+	"github.com/rubyniu105/framework/core/locker"
+	"github.com/rubyniu105/framework/core/pipeline"
+	"github.com/rubyniu105/framework/core/queue"
+	"github.com/rubyniu105/framework/core/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	kv.Register("memory", kv.NewMemoryStore())
+}
+
+type memoryQueue struct {
+	sync.Mutex
+	data map[string][]queue.ProduceRequest
+}
+
+func (q *memoryQueue) Name() string                   { return "memory" }
+func (q *memoryQueue) Init(string) error              { return nil }
+func (q *memoryQueue) Close(string) error             { return nil }
+func (q *memoryQueue) GetStorageSize(k string) uint64 { return 0 }
+func (q *memoryQueue) Destroy(string) error           { return nil }
+func (q *memoryQueue) GetQueues() []string            { return nil }
+func (q *memoryQueue) Push(k string, v []byte) error {
+	return q.produce(queue.ProduceRequest{Topic: k, Data: v})
+}
+func (q *memoryQueue) produce(req queue.ProduceRequest) error {
+	q.Lock()
+	defer q.Unlock()
+	q.data[req.Topic] = append(q.data[req.Topic], req)
+	return nil
+}
+func (q *memoryQueue) LatestOffset(*queue.QueueConfig) queue.Offset { return queue.Offset{} }
+func (q *memoryQueue) GetOffset(*queue.QueueConfig, *queue.ConsumerConfig) (queue.Offset, error) {
+	return queue.Offset{}, nil
+}
+func (q *memoryQueue) DeleteOffset(*queue.QueueConfig, *queue.ConsumerConfig) error { return nil }
+func (q *memoryQueue) CommitOffset(*queue.QueueConfig, *queue.ConsumerConfig, queue.Offset) (bool, error) {
+	return true, nil
+}
+func (q *memoryQueue) AcquireConsumer(*queue.QueueConfig, *queue.ConsumerConfig) (queue.ConsumerAPI, error) {
+	return &memoryConsumer{}, nil
+}
+func (q *memoryQueue) ReleaseConsumer(*queue.QueueConfig, *queue.ConsumerConfig, queue.ConsumerAPI) error {
+	return nil
+}
+func (q *memoryQueue) AcquireProducer(*queue.QueueConfig) (queue.ProducerAPI, error) {
+	return &memoryProducer{q: q}, nil
+}
+func (q *memoryQueue) ReleaseProducer(*queue.QueueConfig, queue.ProducerAPI) error { return nil }
+
+type memoryProducer struct {
+	q *memoryQueue
+}
+
+func (p *memoryProducer) Close() error { return nil }
+func (p *memoryProducer) Produce(reqs *[]queue.ProduceRequest) (*[]queue.ProduceResponse, error) {
+	for _, req := range *reqs {
+		p.q.produce(req)
+	}
+	return &[]queue.ProduceResponse{}, nil
+}
+
+type memoryConsumer struct{}
+
+func (c *memoryConsumer) Close() error                             { return nil }
+func (c *memoryConsumer) ResetOffset(segment, readPos int64) error { return nil }
+func (c *memoryConsumer) CommitOffset(offset queue.Offset) error   { return nil }
+func (c *memoryConsumer) FetchMessages(ctx *queue.Context, numOfMessages int) ([]queue.Message, bool, error) {
+	return nil, true, nil
+}
+
+// failingProcessor fails the messages listed in bad, or the first n calls
+type failingProcessor struct {
+	calls    int
+	failures int
+	bad      map[string]bool
+}
+
+func (p *failingProcessor) Name() string {
+	return "failing"
+}
+
+func (p *failingProcessor) Process(ctx *pipeline.Context) error {
+	p.calls++
+	if p.calls <= p.failures {
+		return errors.New("temporary failure")
+	}
+	v, _ := ctx.GetValue("messages")
+	for _, m := range v.([]queue.Message) {
+		if p.bad[string(m.Data)] {
+			return errors.Errorf("bad message: %v", string(m.Data))
+		}
+	}
+	return nil
+}
+
+func newTestProcessor(p pipeline.Processor, dlq string) *QueueConsumerProcessor {
+	return &QueueConsumerProcessor{
+		config: &Config{
+			QueueField:                "queue_name",
+			MessageField:              "messages",
+			RetryDelayIntervalInMs:    1,
+			MaxRetryDelayIntervalInMs: 5,
+			MaxRetryTimes:             2,
+			DeadLetterQueue:           dlq,
+		},
+		processors: &pipeline.Processors{SkipCatchError: true, List: []pipeline.Processor{p}},
+	}
+}
+
+func testMessages(data ...string) []queue.Message {
+	msgs := []queue.Message{}
+	for i, v := range data {
+		msgs = append(msgs, queue.Message{Offset: queue.NewOffset(0, int64(i)), NextOffset: queue.NewOffset(0, int64(i+1)), Data: []byte(v),
+			Key: []byte("key-" + v), Headers: map[string]string{"trace_id": "t" + util.IntToString(i)}})
+	}
+	return msgs
+}
+
+func TestRetryBackoff(t *testing.T) {
+	processor := QueueConsumerProcessor{config: &Config{RetryDelayIntervalInMs: 100, MaxRetryDelayIntervalInMs: 1000}}
+	assert.Equal(t, 100*time.Millisecond, processor.retryBackoff(1))
+	assert.Equal(t, 200*time.Millisecond, processor.retryBackoff(2))
+	assert.Equal(t, 800*time.Millisecond, processor.retryBackoff(4))
+	assert.Equal(t, time.Second, processor.retryBackoff(5))
+	assert.Equal(t, time.Second, processor.retryBackoff(50))
+}
+
+func TestProcessMessagesWithDeadLetter(t *testing.T) {
+	q := &memoryQueue{data: map[string][]queue.ProduceRequest{}}
+	queue.RegisterDefaultHandler(q)
+
+	ctx := pipeline.AcquireContext(pipeline.PipelineConfigV2{})
+	qCfg := &queue.QueueConfig{ID: "test_queue", Name: "test_queue"}
+	cCfg := &queue.ConsumerConfig{Group: "group-001", Name: "consumer-001"}
+
+	//recovered after retries
+	p := &failingProcessor{failures: 2}
+	ok, err := newTestProcessor(p, "").processMessages(ctx, qCfg, cCfg, testMessages("a", "b"))
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, p.calls)
+
+	//no dead letter queue, fail the worker
+	p = &failingProcessor{bad: map[string]bool{"b": true}}
+	ok, err = newTestProcessor(p, "").processMessages(ctx, qCfg, cCfg, testMessages("a", "b"))
+	assert.False(t, ok)
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, p.calls)
+
+	//only the bad message goes to the dead letter queue
+	p = &failingProcessor{bad: map[string]bool{"b": true}}
+	ok, err = newTestProcessor(p, "test_dlq").processMessages(ctx, qCfg, cCfg, testMessages("a", "b", "c"))
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, p.calls)
+
+	dlqCfg := queue.GetOrInitConfig("test_dlq")
+	assert.Equal(t, 1, len(q.data[dlqCfg.ID]))
+	msg := q.data[dlqCfg.ID][0]
+	assert.Equal(t, "b", string(msg.Data))
+	assert.Equal(t, "key-b", string(msg.Key))
+	assert.Equal(t, "t1", msg.Headers["trace_id"])
+	assert.Equal(t, "test_queue", msg.Headers[HeaderOriginalQueue])
+	assert.Equal(t, "0,1", msg.Headers[HeaderOriginalOffset])
+	assert.Equal(t, "4", msg.Headers[HeaderRetryCount])
+	assert.Equal(t, "bad message: b", msg.Headers[HeaderErrorReason])
+
+	//canceled while waiting for retry
+	p = &failingProcessor{failures: 100}
+	processor := newTestProcessor(p, "test_dlq")
+	processor.config.RetryDelayIntervalInMs = 10000
+	processor.config.MaxRetryDelayIntervalInMs = 10000
+	ctx.CancelTask()
+	ok, err = processor.processMessages(ctx, qCfg, cCfg, testMessages("a"))
+	assert.False(t, ok)
+	assert.Nil(t, err)
+}
+
+func TestProcessMessagesFenced(t *testing.T) {
+	q := &memoryQueue{data: map[string][]queue.ProduceRequest{}}
+	queue.RegisterDefaultHandler(q)
+
+	ctx := pipeline.AcquireContext(pipeline.PipelineConfigV2{})
+	qCfg := &queue.QueueConfig{ID: "fenced_queue", Name: "fenced_queue"}
+	cCfg := &queue.ConsumerConfig{Group: "group-001", Name: "consumer-001", ConsumeTimeoutInSeconds: 1}
+	_, err := queue.AcquireConsumer(qCfg, cCfg, "worker-a")
+	assert.Nil(t, err)
+	defer queue.ReleaseConsumer(qCfg, cCfg, nil)
+
+	//someone else took over while retrying
+	fightingKey := qCfg.ID + cCfg.Key()
+	_, info, _ := locker.GetAllocateInfo(queue.BucketConsumersInFighting, fightingKey)
+	ok, _ := locker.Revoke(info)
+	assert.True(t, ok)
+	ok, _, _ = locker.HoldWithToken(queue.BucketConsumersInFighting, fightingKey, "worker-b", time.Minute, false)
+	assert.True(t, ok)
+
+	//retry forever, but quit when fenced, the messages are not moved to the dead letter queue
+	p := &failingProcessor{failures: 100}
+	processor := newTestProcessor(p, "fenced_dlq")
+	processor.config.MaxRetryTimes = -1
+	processor.config.RetryDelayIntervalInMs = 500
+	processor.config.MaxRetryDelayIntervalInMs = 500
+	ok, err = processor.processMessages(ctx, qCfg, cCfg, testMessages("a"))
+	assert.False(t, ok)
+	assert.Equal(t, queue.ErrConsumerFenced, err)
+	assert.Equal(t, 1, p.calls)
+	assert.Equal(t, 0, len(q.data[queue.GetOrInitConfig("fenced_dlq").ID]))
+}
+
+func TestDefaultMaxRetryTimes(t *testing.T) {
+	newProcessor := func(cfg map[string]interface{}) *QueueConsumerProcessor {
+		c, err := config.NewConfigFrom(cfg)
+		assert.Nil(t, err)
+		p, err := New(c)
+		assert.Nil(t, err)
+		return p.(*QueueConsumerProcessor)
+	}
+
+	//retry forever without the dead letter queue
+	assert.Equal(t, -1, newProcessor(map[string]interface{}{}).config.MaxRetryTimes)
+	assert.Equal(t, defaultMaxRetryTimesWithDeadLetter, newProcessor(map[string]interface{}{"dead_letter_queue": "dlq"}).config.MaxRetryTimes)
+	assert.Equal(t, 5, newProcessor(map[string]interface{}{"dead_letter_queue": "dlq", "max_retry_times": 5}).config.MaxRetryTimes)
+	assert.Equal(t, 2, newProcessor(map[string]interface{}{"max_retry_times": 2}).config.MaxRetryTimes)
+}
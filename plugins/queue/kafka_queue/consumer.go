@@ -141,7 +141,7 @@ func (this *Consumer) FetchMessages(ctx *queue.Context, numOfMessages int) (mess
 			}
 			nextOffset = nextOffsetStr
 			size := len(r.Value)
-			m := queue.Message{Offset: offsetStr, NextOffset: nextOffsetStr, Data: r.Value, Size: size, Timestamp: r.Timestamp.Unix()}
+			m := queue.Message{Offset: offsetStr, NextOffset: nextOffsetStr, Data: r.Value, Size: size, Timestamp: r.Timestamp.Unix(), Key: r.Key, Headers: fromRecordHeaders(r.Headers)}
 			msgs = append(msgs, m)
 			ctx.MessageCount++
 			byteSize += size
@@ -57,8 +57,13 @@ func (p *Producer) Produce(reqs *[]queue.ProduceRequest) (*[]queue.ProduceRespon
 			msg.Topic = p.cfg.ID
 		}
 		msg.Timestamp = time.Now()
-		msg.Key = util.UnsafeStringToBytes(util.GetUUID())
+		if len(req.Key) > 0 {
+			msg.Key = req.Key
+		} else {
+			msg.Key = util.UnsafeStringToBytes(util.GetUUID())
+		}
 		msg.Value = req.Data
+		msg.Headers = toRecordHeaders(req.Headers)
 		messages = append(messages, msg)
 	}
 
@@ -87,3 +92,26 @@ func (p *Producer) Produce(reqs *[]queue.ProduceRequest) (*[]queue.ProduceRespon
 func (p *Producer) Close() error {
 	return nil
 }
+
+func toRecordHeaders(headers map[string]string) []kgo.RecordHeader {
+	if len(headers) == 0 {
+		return nil
+	}
+	result := make([]kgo.RecordHeader, 0, len(headers))
+	for k, v := range headers {
+		result = append(result, kgo.RecordHeader{Key: k, Value: []byte(v)})
+	}
+	return result
+}
+
+// fromRecordHeaders converts kafka record headers, the last one wins for duplicated keys
+func fromRecordHeaders(headers []kgo.RecordHeader) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(headers))
+	for _, h := range headers {
+		result[h.Key] = string(h.Value)
+	}
+	return result
+}
@@ -36,6 +36,9 @@ import (
 	"github.com/rubyniu105/framework/core/util"
 	"io/ioutil"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -43,6 +46,7 @@ import (
 // KVStore represents a simple key-value store.
 type KVStore struct {
 	data     map[string][]byte
+	expires  map[string]int64 //unix nano
 	wal      *WAL
 	mu       sync.Mutex
 	filename string
@@ -50,7 +54,8 @@ type KVStore struct {
 
 // LastState represents the last state of the key-value store.
 type LastState struct {
-	Data map[string][]byte `json:"data"`
+	Data    map[string][]byte `json:"data"`
+	Expires map[string]int64  `json:"expires,omitempty"`
 }
 
 // WAL represents a Write-Ahead Log for storing key-value changes.
@@ -64,6 +69,7 @@ type WAL struct {
 func NewKVStore(lastStateFilename, walFilename string) *KVStore {
 	kv := &KVStore{
 		data:     make(map[string][]byte),
+		expires:  make(map[string]int64),
 		wal:      &WAL{filename: walFilename},
 		filename: lastStateFilename,
 	}
@@ -95,28 +101,105 @@ func (wal *WAL) Close() {
 }
 
 func (kv *KVStore) Set(key string, value []byte) error {
+	return kv.SetWithTTL(key, value, 0)
+}
+
+// SetWithTTL stores a key-value pair which expires after ttl, ttl <= 0 means never expire.
+func (kv *KVStore) SetWithTTL(key string, value []byte, ttl time.Duration) error {
 	//log.Error("set key: ", key, " value: ", string(value))
 
 	kv.mu.Lock()
 	defer kv.mu.Unlock()
 
-	kv.data[key] = value
-	if err := kv.wal.writeEntry(key, value); err != nil {
-		return err
-	}
-	return nil
+	return kv.set(key, value, ttl)
 }
 
 // Delete removes a key-value pair from the store and writes to the WAL synchronously.
 func (kv *KVStore) Delete(key string) error {
 	kv.mu.Lock()
 	defer kv.mu.Unlock()
+
+	return kv.delete(key)
+}
+
+// CompareAndSwap replaces the value of key only if the current value equals old,
+// nil old means the key must be absent, nil new deletes the key.
+func (kv *KVStore) CompareAndSwap(key string, old, new []byte, ttl time.Duration) (bool, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	current, ok := kv.get(key)
+	if ok != (old != nil) || (ok && !bytes.Equal(current, old)) {
+		return false, nil
+	}
+
+	var err error
+	if new == nil {
+		err = kv.delete(key)
+	} else {
+		err = kv.set(key, new, ttl)
+	}
+	return err == nil, err
+}
+
+// Scan iterates over unexpired keys with the prefix in lexical order.
+func (kv *KVStore) Scan(prefix string, fn func(key string, value []byte) bool) {
+	kv.mu.Lock()
+	keys := []string{}
+	for k := range kv.data {
+		if strings.HasPrefix(k, prefix) && !kv.expired(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	values := make([][]byte, len(keys))
+	for i, k := range keys {
+		values[i] = append([]byte{}, kv.data[k]...)
+	}
+	kv.mu.Unlock()
+
+	for i, k := range keys {
+		if !fn(k, values[i]) {
+			return
+		}
+	}
+}
+
+func (kv *KVStore) set(key string, value []byte, ttl time.Duration) error {
+	var expireAt int64
+	if ttl > 0 {
+		expireAt = time.Now().Add(ttl).UnixNano()
+		kv.expires[key] = expireAt
+	} else {
+		delete(kv.expires, key)
+	}
+	kv.data[key] = value
+	return kv.wal.writeEntry(key, value, expireAt)
+}
+
+func (kv *KVStore) delete(key string) error {
 	delete(kv.data, key)
+	delete(kv.expires, key)
+	return kv.wal.writeEntry(key, []byte(""), 0)
+}
+
+func (kv *KVStore) expired(key string) bool {
+	expireAt, ok := kv.expires[key]
+	return ok && time.Now().UnixNano() >= expireAt
+}
 
-	if err := kv.wal.writeEntry(key, []byte("")); err != nil {
-		return err
+// get returns the value, expired keys are purged lazily.
+func (kv *KVStore) get(key string) ([]byte, bool) {
+	v, ok := kv.data[key]
+	if !ok {
+		return nil, false
 	}
-	return nil
+	if kv.expired(key) {
+		delete(kv.data, key)
+		delete(kv.expires, key)
+		return nil, false
+	}
+	return v, true
 }
 
 // Load the current state from the last state file.
@@ -135,6 +218,12 @@ func (kv *KVStore) loadFromLastState() {
 		kv.mu.Lock()
 		defer kv.mu.Unlock()
 		kv.data = lastState.Data
+		if kv.data == nil {
+			kv.data = make(map[string][]byte)
+		}
+		if lastState.Expires != nil {
+			kv.expires = lastState.Expires
+		}
 	}
 }
 
@@ -163,19 +252,28 @@ func (kv *KVStore) loadFromWAL() {
 	for scanner.Scan() {
 		line := scanner.Bytes()
 		parts := splitLine(line)
-		if len(parts) == 2 {
+		if len(parts) == 2 || len(parts) == 3 {
 			key, value := parts[0], parts[1]
 			if len(value) == 0 {
 				delete(kv.data, string(key))
+				delete(kv.expires, string(key))
 			} else {
 				kv.data[string(key)] = value
+				delete(kv.expires, string(key))
+				if len(parts) == 3 {
+					expireAt, err := strconv.ParseInt(string(parts[2]), 10, 64)
+					if err == nil {
+						kv.expires[string(key)] = expireAt
+					}
+				}
 			}
 		}
 	}
 }
 
 // Write an entry to the WAL file.
-func (wal *WAL) writeEntry(key string, value []byte) error {
+// Entries with expiration carry the unix nano expire time as the third field.
+func (wal *WAL) writeEntry(key string, value []byte, expireAt int64) error {
 	wal.mu.Lock()
 	defer wal.mu.Unlock()
 
@@ -183,6 +281,10 @@ func (wal *WAL) writeEntry(key string, value []byte) error {
 	buffer.WriteString(key)
 	buffer.WriteString(splitChar)
 	buffer.Write(value)
+	if expireAt > 0 {
+		buffer.WriteString(splitChar)
+		buffer.WriteString(strconv.FormatInt(expireAt, 10))
+	}
 	buffer.WriteString("\n")
 	_, err := wal.walFile.Write(buffer.Bytes())
 	wal.walFile.Sync()
@@ -202,7 +304,7 @@ func (kv *KVStore) saveToLastState() {
 	kv.mu.Lock()
 	defer kv.mu.Unlock()
 
-	lastState := LastState{Data: kv.data}
+	lastState := LastState{Data: kv.data, Expires: kv.expires}
 	data, err := json.Marshal(lastState)
 	if err != nil {
 		log.Errorf("Error marshaling last state to JSON: %v", err)
@@ -230,7 +332,7 @@ func (kv *KVStore) Get(key string) ([]byte, error) {
 	kv.mu.Lock()
 	defer kv.mu.Unlock()
 
-	v, ok := kv.data[key]
+	v, ok := kv.get(key)
 	if !ok {
 		return nil, nil
 	}
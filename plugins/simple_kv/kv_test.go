@@ -0,0 +1,84 @@
+// Copyright (C) INFINI Labs & INFINI LIMITED.
+//
+// The INFINI Framework is offered under the GNU Affero General Public License v3.0
+// and as commercial software.
+//
+// For commercial licensing, contact us at:
+//   - Website: infinilabs.com
+//   - Email: hello@infini.ltd
+//
+// Open Source licensed under AGPL V3:
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <http://www.gnu.org/licenses/>.
+
+/* Copyright © INFINI LTD. All rights reserved.
+ * Web: https://infinilabs.com
+ * Email: hello#infini.ltd */
+
+package simple_kv
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKVStoreCompareAndSwap(t *testing.T) {
+	dir := t.TempDir()
+	store := NewKVStore(path.Join(dir, "last_state"), path.Join(dir, "wal"))
+
+	ok, err := store.CompareAndSwap("lock", nil, []byte("a"), 0)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, _ = store.CompareAndSwap("lock", nil, []byte("b"), 0)
+	assert.False(t, ok)
+	ok, _ = store.CompareAndSwap("lock", []byte("a"), []byte("b"), 0)
+	assert.True(t, ok)
+	ok, _ = store.CompareAndSwap("lock", []byte("b"), nil, 0)
+	assert.True(t, ok)
+	v, _ := store.Get("lock")
+	assert.Nil(t, v)
+}
+
+func TestKVStoreTTLReplay(t *testing.T) {
+	dir := t.TempDir()
+	store := NewKVStore(path.Join(dir, "last_state"), path.Join(dir, "wal"))
+
+	assert.Nil(t, store.SetWithTTL("b,short", []byte("1"), 50*time.Millisecond))
+	assert.Nil(t, store.SetWithTTL("b,long", []byte("2"), time.Hour))
+	assert.Nil(t, store.Set("a,other", []byte("3")))
+	store.wal.Close()
+
+	time.Sleep(100 * time.Millisecond)
+
+	//replay from wal only
+	_, err := os.Stat(path.Join(dir, "last_state"))
+	assert.True(t, os.IsNotExist(err))
+	store = NewKVStore(path.Join(dir, "last_state"), path.Join(dir, "wal"))
+	defer store.wal.Close()
+
+	v, _ := store.Get("b,short")
+	assert.Nil(t, v)
+	v, _ = store.Get("b,long")
+	assert.Equal(t, "2", string(v))
+
+	var keys []string
+	store.Scan("b,", func(key string, value []byte) bool {
+		keys = append(keys, key)
+		return true
+	})
+	assert.Equal(t, []string{"b,long"}, keys)
+}
@@ -29,7 +29,9 @@ package simple_kv
 
 import (
 	"errors"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/bkaradzic/go-lz4"
 	log "github.com/cihub/seelog"
@@ -136,3 +138,32 @@ func (filter *SimpleKV) ExistsKey(bucket string, key []byte) (bool, error) {
 func (filter *SimpleKV) DeleteKey(bucket string, key []byte) error {
 	return filter.Delete(bucket, key)
 }
+
+func (filter *SimpleKV) AddValueWithTTL(bucket string, key []byte, value []byte, ttl time.Duration) error {
+	if filter.closed {
+		return errors.New("module closed")
+	}
+	return filter.kvstore.SetWithTTL(joinKey(bucket, key), value, ttl)
+}
+
+func (filter *SimpleKV) PutIfAbsent(bucket string, key []byte, value []byte, ttl time.Duration) (bool, error) {
+	return filter.CompareAndSwap(bucket, key, nil, value, ttl)
+}
+
+func (filter *SimpleKV) CompareAndSwap(bucket string, key []byte, old, new []byte, ttl time.Duration) (bool, error) {
+	if filter.closed {
+		return false, errors.New("module closed")
+	}
+	return filter.kvstore.CompareAndSwap(joinKey(bucket, key), old, new, ttl)
+}
+
+func (filter *SimpleKV) ScanPrefix(bucket string, prefix []byte, fn func(key, value []byte) bool) error {
+	if filter.closed {
+		return errors.New("module closed")
+	}
+	bucketPrefix := joinKey(bucket, nil)
+	filter.kvstore.Scan(joinKey(bucket, prefix), func(key string, value []byte) bool {
+		return fn([]byte(strings.TrimPrefix(key, bucketPrefix)), value)
+	})
+	return nil
+}
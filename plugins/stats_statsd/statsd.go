@@ -30,6 +30,7 @@ import (
 	"github.com/rubyniu105/framework/core/env"
 	"github.com/rubyniu105/framework/core/errors"
 	"github.com/rubyniu105/framework/core/stats"
+	"sort"
 	"sync"
 	"time"
 )
@@ -169,6 +170,45 @@ func (module *StatsDModule) Gauge(category, key string, v int64) {
 	module.buffer.Gauge(category+"."+key, v)
 }
 
+// labeledKey appends the label values to the key, ordered by label names
+func labeledKey(category, key string, labels stats.Labels) string {
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	str := category + "." + key
+	for _, k := range names {
+		str += "." + labels[k]
+	}
+	return str
+}
+
+func (module *StatsDModule) IncrementWithLabels(category, key string, labels stats.Labels, value int64) {
+	if !module.statsdInited {
+		return
+	}
+	module.buffer.Incr(labeledKey(category, key, labels), value)
+}
+
+func (module *StatsDModule) GaugeWithLabels(category, key string, labels stats.Labels, v float64) {
+	if !module.statsdInited {
+		return
+	}
+	module.buffer.Gauge(labeledKey(category, key, labels), int64(v))
+}
+
+func (module *StatsDModule) Observe(category, key string, labels stats.Labels, v float64) {
+	if !module.statsdInited {
+		return
+	}
+	module.buffer.Timing(labeledKey(category, key, labels), int64(v))
+}
+
+func (module *StatsDModule) Collect() []*stats.MetricFamily {
+	return nil
+}
+
 func (module *StatsDModule) Stat(category, key string) int64 {
 	return 0
 }